@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Create the first admin user from ADMIN_EMAIL and ADMIN_PASSWORD
+// An existing user with that email is promoted instead, keeping their password
+func (cfg *apiConfig) bootstrapAdmin(ctx context.Context, email, password string) error {
+	if password == "" {
+		return errors.New("ADMIN_PASSWORD must be set with ADMIN_EMAIL")
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = cfg.store.BootstrapAdmin(ctx, database.BootstrapAdminParams{
+		Email:          email,
+		HashedPassword: hashedPassword,
+	})
+	return err
+}
+
+// Handler to change a user's role - PUT /admin/users/{userID}/role
+func (cfg *apiConfig) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	// Request section
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	adminID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user ID")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Error decoding request body")
+		return
+	}
+	defer r.Body.Close()
+
+	role, err := auth.ParseRole(params.Role)
+	if err != nil {
+		respondWithError(w, 400, "role must be user, moderator or admin")
+		return
+	}
+
+	// Stop admins from locking themselves out
+	if userID == adminID && role != auth.RoleAdmin {
+		respondWithError(w, 400, "You can't remove your own admin role")
+		return
+	}
+
+	// Change the role, and revoke the user's sessions if it was lowered
+	// Their access tokens carry the old role, so they're denied rather than left to expire
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error setting user role")
+		return
+	}
+	defer tx.Rollback()
+
+	access, err := tx.GetUserAccess(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "User not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving user", "error", err)
+		respondWithError(w, 500, "Error setting user role")
+		return
+	}
+
+	_, err = tx.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   userID,
+		Role: string(role),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error setting user role", "error", err)
+		respondWithError(w, 500, "Error setting user role")
+		return
+	}
+
+	var denied deniedTokens
+	if !role.AtLeast(auth.Role(access.Role)) {
+		sessionIDs, err := tx.RevokeUserRTokens(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+		if err == nil {
+			denied = deniedSessions(sessionIDs)
+			err = saveDeniedTokens(r.Context(), tx, denied)
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error revoking demoted user's sessions", "error", err)
+			respondWithError(w, 500, "Error setting user role")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing user role", "error", err)
+		respondWithError(w, 500, "Error setting user role")
+		return
+	}
+	cfg.applyDeniedTokens(denied)
+
+	// Respond with no content status
+	w.WriteHeader(204)
+}
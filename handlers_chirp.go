@@ -1,34 +1,71 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"sort"
+	"time"
 
 	"github.com/frogonabike/chirpy/internal/auth"
 	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/frogonabike/chirpy/internal/entitlements"
+	"github.com/frogonabike/chirpy/internal/moderation"
+	"github.com/frogonabike/chirpy/internal/store"
 	"github.com/google/uuid"
 )
 
+// Map a database chirp to the API chirp model
+// Deleted chirps are returned as tombstones without their body
+func chirpFromDB(chirp database.Chirp) Chirp {
+	c := Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID.UUID,
+		LikeCount: chirp.LikeCount,
+	}
+	if chirp.InReplyTo.Valid {
+		c.InReplyTo = &chirp.InReplyTo.UUID
+	}
+	if chirp.DeletedAt.Valid {
+		c.Body = ""
+		c.Deleted = true
+	}
+	if chirp.HiddenAt.Valid {
+		c.Hidden = true
+	}
+	return c
+}
+
+// Map a database chirp to the API chirp model as seen by a viewer
+// Hidden chirps are tombstones for everyone but their author, unless includeHidden is set for moderators
+func chirpForViewer(chirp database.Chirp, viewerID uuid.NullUUID, includeHidden bool) Chirp {
+	c := chirpFromDB(chirp)
+	if c.Hidden && !includeHidden && (!viewerID.Valid || viewerID.UUID != c.UserID) {
+		c.Body = ""
+	}
+	return c
+}
+
 // Hadler to validate chirp content and create chirp - POST /api/chirps
+// With publish_at, Chirpy Red users can schedule the chirp to be posted later instead
 func (cfg *apiConfig) chirpHandler(w http.ResponseWriter, r *http.Request) {
 	// Request section
 	type parameters struct {
-		Body string `json:"body"`
+		Body      string     `json:"body"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
+		PublishAt *time.Time `json:"publish_at"`
 	}
 
-	// Extract JWT from Authorization header
-	jwtToken, err := auth.GetBearerToken(r.Header)
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
 	if err != nil {
-		respondWithError(w, 401, "Missing or invalid Authorization header")
-		return
-	}
-
-	// Validate JWT
-	userID, err := auth.ValidateJWT(jwtToken, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, 401, "Invalid token")
+		respondWithAuthError(w, err)
 		return
 	}
 
@@ -37,147 +74,343 @@ func (cfg *apiConfig) chirpHandler(w http.ResponseWriter, r *http.Request) {
 	params := parameters{}
 	err = decoder.Decode(&params)
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
+		slog.ErrorContext(r.Context(), "Error decoding parameters", "error", err)
 		w.WriteHeader(500)
 		return
 	}
 	defer r.Body.Close()
 
-	// Check if Chirp over 140 characters
-	if len(params.Body) > 140 {
-		respondWithError(w, 400, "Chirp is too long")
+	// The user's plan sets the length limit and whether they can schedule chirps
+	plan, err := cfg.userPlan(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving user plan", "error", err)
+		respondWithError(w, 500, "Error creating chirp")
 		return
-	} else {
-		// If we reach here, Chirp is valid
-		cleaned_body := profanityFilter(params.Body)
-		params.Body = cleaned_body
+	}
+	if params.PublishAt != nil {
+		if !plan.Allows(entitlements.ScheduleChirps) {
+			respondWithError(w, 403, "Scheduling chirps requires Chirpy Red")
+			return
+		}
+		if !params.PublishAt.After(time.Now()) {
+			respondWithError(w, 400, "publish_at must be in the future")
+			return
+		}
 	}
 
-	// Create chirp in database
-	newChirp, err := cfg.dbQueries.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   params.Body,
-		UserID: uuid.NullUUID{UUID: userID, Valid: true},
-	})
+	// If this is a reply, check the parent chirp exists
+	var inReplyTo uuid.NullUUID
+	if params.InReplyTo != nil {
+		_, err := cfg.store.ReturnChirp(r.Context(), database.ReturnChirpParams{
+			ID:       *params.InReplyTo,
+			ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 400, "in_reply_to chirp does not exist")
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "Error retrieving parent chirp", "error", err)
+			respondWithError(w, 500, "Error creating chirp")
+			return
+		}
+		inReplyTo = uuid.NullUUID{UUID: *params.InReplyTo, Valid: true}
+	}
+
+	// Check chirp length and moderation rules
+	moderated, err := cfg.validateChirpBody(params.Body, plan)
 	if err != nil {
-		log.Printf("Error creating chirp: %s", err)
+		respondWithError(w, 400, err.Error())
+		return
 	}
 
-	// Map database chirp to API chirp model
-	createdChirp := Chirp{
-		ID:        newChirp.ID,
-		CreatedAt: newChirp.CreatedAt,
-		UpdatedAt: newChirp.UpdatedAt,
-		Body:      newChirp.Body,
-		UserID:    newChirp.UserID.UUID,
+	// Scheduled chirps keep their original body, as they're moderated again when they're posted
+	if params.PublishAt != nil {
+		scheduled, err := cfg.store.CreateScheduledChirp(r.Context(), database.CreateScheduledChirpParams{
+			UserID:    userID,
+			Body:      params.Body,
+			InReplyTo: inReplyTo,
+			PublishAt: *params.PublishAt,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error scheduling chirp", "error", err)
+			respondWithError(w, 500, "Error creating chirp")
+			return
+		}
+		respondWithJSON(w, 202, scheduledChirpFromDB(scheduled))
+		return
 	}
 
+	// Create the chirp along with its tags and mentions
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error creating chirp")
+		return
+	}
+	defer tx.Rollback()
+
+	newChirp, err := createChirp(r.Context(), tx, userID, inReplyTo, moderated)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating chirp", "error", err)
+		respondWithError(w, 500, "Error creating chirp")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing chirp", "error", err)
+		respondWithError(w, 500, "Error creating chirp")
+		return
+	}
+	cfg.metrics.ChirpsCreated.Inc()
+
 	// Response section
-	respondWithJSON(w, 201, createdChirp)
+	respondWithJSON(w, 201, chirpFromDB(newChirp))
+}
+
+// Create a chirp with a moderated body, along with its tags, mentions and moderation flags,
+// and queue its chirp.created webhooks
+// Shared by new chirps and scheduled ones, and meant to run in a transaction
+func createChirp(ctx context.Context, q store.Queries, userID uuid.UUID, inReplyTo uuid.NullUUID, moderated moderation.Result) (database.Chirp, error) {
+	newChirp, err := q.CreateChirp(ctx, database.CreateChirpParams{
+		Body:      moderated.Text,
+		UserID:    uuid.NullUUID{UUID: userID, Valid: true},
+		InReplyTo: inReplyTo,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if err := saveChirpTagsAndMentions(ctx, q, newChirp.ID, newChirp.Body); err != nil {
+		return database.Chirp{}, fmt.Errorf("saving tags and mentions: %w", err)
+	}
+	if err := saveModerationFlags(ctx, q, newChirp.ID, moderated); err != nil {
+		return database.Chirp{}, fmt.Errorf("saving moderation flags: %w", err)
+	}
+	if err := queueWebhookEvent(ctx, q, webhookEventChirpCreated, userID, chirpFromDB(newChirp)); err != nil {
+		return database.Chirp{}, fmt.Errorf("queueing webhooks: %w", err)
+	}
+	return newChirp, nil
 }
 
-// Handler to return all chirps - GET /api/chirps
+// Handler to return a page of chirps - GET /api/chirps
+// Supports ?author_id=, ?sort=asc|desc|likes, ?limit= and ?cursor= query params
+// Every chirp is returned, without a Link header, unless limit or cursor is given
 func (cfg *apiConfig) getAllChirpsHandler(w http.ResponseWriter, r *http.Request) {
-	var returnedChirps []Chirp
+	// A bearer token is optional, and used to fill in liked_by_me and show hidden chirps
+	viewerID, viewerRole, err := cfg.authenticateOptionalWithRole(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	// Clients from before pagination ask for neither limit nor cursor, and get every chirp
+	if !paginated(r) {
+		page.Limit = unpaginatedLimit
+	}
 
 	// Check if query param "author_id" is present
-	authorID := r.URL.Query().Get("author_id")
-	if authorID != "" {
-		// If present, return chirps by that author
-		chirps, err := cfg.dbQueries.ReturnUserChirps(r.Context(), uuid.NullUUID{UUID: uuid.MustParse(authorID), Valid: true})
-		if err != nil {
-			log.Fatalf("Error retrieving chirps by author: %s", err)
-			respondWithError(w, 500, "Error retrieving chirps by author")
-			return
-		}
-		// Map database chirps to API chirp models
-		for _, chirp := range chirps {
-			var c Chirp
-			c.ID = chirp.ID
-			c.CreatedAt = chirp.CreatedAt
-			c.UpdatedAt = chirp.UpdatedAt
-			c.Body = chirp.Body
-			c.UserID = chirp.UserID.UUID
-			returnedChirps = append(returnedChirps, c)
-		}
-		// respondWithJSON(w, 200, returnedChirps)
-		// return
-	} else {
-		// Retrieve all chirps from database
-		chirps, err := cfg.dbQueries.GetAllChirps(r.Context())
+	var authorID uuid.NullUUID
+	if authorParam := r.URL.Query().Get("author_id"); authorParam != "" {
+		id, err := uuid.Parse(authorParam)
 		if err != nil {
-			log.Fatalf("Error retrieving chirps: %s", err)
-			respondWithError(w, 500, "Error retrieving chirps")
+			respondWithError(w, 400, "Invalid author_id")
 			return
 		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
 
-		// Map database chirps to API chirp models
-		// var returnedChirps []Chirp
-		for _, chirp := range chirps {
-			var c Chirp
-			c.ID = chirp.ID
-			c.CreatedAt = chirp.CreatedAt
-			c.UpdatedAt = chirp.UpdatedAt
-			c.Body = chirp.Body
-			c.UserID = chirp.UserID.UUID
-			returnedChirps = append(returnedChirps, c)
+	// Fetch one extra row so we know whether there is a next page
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	var chirps []database.Chirp
+	// Check for sort query param - Default is ascending
+	switch r.URL.Query().Get("sort") {
+	case "", "asc":
+		chirps, err = cfg.store.ListChirpsAsc(r.Context(), database.ListChirpsAscParams{
+			AuthorID:        authorID,
+			ViewerID:        viewerID,
+			IncludeHidden:   viewerRole.AtLeast(auth.RoleModerator),
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       page.Limit + 1,
+		})
+	case "desc":
+		chirps, err = cfg.store.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+			AuthorID:        authorID,
+			ViewerID:        viewerID,
+			IncludeHidden:   viewerRole.AtLeast(auth.RoleModerator),
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageLimit:       page.Limit + 1,
+		})
+	case "likes":
+		var cursorLikeCount sql.NullInt32
+		if page.Cursor != nil {
+			cursorLikeCount = sql.NullInt32{Int32: page.Cursor.LikeCount, Valid: true}
 		}
-	}
-	// respondWithJSON(w, 200, returnedChirps)
-	// Check for sort query param - Default is ascending, so only need to handle descending
-	sortOrder := r.URL.Query().Get("sort")
-	if sortOrder == "desc" {
-		sort.Slice(returnedChirps, func(i, j int) bool {
-			return returnedChirps[i].CreatedAt.After(returnedChirps[j].CreatedAt)
+		chirps, err = cfg.store.ListChirpsByLikes(r.Context(), database.ListChirpsByLikesParams{
+			AuthorID:        authorID,
+			ViewerID:        viewerID,
+			IncludeHidden:   viewerRole.AtLeast(auth.RoleModerator),
+			CursorCreatedAt: cursorCreatedAt,
+			CursorLikeCount: cursorLikeCount,
+			CursorID:        cursorID,
+			PageLimit:       page.Limit + 1,
 		})
+	default:
+		respondWithError(w, 400, "sort must be asc, desc or likes")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving chirps", "error", err)
+		respondWithError(w, 500, "Error retrieving chirps")
+		return
+	}
+
+	if len(chirps) > int(page.Limit) {
+		chirps = chirps[:page.Limit]
+		last := chirps[len(chirps)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID, LikeCount: last.LikeCount})
+	}
+
+	// Map database chirps to API chirp models
+	returnedChirps := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		returnedChirps = append(returnedChirps, chirpFromDB(chirp))
+	}
+	err = cfg.setLikedByViewer(r.Context(), viewerID, returnedChirps)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving liked chirps", "error", err)
+		respondWithError(w, 500, "Error retrieving chirps")
+		return
 	}
 	respondWithJSON(w, 200, returnedChirps)
 }
 
 // Handler to return chirp by ID
 func (cfg *apiConfig) getChirpByIDHandler(w http.ResponseWriter, r *http.Request) {
+	// A bearer token is optional, and used to fill in liked_by_me and show hidden chirps
+	viewerID, viewerRole, err := cfg.authenticateOptionalWithRole(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
 	// Extract chirpID from URL
-	chirpID := r.PathValue("chirpID")
-	rtnChirp, err := cfg.dbQueries.ReturnChirp(r.Context(), uuid.MustParse(chirpID))
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+	rtnChirp, err := cfg.store.ReturnChirp(r.Context(), database.ReturnChirpParams{
+		ID:            chirpID,
+		ViewerID:      viewerID,
+		IncludeHidden: viewerRole.AtLeast(auth.RoleModerator),
+	})
 	if err != nil {
-		log.Printf("Error retrieving chirp by ID: %s", err)
+		slog.ErrorContext(r.Context(), "Error retrieving chirp by ID", "error", err)
 		respondWithError(w, 404, "Error retrieving chirp")
 		return
 	}
 
 	// Map database chirp to API chirp model
-	returnedChirp := Chirp{
-		ID:        rtnChirp.ID,
-		CreatedAt: rtnChirp.CreatedAt,
-		UpdatedAt: rtnChirp.UpdatedAt,
-		Body:      rtnChirp.Body,
-		UserID:    rtnChirp.UserID.UUID,
-	}
-	respondWithJSON(w, 200, returnedChirp)
+	returnedChirp := []Chirp{chirpFromDB(rtnChirp)}
+	err = cfg.setLikedByViewer(r.Context(), viewerID, returnedChirp)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving liked chirps", "error", err)
+		respondWithError(w, 500, "Error retrieving chirp")
+		return
+	}
+	respondWithJSON(w, 200, returnedChirp[0])
 }
 
-// Handler to delete chirp by ID - But ONLY if owned by user
-func (cfg *apiConfig) deleteChirpByIDHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract JWT from Authorization header
-	jwtToken, err := auth.GetBearerToken(r.Header)
+// Handler to return the thread around a chirp - GET /api/chirps/{chirpID}/thread
+// Returns the ancestors from the root down, and the replies as a tree
+func (cfg *apiConfig) getChirpThreadHandler(w http.ResponseWriter, r *http.Request) {
+	// A bearer token is optional, and lets authors and moderators see hidden chirps
+	viewerID, viewerRole, err := cfg.authenticateOptionalWithRole(r)
 	if err != nil {
-		respondWithError(w, 401, "Missing or invalid Authorization header")
+		respondWithAuthError(w, err)
+		return
+	}
+	includeHidden := viewerRole.AtLeast(auth.RoleModerator)
+
+	// Extract chirpID from URL
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+
+	// Deleted and hidden chirps still have a thread, shown around their tombstone
+	rtnChirp, err := cfg.store.ReturnChirpIncludingDeleted(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, 404, "Error retrieving chirp")
 		return
 	}
 
-	// Validate JWT
-	userID, err := auth.ValidateJWT(jwtToken, cfg.jwtSecret)
+	ancestors, err := cfg.store.GetChirpAncestors(r.Context(), chirpID)
 	if err != nil {
-		respondWithError(w, 401, "Invalid token")
+		slog.ErrorContext(r.Context(), "Error retrieving chirp ancestors", "error", err)
+		respondWithError(w, 500, "Error retrieving thread")
+		return
+	}
+
+	descendants, err := cfg.store.GetChirpDescendants(r.Context(), chirpID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving chirp replies", "error", err)
+		respondWithError(w, 500, "Error retrieving thread")
+		return
+	}
+
+	thread := ChirpThread{
+		Ancestors: make([]Chirp, 0, len(ancestors)),
+		Chirp:     &ThreadNode{Chirp: chirpForViewer(rtnChirp, viewerID, includeHidden), Replies: []*ThreadNode{}},
+	}
+	for _, ancestor := range ancestors {
+		thread.Ancestors = append(thread.Ancestors, chirpForViewer(ancestor, viewerID, includeHidden))
+	}
+
+	// Index every node first, then hang each reply off its parent in date order
+	nodes := map[uuid.UUID]*ThreadNode{rtnChirp.ID: thread.Chirp}
+	for _, descendant := range descendants {
+		nodes[descendant.ID] = &ThreadNode{Chirp: chirpForViewer(descendant, viewerID, includeHidden), Replies: []*ThreadNode{}}
+	}
+	for _, descendant := range descendants {
+		parent := nodes[descendant.InReplyTo.UUID]
+		parent.Replies = append(parent.Replies, nodes[descendant.ID])
+	}
+
+	respondWithJSON(w, 200, thread)
+}
+
+// Handler to delete chirp by ID - But ONLY if owned by user
+// Chirps are soft deleted so their replies remain, showing a tombstone
+func (cfg *apiConfig) deleteChirpByIDHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
 		return
 	}
 
 	// Extract chirpID from URL
-	chirpID := r.PathValue("chirpID")
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
 
 	// Retrieve chirp to check ownership
-	rtnChirp, err := cfg.dbQueries.ReturnChirp(r.Context(), uuid.MustParse(chirpID))
+	rtnChirp, err := cfg.store.ReturnChirp(r.Context(), database.ReturnChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
 	if err != nil {
-		log.Printf("Error retrieving chirp by ID: %s", err)
+		slog.ErrorContext(r.Context(), "Error retrieving chirp by ID", "error", err)
 		respondWithError(w, 404, "Error retrieving chirp")
 		return
 	}
@@ -191,13 +424,33 @@ func (cfg *apiConfig) deleteChirpByIDHandler(w http.ResponseWriter, r *http.Requ
 	// Proceed to delete the chirp
 	// Define parameters for deletion
 	deleteParams := database.DeleteChirpParams{
-		ID:     uuid.MustParse(chirpID),
+		ID:     chirpID,
 		UserID: uuid.NullUUID{UUID: userID, Valid: true},
 	}
 
-	err = cfg.dbQueries.DeleteChirp(r.Context(), deleteParams)
+	// Delete the chirp and queue its chirp.deleted webhooks together
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error deleting chirp")
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.DeleteChirp(r.Context(), deleteParams)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting chirp", "error", err)
+		respondWithError(w, 500, "Error deleting chirp")
+		return
+	}
+	err = queueWebhookEvent(r.Context(), tx, webhookEventChirpDeleted, userID, chirpDeletedData{ID: deleteParams.ID, UserID: userID})
 	if err != nil {
-		log.Printf("Error deleting chirp: %s", err)
+		slog.ErrorContext(r.Context(), "Error queueing webhooks", "error", err)
+		respondWithError(w, 500, "Error deleting chirp")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing chirp deletion", "error", err)
 		respondWithError(w, 500, "Error deleting chirp")
 		return
 	}
@@ -205,3 +458,183 @@ func (cfg *apiConfig) deleteChirpByIDHandler(w http.ResponseWriter, r *http.Requ
 	// Respond with no content status
 	w.WriteHeader(204)
 }
+
+// Handler to edit the body of a chirp - PUT /api/chirps/{chirpID}
+// Only the owner may edit, with Chirpy Red, and the previous body is kept as a revision
+func (cfg *apiConfig) updateChirpHandler(w http.ResponseWriter, r *http.Request) {
+	// Request section
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// Editing is a Chirpy Red feature
+	plan, err := cfg.userPlan(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving user plan", "error", err)
+		respondWithError(w, 500, "Error updating chirp")
+		return
+	}
+	if !plan.Allows(entitlements.EditChirps) {
+		respondWithError(w, 403, "Editing chirps requires Chirpy Red")
+		return
+	}
+
+	// Extract chirpID from URL
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+
+	// Decode request body
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Error decoding request body")
+		return
+	}
+	defer r.Body.Close()
+
+	// Edits go through the same checks as new chirps
+	moderated, err := cfg.validateChirpBody(params.Body, plan)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	params.Body = moderated.Text
+
+	// Save the old body and update the chirp in a single transaction
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error updating chirp")
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the chirp so concurrent edits can't lose a revision
+	oldChirp, err := tx.ReturnChirpForUpdate(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving chirp by ID", "error", err)
+		respondWithError(w, 500, "Error updating chirp")
+		return
+	}
+
+	// Check if the chirp belongs to the user
+	if oldChirp.UserID.UUID != userID {
+		respondWithError(w, 403, "You do not have permission to edit this chirp")
+		return
+	}
+
+	// Nothing to record if the body hasn't changed
+	if oldChirp.Body == params.Body {
+		respondWithJSON(w, 200, chirpFromDB(oldChirp))
+		return
+	}
+
+	_, err = tx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		ChirpID: oldChirp.ID,
+		Body:    oldChirp.Body,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating chirp revision", "error", err)
+		respondWithError(w, 500, "Error updating chirp")
+		return
+	}
+
+	updatedChirp, err := tx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirpID,
+		Body: params.Body,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating chirp", "error", err)
+		respondWithError(w, 500, "Error updating chirp")
+		return
+	}
+
+	err = saveChirpTagsAndMentions(r.Context(), tx, updatedChirp.ID, updatedChirp.Body)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving chirp tags and mentions", "error", err)
+		respondWithError(w, 500, "Error updating chirp")
+		return
+	}
+
+	err = saveModerationFlags(r.Context(), tx, updatedChirp.ID, moderated)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving moderation flags", "error", err)
+		respondWithError(w, 500, "Error updating chirp")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing chirp update", "error", err)
+		respondWithError(w, 500, "Error updating chirp")
+		return
+	}
+
+	// Response section
+	respondWithJSON(w, 200, chirpFromDB(updatedChirp))
+}
+
+// Handler to return the previous bodies of a chirp - GET /api/chirps/{chirpID}/revisions
+// Only the chirp's author and moderators can see what it said before an edit
+func (cfg *apiConfig) getChirpRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, role, err := cfg.authenticateWithRole(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	isModerator := role.AtLeast(auth.RoleModerator)
+
+	// Extract chirpID from URL
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+
+	// Make sure the chirp exists so we can 404 rather than return an empty list
+	chirp, err := cfg.store.ReturnChirp(r.Context(), database.ReturnChirpParams{
+		ID:            chirpID,
+		ViewerID:      uuid.NullUUID{UUID: userID, Valid: true},
+		IncludeHidden: isModerator,
+	})
+	if err != nil {
+		respondWithError(w, 404, "Error retrieving chirp")
+		return
+	}
+	if !isModerator && chirp.UserID.UUID != userID {
+		respondWithError(w, 403, "You can only see the revisions of your own chirps")
+		return
+	}
+
+	revisions, err := cfg.store.ListChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving chirp revisions", "error", err)
+		respondWithError(w, 500, "Error retrieving chirp revisions")
+		return
+	}
+
+	// Map database revisions to API revision models
+	returnedRevisions := make([]ChirpRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		returnedRevisions = append(returnedRevisions, ChirpRevision{
+			ID:        revision.ID,
+			CreatedAt: revision.CreatedAt,
+			ChirpID:   revision.ChirpID,
+			Body:      revision.Body,
+		})
+	}
+	respondWithJSON(w, 200, returnedRevisions)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Follow user handler - POST /api/users/{userID}/follow
+func (cfg *apiConfig) followUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	followerID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// Extract userID from URL
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user ID")
+		return
+	}
+	if followeeID == followerID {
+		respondWithError(w, 400, "You can't follow yourself")
+		return
+	}
+
+	// Check the user to follow exists
+	_, err = cfg.store.GetUserByID(r.Context(), followeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "User not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving user", "error", err)
+		respondWithError(w, 500, "Error following user")
+		return
+	}
+
+	// Following someone twice is a no-op
+	err = cfg.store.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error following user", "error", err)
+		respondWithError(w, 500, "Error following user")
+		return
+	}
+
+	// Respond with no content status
+	w.WriteHeader(204)
+}
+
+// Unfollow user handler - DELETE /api/users/{userID}/follow
+func (cfg *apiConfig) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	followerID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// Extract userID from URL
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user ID")
+		return
+	}
+
+	err = cfg.store.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error unfollowing user", "error", err)
+		respondWithError(w, 500, "Error unfollowing user")
+		return
+	}
+
+	// Respond with no content status
+	w.WriteHeader(204)
+}
+
+// Followers list handler - GET /api/users/{userID}/followers
+func (cfg *apiConfig) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user ID")
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	followers, err := cfg.store.ListFollowers(r.Context(), database.ListFollowersParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving followers", "error", err)
+		respondWithError(w, 500, "Error retrieving followers")
+		return
+	}
+
+	if len(followers) > int(page.Limit) {
+		followers = followers[:page.Limit]
+		last := followers[len(followers)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.UserID})
+	}
+
+	// Map database rows to API follow models
+	returnedFollows := make([]Follow, 0, len(followers))
+	for _, follower := range followers {
+		returnedFollows = append(returnedFollows, Follow{
+			UserID:     follower.UserID,
+			FollowedAt: follower.CreatedAt,
+		})
+	}
+	respondWithJSON(w, 200, returnedFollows)
+}
+
+// Following list handler - GET /api/users/{userID}/following
+func (cfg *apiConfig) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user ID")
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	following, err := cfg.store.ListFollowing(r.Context(), database.ListFollowingParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving followed users", "error", err)
+		respondWithError(w, 500, "Error retrieving followed users")
+		return
+	}
+
+	if len(following) > int(page.Limit) {
+		following = following[:page.Limit]
+		last := following[len(following)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.UserID})
+	}
+
+	// Map database rows to API follow models
+	returnedFollows := make([]Follow, 0, len(following))
+	for _, followee := range following {
+		returnedFollows = append(returnedFollows, Follow{
+			UserID:     followee.UserID,
+			FollowedAt: followee.CreatedAt,
+		})
+	}
+	respondWithJSON(w, 200, returnedFollows)
+}
+
+// Home timeline handler - GET /api/timeline
+// Returns chirps from followed users plus the user's own, newest first
+func (cfg *apiConfig) timelineHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, role, err := cfg.authenticateWithRole(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	// Fetch one extra row so we know whether there is a next page
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	chirps, err := cfg.store.ListTimeline(r.Context(), database.ListTimelineParams{
+		UserID:          userID,
+		IncludeHidden:   role.AtLeast(auth.RoleModerator),
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving timeline", "error", err)
+		respondWithError(w, 500, "Error retrieving timeline")
+		return
+	}
+
+	if len(chirps) > int(page.Limit) {
+		chirps = chirps[:page.Limit]
+		last := chirps[len(chirps)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	// Map database chirps to API chirp models
+	returnedChirps := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		returnedChirps = append(returnedChirps, chirpFromDB(chirp))
+	}
+	err = cfg.setLikedByViewer(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, returnedChirps)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving liked chirps", "error", err)
+		respondWithError(w, 500, "Error retrieving timeline")
+		return
+	}
+	respondWithJSON(w, 200, returnedChirps)
+}
@@ -1,22 +1,107 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/metrics"
 )
 
-// Handler for readiness probe
-func readyHandler(w http.ResponseWriter, r *http.Request) {
+// How long the readiness probe waits on each dependency
+const readinessCheckTimeout = 2 * time.Second
+
+// Result of checking one dependency in the readiness probe
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Body of the readiness probe response
+type readinessStatus struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]dependencyStatus `json:"dependencies,omitempty"`
+}
+
+// Handler for liveness probe - the process is up and serving requests
+func livezHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(200)
 	w.Write([]byte("OK"))
 }
 
+// Handler for readiness probe - the server can do useful work - GET /api/readyz
+// Goes unready as soon as shutdown starts, so load balancers stop sending traffic
+func (cfg *apiConfig) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.shuttingDown.Load() {
+		respondWithJSON(w, 503, readinessStatus{Status: "shutting_down"})
+		return
+	}
+
+	status := readinessStatus{
+		Status: "ready",
+		Dependencies: map[string]dependencyStatus{
+			"database": checkDependency(r.Context(), cfg.store.Ping),
+		},
+	}
+	// Only the database backends have migrations to check
+	if cfg.db != nil {
+		status.Dependencies["migrations"] = checkDependency(r.Context(), cfg.checkSchemaVersion)
+	}
+	code := 200
+	for _, dependency := range status.Dependencies {
+		if dependency.Status != "up" {
+			status.Status = "unready"
+			code = 503
+		}
+	}
+	respondWithJSON(w, code, status)
+}
+
+// Run one readiness check with a timeout, timing how long it took
+func checkDependency(ctx context.Context, check func(context.Context) error) dependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	status := dependencyStatus{
+		Status:    "up",
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Readiness check failed", "error", err)
+		status.Status = "down"
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Check the database has had exactly the migrations embedded in this binary
+// goose deletes a version's row when it's rolled back, so the highest applied row is current
+// This reads the table directly, as goose's own helper waits for the migration lock
+func (cfg *apiConfig) checkSchemaVersion(ctx context.Context) error {
+	var version sql.NullInt64
+	err := cfg.db.QueryRowContext(ctx, "SELECT MAX(version_id) FROM goose_db_version WHERE is_applied").Scan(&version)
+	if err != nil {
+		return err
+	}
+	if version.Int64 != cfg.schemaVersion {
+		return fmt.Errorf("schema version is %d, expected %d", version.Int64, cfg.schemaVersion)
+	}
+	return nil
+}
+
 // Handler for returning server hit count
+// Reads the same counter that /metrics exports
 func (cfg *apiConfig) metricsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(200)
-	w.Write([]byte(fmt.Sprintf("<html><body><h1>Welcome, Chirpy Admin</h1><p>Chirpy has been visited %d times!</p></body></html>", cfg.fileserverHits.Load())))
+	w.Write([]byte(fmt.Sprintf("<html><body><h1>Welcome, Chirpy Admin</h1><p>Chirpy has been visited %d times!</p></body></html>", int64(metrics.CounterValue(cfg.metrics.FileserverHits)))))
 }
 
 // // Handler to reset metrics
@@ -31,7 +116,7 @@ func (cfg *apiConfig) resetUsersHandler(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, 403, "User reset is only allowed in dev environment")
 		return
 	}
-	err := cfg.dbQueries.ResetUsers(r.Context())
+	err := cfg.store.ResetUsers(r.Context())
 	if err != nil {
 		respondWithError(w, 500, "Error resetting users database")
 		return
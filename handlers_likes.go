@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Like chirp handler - POST /api/chirps/{chirpID}/likes
+func (cfg *apiConfig) likeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeChirpLike(w, r, true)
+}
+
+// Unlike chirp handler - DELETE /api/chirps/{chirpID}/likes
+func (cfg *apiConfig) unlikeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeChirpLike(w, r, false)
+}
+
+// Shared body of the like and unlike handlers
+// Both are idempotent, and the chirp's like_count only moves when a like is added or removed
+func (cfg *apiConfig) changeChirpLike(w http.ResponseWriter, r *http.Request, like bool) {
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// Extract chirpID from URL
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+
+	// Check the chirp exists
+	_, err = cfg.store.ReturnChirp(r.Context(), database.ReturnChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving chirp by ID", "error", err)
+		respondWithError(w, 500, "Error updating like")
+		return
+	}
+
+	// Update the like and the chirp's count together
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error updating like")
+		return
+	}
+	defer tx.Rollback()
+
+	var changed int64
+	var delta int32
+	if like {
+		changed, err = tx.LikeChirp(r.Context(), database.LikeChirpParams{UserID: userID, ChirpID: chirpID})
+		delta = 1
+	} else {
+		changed, err = tx.UnlikeChirp(r.Context(), database.UnlikeChirpParams{UserID: userID, ChirpID: chirpID})
+		delta = -1
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating like", "error", err)
+		respondWithError(w, 500, "Error updating like")
+		return
+	}
+
+	if changed > 0 {
+		err = tx.AdjustChirpLikeCount(r.Context(), database.AdjustChirpLikeCountParams{ID: chirpID, Delta: delta})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error updating like count", "error", err)
+			respondWithError(w, 500, "Error updating like")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing like", "error", err)
+		respondWithError(w, 500, "Error updating like")
+		return
+	}
+
+	// Respond with no content status
+	w.WriteHeader(204)
+}
+
+// Fill in liked_by_me on chirps for a logged in viewer
+// Leaves the field unset for anonymous requests
+func (cfg *apiConfig) setLikedByViewer(ctx context.Context, viewerID uuid.NullUUID, chirps []Chirp) error {
+	if !viewerID.Valid || len(chirps) == 0 {
+		return nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+	likedIDs, err := cfg.store.ListLikedChirpIDs(ctx, database.ListLikedChirpIDsParams{
+		UserID:   viewerID.UUID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	liked := make(map[uuid.UUID]bool, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	for i := range chirps {
+		likedByMe := liked[chirps[i].ID]
+		chirps[i].LikedByMe = &likedByMe
+	}
+	return nil
+}
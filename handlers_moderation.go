@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/frogonabike/chirpy/internal/moderation"
+	"github.com/frogonabike/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// How often each server picks up word list changes made on other replicas
+const moderationReloadInterval = time.Minute
+
+// Load the moderation rules from the word file and the database into the engine
+// Words in the database override the same word in the file, so admins can change them
+func (cfg *apiConfig) reloadModerationRules(ctx context.Context) error {
+	words, err := cfg.store.ListModerationWords(ctx)
+	if err != nil {
+		return err
+	}
+
+	inDB := make(map[string]bool, len(words))
+	rules := make([]moderation.Rule, 0, len(words)+len(cfg.moderationFileRules))
+	for _, word := range words {
+		inDB[word.Word] = true
+		rules = append(rules, moderation.Rule{Word: word.Word, Action: moderation.Action(word.Action)})
+	}
+	for _, rule := range cfg.moderationFileRules {
+		if !inDB[strings.ToLower(rule.Word)] {
+			rules = append(rules, rule)
+		}
+	}
+
+	cfg.moderation.SetRules(rules)
+	return nil
+}
+
+// Periodically reload the moderation rules until the context is cancelled
+func (cfg *apiConfig) watchModerationRules(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.reloadModerationRules(ctx); err != nil {
+				slog.ErrorContext(ctx, "Error reloading moderation rules", "error", err)
+			}
+		}
+	}
+}
+
+// Record a moderation flag for every flagged word in a chirp
+func saveModerationFlags(ctx context.Context, q store.Queries, chirpID uuid.UUID, result moderation.Result) error {
+	for _, match := range result.Matches {
+		if match.Action != moderation.ActionFlag {
+			continue
+		}
+		err := q.CreateModerationFlag(ctx, database.CreateModerationFlagParams{
+			ChirpID: chirpID,
+			Word:    match.Word,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Map a database moderation word to the API model
+func moderationWordFromDB(word database.ModerationWord) ModerationWord {
+	return ModerationWord{
+		Word:      word.Word,
+		CreatedAt: word.CreatedAt,
+		UpdatedAt: word.UpdatedAt,
+		Action:    word.Action,
+	}
+}
+
+// Handler to list the moderation word list - GET /admin/moderation/words
+func (cfg *apiConfig) listModerationWordsHandler(w http.ResponseWriter, r *http.Request) {
+	words, err := cfg.store.ListModerationWords(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving moderation words", "error", err)
+		respondWithError(w, 500, "Error retrieving moderation words")
+		return
+	}
+
+	returnedWords := make([]ModerationWord, 0, len(words))
+	for _, word := range words {
+		returnedWords = append(returnedWords, moderationWordFromDB(word))
+	}
+	respondWithJSON(w, 200, returnedWords)
+}
+
+// Handler to add a word or change its action - POST /admin/moderation/words
+func (cfg *apiConfig) upsertModerationWordHandler(w http.ResponseWriter, r *http.Request) {
+	// Request section
+	type parameters struct {
+		Word   string `json:"word"`
+		Action string `json:"action"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Error decoding request body")
+		return
+	}
+	defer r.Body.Close()
+
+	// Words are single tokens, stored lowercased
+	word := strings.ToLower(strings.TrimSpace(params.Word))
+	if word == "" || strings.ContainsFunc(word, unicode.IsSpace) || moderation.Normalize(word) == "" {
+		respondWithError(w, 400, "word must be a single word")
+		return
+	}
+	if params.Action == "" {
+		params.Action = string(moderation.ActionMask)
+	}
+	action, err := moderation.ParseAction(params.Action)
+	if err != nil {
+		respondWithError(w, 400, "action must be mask, flag or reject")
+		return
+	}
+
+	saved, err := cfg.store.UpsertModerationWord(r.Context(), database.UpsertModerationWordParams{
+		Word:   word,
+		Action: string(action),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving moderation word", "error", err)
+		respondWithError(w, 500, "Error saving moderation word")
+		return
+	}
+
+	// Apply the change straight away on this server
+	if err := cfg.reloadModerationRules(r.Context()); err != nil {
+		slog.ErrorContext(r.Context(), "Error reloading moderation rules", "error", err)
+	}
+
+	respondWithJSON(w, 200, moderationWordFromDB(saved))
+}
+
+// Handler to remove a word - DELETE /admin/moderation/words/{word}
+func (cfg *apiConfig) deleteModerationWordHandler(w http.ResponseWriter, r *http.Request) {
+	word := strings.ToLower(r.PathValue("word"))
+
+	deleted, err := cfg.store.DeleteModerationWord(r.Context(), word)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting moderation word", "error", err)
+		respondWithError(w, 500, "Error deleting moderation word")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "Word not found")
+		return
+	}
+
+	// Apply the change straight away on this server
+	if err := cfg.reloadModerationRules(r.Context()); err != nil {
+		slog.ErrorContext(r.Context(), "Error reloading moderation rules", "error", err)
+	}
+
+	// Respond with no content status
+	w.WriteHeader(204)
+}
+
+// Handler to list chirps flagged for review - GET /admin/moderation/flags
+func (cfg *apiConfig) listModerationFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	// Fetch one extra row so we know whether there is a next page
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	flags, err := cfg.store.ListPendingModerationFlags(r.Context(), database.ListPendingModerationFlagsParams{
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving moderation flags", "error", err)
+		respondWithError(w, 500, "Error retrieving moderation flags")
+		return
+	}
+
+	if len(flags) > int(page.Limit) {
+		flags = flags[:page.Limit]
+		last := flags[len(flags)-1].ModerationFlag
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	// Moderators need to see what was said, even if the chirp was since deleted
+	returnedFlags := make([]ModerationFlag, 0, len(flags))
+	for _, flag := range flags {
+		chirp := chirpFromDB(flag.Chirp)
+		chirp.Body = flag.Chirp.Body
+		returnedFlags = append(returnedFlags, ModerationFlag{
+			ID:        flag.ModerationFlag.ID,
+			CreatedAt: flag.ModerationFlag.CreatedAt,
+			Word:      flag.ModerationFlag.Word,
+			Chirp:     chirp,
+		})
+	}
+	respondWithJSON(w, 200, returnedFlags)
+}
+
+// Handler to mark a flag as reviewed - POST /admin/moderation/flags/{flagID}/review
+func (cfg *apiConfig) reviewModerationFlagHandler(w http.ResponseWriter, r *http.Request) {
+	flagID, err := uuid.Parse(r.PathValue("flagID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid flag ID")
+		return
+	}
+
+	reviewed, err := cfg.store.ReviewModerationFlag(r.Context(), flagID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reviewing moderation flag", "error", err)
+		respondWithError(w, 500, "Error reviewing moderation flag")
+		return
+	}
+	if reviewed == 0 {
+		respondWithError(w, 404, "Flag not found or already reviewed")
+		return
+	}
+
+	// Respond with no content status
+	w.WriteHeader(204)
+}
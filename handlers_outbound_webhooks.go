@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Shortest secret accepted when a subscriber picks their own
+const minWebhookSecretLength = 16
+
+// Map a database webhook subscription and its events to the API model, leaving out the secret
+func webhookSubscriptionFromDB(sub database.WebhookSubscription, events []string) WebhookSubscription {
+	s := WebhookSubscription{
+		ID:        sub.ID,
+		CreatedAt: sub.CreatedAt,
+		URL:       sub.Url,
+		Events:    events,
+	}
+	if sub.UserID.Valid {
+		s.UserID = &sub.UserID.UUID
+	}
+	return s
+}
+
+// Map a database delivery attempt to the API model
+func webhookDeliveryAttemptFromDB(attempt database.ListWebhookDeliveryAttemptsRow) WebhookDeliveryAttempt {
+	a := WebhookDeliveryAttempt{
+		ID:             attempt.ID,
+		CreatedAt:      attempt.CreatedAt,
+		DeliveryID:     attempt.DeliveryID,
+		Event:          attempt.Event,
+		DeliveryStatus: attempt.Status,
+		ResponseBody:   attempt.ResponseBody,
+		Error:          attempt.Error.String,
+	}
+	if attempt.ResponseStatus.Valid {
+		a.ResponseStatus = &attempt.ResponseStatus.Int32
+	}
+	return a
+}
+
+// Check a webhook URL is absolute http or https, and that its host resolves to public addresses
+// The client checks the address again when it connects, since DNS can change after registration
+func validateWebhookURL(ctx context.Context, rawURL string, allowPrivate bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if allowPrivate {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return errors.New("url host could not be resolved")
+	}
+	for _, addr := range addrs {
+		if !publicWebhookAddr(addr) {
+			return errWebhookAddress
+		}
+	}
+	return nil
+}
+
+// Check the events are ones we send, returning them without duplicates
+func validateWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, errors.New("events must list at least one event")
+	}
+	for _, event := range events {
+		if !slices.Contains(webhookEvents, event) {
+			return nil, errors.New("events must be chirp.created, chirp.deleted or user.upgraded")
+		}
+	}
+	events = slices.Clone(events)
+	slices.Sort(events)
+	return slices.Compact(events), nil
+}
+
+// Handler to subscribe to webhooks about the user - POST /api/users/me/webhooks
+func (cfg *apiConfig) createWebhookSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	cfg.createWebhookSubscription(w, r, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+// Handler to subscribe to webhooks about every user - POST /admin/webhooks
+func (cfg *apiConfig) createAdminWebhookSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.createWebhookSubscription(w, r, uuid.NullUUID{})
+}
+
+// Create a subscription owned by a user, or an admin one if owner is NULL
+// A secret is generated unless the subscriber sends their own
+func (cfg *apiConfig) createWebhookSubscription(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	// Request section
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+
+	// Decode request body
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Invalid request body")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := validateWebhookURL(r.Context(), params.URL, cfg.webhookAllowPrivate); err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	events, err := validateWebhookEvents(params.Events)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	secret := params.Secret
+	if secret == "" {
+		secret, err = auth.MakeWebhookSecret()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error generating webhook secret", "error", err)
+			respondWithError(w, 500, "Error creating webhook subscription")
+			return
+		}
+	} else if len(secret) < minWebhookSecretLength {
+		respondWithError(w, 400, "secret must be at least 16 characters")
+		return
+	}
+
+	// Create the subscription along with its events
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error creating webhook subscription")
+		return
+	}
+	defer tx.Rollback()
+
+	sub, err := tx.CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+		UserID: owner,
+		Url:    params.URL,
+		Secret: secret,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating webhook subscription", "error", err)
+		respondWithError(w, 500, "Error creating webhook subscription")
+		return
+	}
+	err = tx.AddWebhookSubscriptionEvents(r.Context(), database.AddWebhookSubscriptionEventsParams{
+		SubscriptionID: sub.ID,
+		Events:         events,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving webhook subscription events", "error", err)
+		respondWithError(w, 500, "Error creating webhook subscription")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing webhook subscription", "error", err)
+		respondWithError(w, 500, "Error creating webhook subscription")
+		return
+	}
+
+	// Response section - the only time the secret is returned
+	created := webhookSubscriptionFromDB(sub, events)
+	created.Secret = sub.Secret
+	respondWithJSON(w, 201, created)
+}
+
+// Handler to list the user's webhook subscriptions - GET /api/users/me/webhooks
+func (cfg *apiConfig) listWebhookSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	cfg.listWebhookSubscriptions(w, r, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+// Handler to list the admin webhook subscriptions - GET /admin/webhooks
+func (cfg *apiConfig) listAdminWebhookSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listWebhookSubscriptions(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) listWebhookSubscriptions(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	subs, err := cfg.store.ListWebhookSubscriptions(r.Context(), owner)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving webhook subscriptions", "error", err)
+		respondWithError(w, 500, "Error retrieving webhook subscriptions")
+		return
+	}
+
+	// Map returned database rows to API models, with each one's events
+	returnedSubs := make([]WebhookSubscription, 0, len(subs))
+	for _, sub := range subs {
+		events, err := cfg.store.ListWebhookSubscriptionEvents(r.Context(), sub.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error retrieving webhook subscription events", "error", err)
+			respondWithError(w, 500, "Error retrieving webhook subscriptions")
+			return
+		}
+		returnedSubs = append(returnedSubs, webhookSubscriptionFromDB(sub, events))
+	}
+
+	// Response section
+	respondWithJSON(w, 200, returnedSubs)
+}
+
+// Handler to unsubscribe from webhooks - DELETE /api/users/me/webhooks/{webhookID}
+// Deliveries still queued are dropped
+func (cfg *apiConfig) deleteWebhookSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	cfg.deleteWebhookSubscription(w, r, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+// Handler to delete an admin webhook subscription - DELETE /admin/webhooks/{webhookID}
+func (cfg *apiConfig) deleteAdminWebhookSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.deleteWebhookSubscription(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) deleteWebhookSubscription(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	// Extract webhookID from URL
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid webhook ID")
+		return
+	}
+
+	deleted, err := cfg.store.DeleteWebhookSubscription(r.Context(), database.DeleteWebhookSubscriptionParams{
+		ID:     webhookID,
+		UserID: owner,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting webhook subscription", "error", err)
+		respondWithError(w, 500, "Error deleting webhook subscription")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "Webhook subscription not found")
+		return
+	}
+
+	// Response section
+	w.WriteHeader(204)
+}
+
+// Handler to return a webhook subscription's delivery log, newest attempt first - GET /api/users/me/webhooks/{webhookID}/deliveries
+// Supports ?limit= and ?cursor= query params
+func (cfg *apiConfig) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	cfg.listWebhookDeliveries(w, r, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+// Handler to return an admin webhook subscription's delivery log - GET /admin/webhooks/{webhookID}/deliveries
+func (cfg *apiConfig) listAdminWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listWebhookDeliveries(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) listWebhookDeliveries(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	// Extract webhookID from URL
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid webhook ID")
+		return
+	}
+
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	// Other users' subscriptions are reported as not found
+	sub, err := cfg.store.GetWebhookSubscription(r.Context(), webhookID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && sub.UserID != owner) {
+		respondWithError(w, 404, "Webhook subscription not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving webhook subscription", "error", err)
+		respondWithError(w, 500, "Error retrieving webhook deliveries")
+		return
+	}
+
+	// Fetch one extra row so we know whether there is a next page
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	attempts, err := cfg.store.ListWebhookDeliveryAttempts(r.Context(), database.ListWebhookDeliveryAttemptsParams{
+		SubscriptionID:  sub.ID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving webhook deliveries", "error", err)
+		respondWithError(w, 500, "Error retrieving webhook deliveries")
+		return
+	}
+
+	if len(attempts) > int(page.Limit) {
+		attempts = attempts[:page.Limit]
+		last := attempts[len(attempts)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	// Map returned database rows to API models
+	returnedAttempts := make([]WebhookDeliveryAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		returnedAttempts = append(returnedAttempts, webhookDeliveryAttemptFromDB(attempt))
+	}
+
+	// Response section
+	respondWithJSON(w, 200, returnedAttempts)
+}
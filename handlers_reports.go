@@ -0,0 +1,347 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Report statuses, matching the CHECK constraint on reports.status
+const (
+	reportStatusOpen            = "open"
+	reportStatusDismissed       = "dismissed"
+	reportStatusChirpHidden     = "chirp_hidden"
+	reportStatusAuthorSuspended = "author_suspended"
+)
+
+// Longest reason accepted with a report
+const maxReportReasonLength = 500
+
+// Map a database report to the API report model
+func reportFromDB(report database.Report) Report {
+	r := Report{
+		ID:         report.ID,
+		CreatedAt:  report.CreatedAt,
+		UpdatedAt:  report.UpdatedAt,
+		ReporterID: report.ReporterID,
+		Reason:     report.Reason,
+		Status:     report.Status,
+	}
+	if report.ResolvedAt.Valid {
+		r.ResolvedAt = &report.ResolvedAt.Time
+	}
+	return r
+}
+
+// Handler to report an abusive chirp - POST /api/chirps/{chirpID}/reports
+func (cfg *apiConfig) reportChirpHandler(w http.ResponseWriter, r *http.Request) {
+	// Request section
+	type parameters struct {
+		Reason string `json:"reason"`
+	}
+
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// Extract chirpID from URL
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Error decoding request body")
+		return
+	}
+	defer r.Body.Close()
+
+	reason := strings.TrimSpace(params.Reason)
+	if reason == "" {
+		respondWithError(w, 400, "reason is required")
+		return
+	}
+	if len(reason) > maxReportReasonLength {
+		respondWithError(w, 400, "reason is too long")
+		return
+	}
+
+	// Check the chirp exists and isn't the reporter's own
+	chirp, err := cfg.store.ReturnChirp(r.Context(), database.ReturnChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving chirp by ID", "error", err)
+		respondWithError(w, 500, "Error creating report")
+		return
+	}
+	if chirp.UserID.UUID == userID {
+		respondWithError(w, 400, "You can't report your own chirp")
+		return
+	}
+
+	report, err := cfg.store.CreateReport(r.Context(), database.CreateReportParams{
+		ChirpID:    chirpID,
+		ReporterID: userID,
+		Reason:     reason,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 409, "You have already reported this chirp")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating report", "error", err)
+		respondWithError(w, 500, "Error creating report")
+		return
+	}
+
+	respondWithJSON(w, 201, reportFromDB(report))
+}
+
+// Handler to list reports in the moderation queue - GET /admin/reports
+// Supports ?status= (default open) to look back at resolved reports
+func (cfg *apiConfig) listReportsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = reportStatusOpen
+	case reportStatusOpen, reportStatusDismissed, reportStatusChirpHidden, reportStatusAuthorSuspended:
+	default:
+		respondWithError(w, 400, "status must be open, dismissed, chirp_hidden or author_suspended")
+		return
+	}
+
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	// Fetch one extra row so we know whether there is a next page
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	reports, err := cfg.store.ListReports(r.Context(), database.ListReportsParams{
+		Status:          status,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving reports", "error", err)
+		respondWithError(w, 500, "Error retrieving reports")
+		return
+	}
+
+	if len(reports) > int(page.Limit) {
+		reports = reports[:page.Limit]
+		last := reports[len(reports)-1].Report
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	// Moderators need to see what was said, even if the chirp was since deleted or hidden
+	returnedReports := make([]Report, 0, len(reports))
+	for _, report := range reports {
+		chirp := chirpFromDB(report.Chirp)
+		chirp.Body = report.Chirp.Body
+		returnedReport := reportFromDB(report.Report)
+		returnedReport.Chirp = &chirp
+		returnedReports = append(returnedReports, returnedReport)
+	}
+	respondWithJSON(w, 200, returnedReports)
+}
+
+// Handler to resolve a report - POST /admin/reports/{reportID}/resolve
+// The action is dismiss, hide_chirp or suspend_author
+func (cfg *apiConfig) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	// Request section
+	type parameters struct {
+		Action string `json:"action"`
+	}
+
+	_, resolverRole, err := cfg.authenticateWithRole(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid report ID")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "Error decoding request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var status string
+	switch params.Action {
+	case "dismiss":
+		status = reportStatusDismissed
+	case "hide_chirp":
+		status = reportStatusChirpHidden
+	case "suspend_author":
+		status = reportStatusAuthorSuspended
+	default:
+		respondWithError(w, 400, "action must be dismiss, hide_chirp or suspend_author")
+		return
+	}
+
+	// Resolve the report and apply its action together
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error resolving report")
+		return
+	}
+	defer tx.Rollback()
+
+	report, err := tx.ResolveReport(r.Context(), database.ResolveReportParams{
+		ID:     reportID,
+		Status: status,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Tell a missing report apart from one that was already resolved
+		if _, err := tx.ReturnReport(r.Context(), reportID); err == nil {
+			respondWithError(w, 409, "Report already resolved")
+		} else {
+			respondWithError(w, 404, "Report not found")
+		}
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error resolving report", "error", err)
+		respondWithError(w, 500, "Error resolving report")
+		return
+	}
+
+	var denied deniedTokens
+	if status != reportStatusDismissed {
+		chirp, err := tx.ReturnChirpIncludingDeleted(r.Context(), report.ChirpID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error retrieving reported chirp", "error", err)
+			respondWithError(w, 500, "Error resolving report")
+			return
+		}
+
+		if status == reportStatusChirpHidden {
+			err = tx.HideChirp(r.Context(), chirp.ID)
+		} else {
+			// Moderators can only suspend users below them, so staff can't lock each other out
+			if chirp.UserID.Valid {
+				author, err := tx.GetUserAccess(r.Context(), chirp.UserID.UUID)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "Error retrieving reported author", "error", err)
+					respondWithError(w, 500, "Error resolving report")
+					return
+				}
+				if auth.Role(author.Role).AtLeast(resolverRole) {
+					respondWithError(w, 403, "You can't suspend a user whose role is the same as or higher than yours")
+					return
+				}
+			}
+
+			// Suspended users also lose their sessions, so their tokens stay unusable if they're unsuspended
+			err = tx.SuspendUser(r.Context(), chirp.UserID.UUID)
+			if err == nil {
+				var sessionIDs []uuid.UUID
+				sessionIDs, err = tx.RevokeUserRTokens(r.Context(), chirp.UserID)
+				denied = deniedSessions(sessionIDs)
+			}
+			if err == nil {
+				err = saveDeniedTokens(r.Context(), tx, denied)
+			}
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error applying report action", "error", err)
+			respondWithError(w, 500, "Error resolving report")
+			return
+		}
+
+		// Other reports about the chirp have been dealt with too
+		err = tx.ResolveOpenChirpReports(r.Context(), database.ResolveOpenChirpReportsParams{
+			ChirpID: chirp.ID,
+			Status:  status,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error resolving other reports", "error", err)
+			respondWithError(w, 500, "Error resolving report")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing report resolution", "error", err)
+		respondWithError(w, 500, "Error resolving report")
+		return
+	}
+	cfg.applyDeniedTokens(denied)
+
+	respondWithJSON(w, 200, reportFromDB(report))
+}
+
+// Handler to make a hidden chirp visible again - POST /admin/chirps/{chirpID}/unhide
+func (cfg *apiConfig) unhideChirpHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID")
+		return
+	}
+
+	unhidden, err := cfg.store.UnhideChirp(r.Context(), chirpID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error unhiding chirp", "error", err)
+		respondWithError(w, 500, "Error unhiding chirp")
+		return
+	}
+	if unhidden == 0 {
+		respondWithError(w, 404, "Chirp not found or not hidden")
+		return
+	}
+
+	// Respond with no content status
+	w.WriteHeader(204)
+}
+
+// Handler to lift a user's suspension - POST /admin/users/{userID}/unsuspend
+func (cfg *apiConfig) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user ID")
+		return
+	}
+
+	unsuspended, err := cfg.store.UnsuspendUser(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error unsuspending user", "error", err)
+		respondWithError(w, 500, "Error unsuspending user")
+		return
+	}
+	if unsuspended == 0 {
+		respondWithError(w, 404, "User not found or not suspended")
+		return
+	}
+
+	// Respond with no content status
+	w.WriteHeader(204)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// How often to post the scheduled chirps that are due
+const scheduledChirpsInterval = 30 * time.Second
+
+// Most scheduled chirps posted at a time
+const scheduledChirpsBatchSize = 100
+
+// Map a database scheduled chirp to the API model
+func scheduledChirpFromDB(chirp database.ScheduledChirp) ScheduledChirp {
+	c := ScheduledChirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		PublishAt: chirp.PublishAt,
+	}
+	if chirp.InReplyTo.Valid {
+		c.InReplyTo = &chirp.InReplyTo.UUID
+	}
+	return c
+}
+
+// Handler to list the user's scheduled chirps, soonest first - GET /api/users/me/scheduled-chirps
+func (cfg *apiConfig) listScheduledChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	rows, err := cfg.store.ListUserScheduledChirps(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving scheduled chirps", "error", err)
+		respondWithError(w, 500, "Error retrieving scheduled chirps")
+		return
+	}
+
+	// Map returned database rows to API models
+	chirps := make([]ScheduledChirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, scheduledChirpFromDB(row))
+	}
+
+	// Response section
+	respondWithJSON(w, 200, chirps)
+}
+
+// Handler to cancel a scheduled chirp - DELETE /api/users/me/scheduled-chirps/{scheduledChirpID}
+// Works without Chirpy Red, so users who lose it can still cancel what they scheduled
+func (cfg *apiConfig) deleteScheduledChirpHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// Extract scheduledChirpID from URL
+	scheduledChirpID, err := uuid.Parse(r.PathValue("scheduledChirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid scheduled chirp ID")
+		return
+	}
+
+	deleted, err := cfg.store.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{
+		ID:     scheduledChirpID,
+		UserID: userID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting scheduled chirp", "error", err)
+		respondWithError(w, 500, "Error deleting scheduled chirp")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "Scheduled chirp not found")
+		return
+	}
+
+	// Response section
+	w.WriteHeader(204)
+}
+
+// Post the scheduled chirps due by a time, returning how many were posted
+// They're moderated again, as the rules may have changed since they were scheduled,
+// and ones that are now rejected are dropped
+func (cfg *apiConfig) publishScheduledChirps(ctx context.Context, dueBy time.Time) (int, error) {
+	tx, err := cfg.store.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	due, err := tx.ListDueScheduledChirps(ctx, database.ListDueScheduledChirpsParams{
+		PublishBefore: dueBy,
+		PageLimit:     scheduledChirpsBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	posted := 0
+	for _, scheduled := range due {
+		_, err := tx.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{
+			ID:     scheduled.ID,
+			UserID: scheduled.UserID,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		moderated := cfg.moderation.Check(scheduled.Body)
+		if moderated.Rejected() {
+			slog.WarnContext(ctx, "Dropped scheduled chirp with a banned word", "scheduled_chirp_id", scheduled.ID, "user_id", scheduled.UserID)
+			continue
+		}
+		if _, err := createChirp(ctx, tx, scheduled.UserID, scheduled.InReplyTo, moderated); err != nil {
+			return 0, err
+		}
+		posted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	cfg.metrics.ChirpsCreated.Add(float64(posted))
+	return posted, nil
+}
+
+// Periodically post the scheduled chirps that are due until the context is cancelled
+func (cfg *apiConfig) watchScheduledChirps(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := cfg.publishScheduledChirps(ctx, time.Now()); err != nil {
+				slog.ErrorContext(ctx, "Error posting scheduled chirps", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Handler to search chirps by content - GET /api/chirps/search
+// Supports ?q=, ?author_id=, ?since= and ?until= (RFC 3339), ?limit= and ?cursor= query params
+func (cfg *apiConfig) searchChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	// A bearer token is optional, and used to fill in liked_by_me and show hidden chirps
+	viewerID, viewerRole, err := cfg.authenticateOptionalWithRole(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		respondWithError(w, 400, "Missing search query q")
+		return
+	}
+
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	// Optional filters
+	var authorID uuid.NullUUID
+	if authorParam := r.URL.Query().Get("author_id"); authorParam != "" {
+		id, err := uuid.Parse(authorParam)
+		if err != nil {
+			respondWithError(w, 400, "Invalid author_id")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	since, err := parseTimeParam(r, "since")
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	until, err := parseTimeParam(r, "until")
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	// Fetch one extra row so we know whether there is a next page
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	var cursorRank sql.NullFloat64
+	if page.Cursor != nil {
+		cursorRank = sql.NullFloat64{Float64: page.Cursor.Rank, Valid: true}
+	}
+	results, err := cfg.store.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Query:           query,
+		AuthorID:        authorID,
+		ViewerID:        viewerID,
+		IncludeHidden:   viewerRole.AtLeast(auth.RoleModerator),
+		Since:           since,
+		Until:           until,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorRank:      cursorRank,
+		CursorID:        cursorID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error searching chirps", "error", err)
+		respondWithError(w, 500, "Error searching chirps")
+		return
+	}
+
+	if len(results) > int(page.Limit) {
+		results = results[:page.Limit]
+		last := results[len(results)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.Chirp.CreatedAt, ID: last.Chirp.ID, Rank: float64(last.Rank)})
+	}
+
+	// Map search results to API chirp models
+	returnedChirps := make([]Chirp, 0, len(results))
+	for _, result := range results {
+		chirp := chirpFromDB(result.Chirp)
+		chirp.Snippet = result.Snippet
+		returnedChirps = append(returnedChirps, chirp)
+	}
+	err = cfg.setLikedByViewer(r.Context(), viewerID, returnedChirps)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving liked chirps", "error", err)
+		respondWithError(w, 500, "Error searching chirps")
+		return
+	}
+	respondWithJSON(w, 200, returnedChirps)
+}
+
+// Parse an optional RFC 3339 timestamp query parameter
+func parseTimeParam(r *http.Request, name string) (sql.NullTime, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	// Chirp timestamps are stored without a zone, so compare in UTC
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
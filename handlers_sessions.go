@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/frogonabike/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// How often to reload the denylist, picking up tokens revoked through other instances
+const denylistReloadInterval = 15 * time.Second
+
+// Add the denied tokens in the database to the denylist, first deleting the ones that have expired
+func (cfg *apiConfig) reloadDenylist(ctx context.Context) error {
+	if _, err := cfg.store.DeleteExpiredDeniedTokens(ctx); err != nil {
+		return err
+	}
+	rows, err := cfg.store.ListDeniedTokens(ctx)
+	if err != nil {
+		return err
+	}
+	entries := make(map[uuid.UUID]time.Time, len(rows))
+	for _, row := range rows {
+		entries[row.ID] = row.ExpiresAt
+	}
+	cfg.denylist.Load(entries)
+	return nil
+}
+
+// Periodically reload the denylist until the context is cancelled
+func (cfg *apiConfig) watchDenylist(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.reloadDenylist(ctx); err != nil {
+				slog.ErrorContext(ctx, "Error reloading denylist", "error", err)
+			}
+		}
+	}
+}
+
+// A denied token is kept on the list until every token it covers has expired
+// Sessions can have just been issued an access token, so they're denied for a full token lifetime
+type deniedTokens struct {
+	ids       []uuid.UUID
+	expiresAt time.Time
+}
+
+// Deny the access tokens of sessions
+func deniedSessions(sessionIDs []uuid.UUID) deniedTokens {
+	return deniedTokens{ids: sessionIDs, expiresAt: time.Now().Add(auth.AccessTokenLifetime)}
+}
+
+// Deny a single access token by its jti, if it has one
+func deniedAccessToken(claims *auth.Claims) deniedTokens {
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil || claims.ExpiresAt == nil {
+		return deniedTokens{}
+	}
+	return deniedTokens{ids: []uuid.UUID{tokenID}, expiresAt: claims.ExpiresAt.Time}
+}
+
+// Save denied tokens to the database, through a transaction's queries to deny them along with its other changes
+func saveDeniedTokens(ctx context.Context, q store.Queries, denied ...deniedTokens) error {
+	for _, d := range denied {
+		for _, id := range d.ids {
+			err := q.DenyToken(ctx, database.DenyTokenParams{ID: id, ExpiresAt: d.expiresAt})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Add saved denied tokens to this instance's denylist, so they're rejected before the next reload
+// Call this once they're committed, so a rollback can't leave them denied here only
+func (cfg *apiConfig) applyDeniedTokens(denied ...deniedTokens) {
+	for _, d := range denied {
+		cfg.denylist.Add(d.expiresAt, d.ids...)
+	}
+}
+
+// Handler to list the user's active sessions, most recently used first - GET /api/users/me/sessions
+func (cfg *apiConfig) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, claims, err := cfg.authenticateClaims(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	rows, err := cfg.store.ListUserSessions(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving sessions", "error", err)
+		respondWithError(w, 500, "Error retrieving sessions")
+		return
+	}
+
+	// Map returned database rows to API session models, marking the one making this request
+	currentID, _ := claims.Session()
+	sessions := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, Session{
+			ID:         row.FamilyID,
+			SignedInAt: row.SignedInAt,
+			LastUsedAt: row.LastUsedAt,
+			ExpiresAt:  row.ExpiresAt,
+			UserAgent:  row.UserAgent,
+			IP:         row.Ip,
+			Current:    row.FamilyID == currentID,
+		})
+	}
+
+	// Response section
+	respondWithJSON(w, 200, sessions)
+}
+
+// Handler to sign out one of the user's sessions - DELETE /api/users/me/sessions/{sessionID}
+// Access tokens already issued for the session are denied too
+func (cfg *apiConfig) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// Extract sessionID from URL
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid session ID")
+		return
+	}
+
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error revoking session")
+		return
+	}
+	defer tx.Rollback()
+
+	revoked, err := tx.RevokeUserSession(r.Context(), database.RevokeUserSessionParams{
+		UserID:   uuid.NullUUID{UUID: userID, Valid: true},
+		FamilyID: sessionID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking session", "error", err)
+		respondWithError(w, 500, "Error revoking session")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, 404, "Session not found")
+		return
+	}
+	denied := deniedSessions([]uuid.UUID{sessionID})
+	if err := saveDeniedTokens(r.Context(), tx, denied); err != nil {
+		slog.ErrorContext(r.Context(), "Error denying session tokens", "error", err)
+		respondWithError(w, 500, "Error revoking session")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing session revocation", "error", err)
+		respondWithError(w, 500, "Error revoking session")
+		return
+	}
+	cfg.applyDeniedTokens(denied)
+
+	// Response section
+	w.WriteHeader(204)
+}
+
+// Handler to sign out all of the user's sessions - POST /api/logout-all
+// Their access tokens are denied, including the one making this request
+func (cfg *apiConfig) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, claims, err := cfg.authenticateClaims(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error revoking sessions")
+		return
+	}
+	defer tx.Rollback()
+
+	sessionIDs, err := tx.RevokeUserRTokens(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking sessions", "error", err)
+		respondWithError(w, 500, "Error revoking sessions")
+		return
+	}
+	denied := []deniedTokens{deniedSessions(sessionIDs), deniedAccessToken(claims)}
+	if err := saveDeniedTokens(r.Context(), tx, denied...); err != nil {
+		slog.ErrorContext(r.Context(), "Error denying session tokens", "error", err)
+		respondWithError(w, 500, "Error revoking sessions")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing session revocation", "error", err)
+		respondWithError(w, 500, "Error revoking sessions")
+		return
+	}
+	cfg.applyDeniedTokens(denied...)
+
+	// Response section
+	w.WriteHeader(204)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/frogonabike/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// Trending tag defaults for GET /api/tags/trending
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 30 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+	maxTrendingLimit      = 100
+)
+
+// Store the #hashtags and @mentions found in a chirp body
+// Any existing ones are replaced, so this is also used when a chirp is edited
+// Tags the chirp already had are kept as they were, so editing an old chirp doesn't make its tags trend again
+func saveChirpTagsAndMentions(ctx context.Context, q store.Queries, chirpID uuid.UUID, body string) error {
+	tagIDs := []uuid.UUID{}
+	if tags := extractHashtags(body); len(tags) > 0 {
+		var err error
+		tagIDs, err = q.UpsertTags(ctx, tags)
+		if err != nil {
+			return err
+		}
+	}
+	if err := q.DeleteStaleChirpTags(ctx, database.DeleteStaleChirpTagsParams{ChirpID: chirpID, KeepTagIds: tagIDs}); err != nil {
+		return err
+	}
+	if len(tagIDs) > 0 {
+		err := q.AddChirpTags(ctx, database.AddChirpTagsParams{ChirpID: chirpID, TagIds: tagIDs})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := q.DeleteChirpMentions(ctx, chirpID); err != nil {
+		return err
+	}
+
+	if names := extractMentions(body); len(names) > 0 {
+		userIDs, err := resolveMentions(ctx, q, names)
+		if err != nil {
+			return err
+		}
+		if len(userIDs) > 0 {
+			err = q.AddChirpMentions(ctx, database.AddChirpMentionsParams{ChirpID: chirpID, UserIds: userIDs})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve mentioned names to user IDs
+// A matching handle wins, otherwise the email local part is used if only one user has it
+func resolveMentions(ctx context.Context, q store.Queries, names []string) ([]uuid.UUID, error) {
+	candidates, err := q.FindMentionCandidates(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	byHandle := map[string]uuid.UUID{}
+	byLocalPart := map[string][]uuid.UUID{}
+	for _, candidate := range candidates {
+		if candidate.Handle.Valid {
+			byHandle[strings.ToLower(candidate.Handle.String)] = candidate.ID
+		}
+		localPart, _, _ := strings.Cut(candidate.Email, "@")
+		localPart = strings.ToLower(localPart)
+		byLocalPart[localPart] = append(byLocalPart[localPart], candidate.ID)
+	}
+
+	userIDs := []uuid.UUID{}
+	for _, name := range names {
+		if id, ok := byHandle[name]; ok {
+			userIDs = append(userIDs, id)
+		} else if ids := byLocalPart[name]; len(ids) == 1 {
+			userIDs = append(userIDs, ids[0])
+		}
+	}
+	return userIDs, nil
+}
+
+// Handler to return chirps with a hashtag - GET /api/tags/{tag}/chirps
+func (cfg *apiConfig) getTagChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	// A bearer token is optional, and lets authors and moderators see hidden chirps
+	viewerID, viewerRole, err := cfg.authenticateOptionalWithRole(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// Tags are stored lowercased and without the #
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	// Fetch one extra row so we know whether there is a next page
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	chirps, err := cfg.store.ListTagChirps(r.Context(), database.ListTagChirpsParams{
+		Tag:             tag,
+		ViewerID:        viewerID,
+		IncludeHidden:   viewerRole.AtLeast(auth.RoleModerator),
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving chirps by tag", "error", err)
+		respondWithError(w, 500, "Error retrieving chirps")
+		return
+	}
+
+	if len(chirps) > int(page.Limit) {
+		chirps = chirps[:page.Limit]
+		last := chirps[len(chirps)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	// Map database chirps to API chirp models
+	returnedChirps := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		returnedChirps = append(returnedChirps, chirpFromDB(chirp))
+	}
+	respondWithJSON(w, 200, returnedChirps)
+}
+
+// Handler to return the most used tags - GET /api/tags/trending
+// Supports ?window= (a Go duration such as 6h, default 24h) and ?limit= query params
+func (cfg *apiConfig) trendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if windowParam := r.URL.Query().Get("window"); windowParam != "" {
+		d, err := time.ParseDuration(windowParam)
+		if err != nil || d <= 0 || d > maxTrendingWindow {
+			respondWithError(w, 400, "window must be a duration between 1s and 720h")
+			return
+		}
+		window = d
+	}
+
+	limit := defaultTrendingLimit
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		n, err := strconv.Atoi(limitParam)
+		if err != nil || n < 1 {
+			respondWithError(w, 400, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxTrendingLimit)
+	}
+
+	tags, err := cfg.store.ListTrendingTags(r.Context(), database.ListTrendingTagsParams{
+		WindowSeconds: window.Seconds(),
+		TagLimit:      int32(limit),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving trending tags", "error", err)
+		respondWithError(w, 500, "Error retrieving trending tags")
+		return
+	}
+
+	// Map database rows to API tag models
+	returnedTags := make([]TrendingTag, 0, len(tags))
+	for _, tag := range tags {
+		returnedTags = append(returnedTags, TrendingTag{
+			Tag:        tag.Name,
+			ChirpCount: tag.ChirpCount,
+		})
+	}
+	respondWithJSON(w, 200, returnedTags)
+}
+
+// Handler to return chirps mentioning the user - GET /api/users/me/mentions
+func (cfg *apiConfig) mentionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract and validate JWT from Authorization header
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	// Fetch one extra row so we know whether there is a next page
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	chirps, err := cfg.store.ListMentions(r.Context(), database.ListMentionsParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving mentions", "error", err)
+		respondWithError(w, 500, "Error retrieving mentions")
+		return
+	}
+
+	if len(chirps) > int(page.Limit) {
+		chirps = chirps[:page.Limit]
+		last := chirps[len(chirps)-1]
+		setNextPageLink(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	// Map database chirps to API chirp models
+	returnedChirps := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		returnedChirps = append(returnedChirps, chirpFromDB(chirp))
+	}
+	respondWithJSON(w, 200, returnedChirps)
+}
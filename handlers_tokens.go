@@ -1,13 +1,20 @@
 package main
 
 import (
-	"log"
+	"database/sql"
+	"errors"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
 )
 
 // refreshToken handler - POST /api/refresh
+// Every refresh replaces the refresh token with a new one in the same family
+// A revoked token coming back means it was copied, so the whole family is revoked and its access tokens denied
 func (cfg *apiConfig) tokenRefreshHandler(w http.ResponseWriter, r *http.Request) {
 	// Request section
 	refreshToken, err := auth.GetBearerToken(r.Header)
@@ -16,31 +23,121 @@ func (cfg *apiConfig) tokenRefreshHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Validate refresh token in database
-	userID, err := cfg.dbQueries.GetUserFromRToken(r.Context(), refreshToken)
+	// Look up the refresh token, locking it until the new one is saved
+	tx, err := cfg.store.Begin(r.Context())
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	stored, err := tx.GetRTokenForUpdate(r.Context(), refreshToken)
+	if errors.Is(err, sql.ErrNoRows) {
 		respondWithError(w, 401, "Invalid or expired refresh token")
 		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving refresh token", "error", err)
+		respondWithError(w, 500, "Internal server error")
+		return
+	}
+	if stored.RevokedAt.Valid {
+		slog.WarnContext(r.Context(), "Revoked refresh token reused, revoking its family", "user_id", stored.UserID.UUID, "family_id", stored.FamilyID)
+		if err := tx.RevokeRTokenFamily(r.Context(), stored.FamilyID); err != nil {
+			slog.ErrorContext(r.Context(), "Error revoking refresh token family", "error", err)
+			respondWithError(w, 500, "Internal server error")
+			return
+		}
+		denied := deniedSessions([]uuid.UUID{stored.FamilyID})
+		if err := saveDeniedTokens(r.Context(), tx, denied); err != nil {
+			slog.ErrorContext(r.Context(), "Error denying session tokens", "error", err)
+			respondWithError(w, 500, "Internal server error")
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing transaction", "error", err)
+			respondWithError(w, 500, "Internal server error")
+			return
+		}
+		cfg.applyDeniedTokens(denied)
+		respondWithError(w, 401, "Invalid or expired refresh token")
+		return
+	}
+	if !stored.ExpiresAt.After(time.Now()) {
+		respondWithError(w, 401, "Invalid or expired refresh token")
+		return
+	}
+	userID := stored.UserID
+
+	// Suspending a user revokes their refresh tokens, but check in case one slipped through
+	access, err := tx.GetUserAccess(r.Context(), userID.UUID)
+	if err != nil {
+		respondWithError(w, 401, "Invalid or expired refresh token")
+		return
+	}
+	if access.SuspendedAt.Valid {
+		respondWithError(w, 403, errSuspended.Error())
+		return
+	}
+
+	// The new token picks up any change to the user's role
+	role, err := auth.ParseRole(access.Role)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading user role", "error", err)
+		respondWithError(w, 500, "Internal server error")
+		return
+	}
+	newJWT, err := auth.MakeSessionJWT(userID.UUID, role, stored.FamilyID, cfg.jwtSecret)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating JWT", "error", err)
+		respondWithError(w, 500, "Internal server error")
+		return
 	}
 
-	newJWT, err := auth.MakeJWT(userID.UUID, cfg.jwtSecret)
+	// Rotate the refresh token
+	newRefreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		log.Printf("Error creating JWT: %s", err)
+		slog.ErrorContext(r.Context(), "Error creating refresh token", "error", err)
+		respondWithError(w, 500, "Internal server error")
+		return
+	}
+	if _, err := tx.RevokeRToken(r.Context(), refreshToken); err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking refresh token", "error", err)
+		respondWithError(w, 500, "Internal server error")
+		return
+	}
+	_, err = tx.CreateRToken(r.Context(), database.CreateRTokenParams{
+		Token:     newRefreshToken,
+		UserID:    userID,
+		FamilyID:  stored.FamilyID,
+		UserAgent: r.UserAgent(),
+		Ip:        cfg.clientIP(r),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating refresh token", "error", err)
+		respondWithError(w, 500, "Internal server error")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing transaction", "error", err)
 		respondWithError(w, 500, "Internal server error")
 		return
 	}
 
 	// Response section
 	type response struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 	resp := response{
-		Token: newJWT,
+		Token:        newJWT,
+		RefreshToken: newRefreshToken,
 	}
 	respondWithJSON(w, 200, resp)
 }
 
 // revokeRefreshToken handler - POST /api/revoke
+// Signs out the token's session, denying the access tokens issued for it
 func (cfg *apiConfig) revokeRefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 	// Request section
 	refreshToken, err := auth.GetBearerToken(r.Header)
@@ -50,11 +147,32 @@ func (cfg *apiConfig) revokeRefreshTokenHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Revoke refresh token in database
-	err = cfg.dbQueries.RevokeRToken(r.Context(), refreshToken)
+	tx, err := cfg.store.Begin(r.Context())
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
 		respondWithError(w, 500, "Error revoking refresh token")
 		return
 	}
+	defer tx.Rollback()
+
+	sessionIDs, err := tx.RevokeRToken(r.Context(), refreshToken)
+	if err != nil {
+		respondWithError(w, 500, "Error revoking refresh token")
+		return
+	}
+	denied := deniedSessions(sessionIDs)
+	if err := saveDeniedTokens(r.Context(), tx, denied); err != nil {
+		slog.ErrorContext(r.Context(), "Error denying session tokens", "error", err)
+		respondWithError(w, 500, "Error revoking refresh token")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing transaction", "error", err)
+		respondWithError(w, 500, "Error revoking refresh token")
+		return
+	}
+	cfg.applyDeniedTokens(denied)
+
 	// Response section
 	respondWithJSON(w, 204, "")
 }
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/frogonabike/chirpy/internal/auth"
@@ -16,13 +19,14 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
+		slog.ErrorContext(r.Context(), "Error decoding parameters", "error", err)
 		w.WriteHeader(500)
 		return
 	}
@@ -34,10 +38,17 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Validate the optional handle
+	handle, err := cfg.validateHandle(r.Context(), params.Handle, uuid.Nil)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
 	// Hash the password
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
-		log.Printf("Error hashing password: %s", err)
+		slog.ErrorContext(r.Context(), "Error hashing password", "error", err)
 		respondWithError(w, 500, "Internal server error")
 		return
 	}
@@ -46,10 +57,11 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 	dbParams := database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
+		Handle:         handle,
 	}
 
 	// Create user in database
-	newUser, err := cfg.dbQueries.CreateUser(r.Context(), dbParams)
+	newUser, err := cfg.store.CreateUser(r.Context(), dbParams)
 	if err != nil {
 		respondWithError(w, 400, "Error creating user")
 		return
@@ -62,6 +74,7 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 		UpdatedAt: newUser.UpdatedAt,
 		Email:     newUser.Email,
 		ChirpyRed: newUser.IsChirpyRed,
+		Handle:    newUser.Handle.String,
 	}
 
 	// Response section
@@ -83,7 +96,7 @@ func (cfg *apiConfig) userLoginHandler(w http.ResponseWriter, r *http.Request) {
 	params := parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
+		slog.ErrorContext(r.Context(), "Error decoding parameters", "error", err)
 		w.WriteHeader(500)
 		return
 	}
@@ -96,17 +109,25 @@ func (cfg *apiConfig) userLoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Retrieve user from database
-	user, err := cfg.dbQueries.UserLogin(r.Context(), params.Email)
+	user, err := cfg.store.UserLogin(r.Context(), params.Email)
 	if err != nil {
+		cfg.metrics.Logins.WithLabelValues("failed").Inc()
 		respondWithError(w, 401, "incorrect email or password")
 		return
 	}
 	// Verify password
 	match, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
 	if err != nil || !match {
+		cfg.metrics.Logins.WithLabelValues("failed").Inc()
 		respondWithError(w, 401, "incorrect email or password")
 		return
 	}
+	// Suspended users can't log in
+	if user.SuspendedAt.Valid {
+		cfg.metrics.Logins.WithLabelValues("failed").Inc()
+		respondWithError(w, 403, errSuspended.Error())
+		return
+	}
 
 	// Check and set token expiry
 	// if params.Expiry <= 0 || params.Expiry > 3600 {
@@ -115,33 +136,45 @@ func (cfg *apiConfig) userLoginHandler(w http.ResponseWriter, r *http.Request) {
 	// 	expiryTime = time.Duration(params.Expiry) * time.Second
 	// }
 
-	// Create JWT token
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret)
+	// Create JWT token carrying the user's role
+	role, err := auth.ParseRole(user.Role)
 	if err != nil {
-		log.Printf("Error creating JWT: %s", err)
+		slog.ErrorContext(r.Context(), "Error reading user role", "error", err)
+		respondWithError(w, 500, "Internal server error")
+		return
+	}
+	// Each login starts a new session, a family of refresh tokens that refreshes carry on
+	sessionID := uuid.New()
+	token, err := auth.MakeSessionJWT(user.ID, role, sessionID, cfg.jwtSecret)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating JWT", "error", err)
 		respondWithError(w, 500, "Internal server error")
 		return
 	}
 	// Create refresh token
 	refreshtoken, err := auth.MakeRefreshToken()
 	if err != nil {
-		log.Printf("Error creating refresh token: %s", err)
+		slog.ErrorContext(r.Context(), "Error creating refresh token", "error", err)
 		respondWithError(w, 500, "Internal server error")
 		return
 	}
 	// Create refresh token db record
-	// dbParams for refresh token
 	dbParams := database.CreateRTokenParams{
-		Token:  refreshtoken,
-		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Token:     refreshtoken,
+		UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+		FamilyID:  sessionID,
+		UserAgent: r.UserAgent(),
+		Ip:        cfg.clientIP(r),
 	}
-	_, err = cfg.dbQueries.CreateRToken(r.Context(), dbParams)
+	_, err = cfg.store.CreateRToken(r.Context(), dbParams)
 	if err != nil {
-		log.Printf("Error creating refresh token: %s", err)
+		slog.ErrorContext(r.Context(), "Error creating refresh token", "error", err)
 		respondWithError(w, 500, "Internal server error")
 		return
 	}
 
+	cfg.metrics.Logins.WithLabelValues("succeeded").Inc()
+
 	// Map returned database user model to API user model
 	loggedInUser := User{
 		ID:           user.ID,
@@ -151,6 +184,8 @@ func (cfg *apiConfig) userLoginHandler(w http.ResponseWriter, r *http.Request) {
 		Token:        token,
 		RefreshToken: refreshtoken,
 		ChirpyRed:    user.IsChirpyRed,
+		Handle:       user.Handle.String,
+		Role:         user.Role,
 	}
 
 	// Response section
@@ -163,20 +198,14 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 	type parameters struct {
 		Email       string `json:"email"`
 		NewPassword string `json:"new_password"`
+		Handle      string `json:"handle"`
 	}
 
-	// Extract JWT from Authorization header
-	token, err := auth.GetBearerToken(r.Header)
+	// Extract and validate JWT from Authorization header
+	userID, claims, err := cfg.authenticateClaims(r)
 	if err != nil {
-		log.Printf("Malformed or missing access token: %s", err)
-		respondWithError(w, 401, "Missing or invalid Authorization header")
-	}
-
-	// Validate JWT and extra user ID
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		log.Printf("Invalid access token: %s", err)
-		respondWithError(w, 401, "Invalid token")
+		respondWithAuthError(w, err)
+		return
 	}
 
 	// Decode request body
@@ -184,30 +213,69 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 	params := parameters{}
 	err = decoder.Decode(&params)
 	if err != nil {
-		log.Printf("Error extracting ID from token: %s", err)
-		respondWithError(w, 401, "Invalid token")
+		respondWithError(w, 400, "Error decoding request body")
+		return
 	}
 	defer r.Body.Close()
 
+	// Validate the new handle, if one was given
+	handle, err := cfg.validateHandle(r.Context(), params.Handle, userID)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
 	// Hash the new password
 	hashedPassword, err := auth.HashPassword(params.NewPassword)
 	if err != nil {
-		log.Printf("Error hashing password: %s", err)
+		slog.ErrorContext(r.Context(), "Error hashing password", "error", err)
 		respondWithError(w, 500, "Internal server error")
 		return
 	}
 
-	// Update user in database
-	updatedUser, err := cfg.dbQueries.UpdateUser(r.Context(), database.UpdateUserParams{
+	// Update user in database, signing out every other session as the password has changed
+	// Tokens without a session revoke them all, and the other sessions' access tokens are denied
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error updating user")
+		return
+	}
+	defer tx.Rollback()
+
+	updatedUser, err := tx.UpdateUser(r.Context(), database.UpdateUserParams{
 		ID:             userID,
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
+		Handle:         handle,
 	})
 	if err != nil {
-		log.Printf("Error updating user: %s", err)
+		slog.ErrorContext(r.Context(), "Error updating user", "error", err)
 		respondWithError(w, 500, "Error updating user")
 		return
 	}
+	sessionID, _ := claims.Session()
+	otherSessionIDs, err := tx.RevokeOtherUserRTokens(r.Context(), database.RevokeOtherUserRTokensParams{
+		UserID:       uuid.NullUUID{UUID: userID, Valid: true},
+		KeepFamilyID: sessionID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking other sessions", "error", err)
+		respondWithError(w, 500, "Error updating user")
+		return
+	}
+	denied := deniedSessions(otherSessionIDs)
+	if err := saveDeniedTokens(r.Context(), tx, denied); err != nil {
+		slog.ErrorContext(r.Context(), "Error denying other sessions' tokens", "error", err)
+		respondWithError(w, 500, "Error updating user")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing user update", "error", err)
+		respondWithError(w, 500, "Error updating user")
+		return
+	}
+	cfg.applyDeniedTokens(denied)
 
 	// Map returned database user model to API user model
 	user := User{
@@ -216,9 +284,30 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		UpdatedAt: updatedUser.UpdatedAt,
 		Email:     updatedUser.Email,
 		ChirpyRed: updatedUser.IsChirpyRed,
+		Handle:    updatedUser.Handle.String,
 	}
 
 	// Response section
 	respondWithJSON(w, 200, user)
 
 }
+
+// Check a requested handle is well formed and not used by anyone but userID
+// An empty handle is allowed and returned as NULL
+func (cfg *apiConfig) validateHandle(ctx context.Context, handle string, userID uuid.UUID) (sql.NullString, error) {
+	if handle == "" {
+		return sql.NullString{}, nil
+	}
+	if !handlePattern.MatchString(handle) {
+		return sql.NullString{}, errors.New("Handle must be 1-30 letters, digits or underscores")
+	}
+
+	existing, err := cfg.store.GetUserByHandle(ctx, handle)
+	if err == nil && existing.ID != userID {
+		return sql.NullString{}, errors.New("Handle is already taken")
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.ErrorContext(ctx, "Error checking handle", "error", err)
+		return sql.NullString{}, errors.New("Error checking handle")
+	}
+	return sql.NullString{String: handle, Valid: true}, nil
+}
@@ -2,54 +2,161 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/frogonabike/chirpy/internal/auth"
-	"github.com/google/uuid"
+	"github.com/frogonabike/chirpy/internal/database"
 )
 
+// How far a webhook's timestamp can be from now, allowing for clock skew and delivery delays
+const webhookTolerance = 5 * time.Minute
+
+// Largest webhook body read, as the whole body is held in memory to check its signature
+const maxWebhookBodyBytes = 64 << 10
+
+// Headers Polka signs webhooks with - see auth.SignWebhook
+const (
+	polkaTimestampHeader = "X-Polka-Timestamp"
+	polkaSignatureHeader = "X-Polka-Signature"
+)
+
+// Results recorded for Polka events, matching the CHECK constraint on webhook_events.result
+const (
+	webhookEventProcessed = "processed"
+	webhookEventIgnored   = "ignored"
+)
+
+// Handler for Polka subscription events - POST /api/polka/webhooks
+// Each event is processed once, so redelivered events are acknowledged without doing anything
 func (cfg *apiConfig) webhookHandler(w http.ResponseWriter, r *http.Request) {
 	// Request section
 
-	// Check API key header
-	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil || apiKey != cfg.polkaKey {
-		respondWithError(w, 401, "Missing or invalid API key")
+	// Read the raw body, which the signature covers
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		cfg.metrics.Webhooks.WithLabelValues("unknown", "invalid").Inc()
+		respondWithError(w, 413, "Request body too large")
+		return
+	} else if err != nil {
+		cfg.metrics.Webhooks.WithLabelValues("unknown", "invalid").Inc()
+		respondWithError(w, 400, "Error reading request body")
+		return
+	}
+
+	// Check the signature and its timestamp
+	err = auth.VerifyWebhookSignature(body, r.Header.Get(polkaTimestampHeader), r.Header.Get(polkaSignatureHeader), cfg.polkaKeys, webhookTolerance)
+	if err != nil {
+		cfg.metrics.Webhooks.WithLabelValues("unknown", "unauthorized").Inc()
+		respondWithError(w, 401, "Missing or invalid signature")
 		return
 	}
 
 	// Define expected parameters
 	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
+		ID    string         `json:"id"`
+		Event string         `json:"event"`
+		Data  polkaEventData `json:"data"`
 	}
 
 	// Decode request body
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err = json.Unmarshal(body, &params)
 	if err != nil {
-		respondWithError(w, 500, "Error decoding request body")
+		cfg.metrics.Webhooks.WithLabelValues("unknown", "invalid").Inc()
+		respondWithError(w, 400, "Error decoding request body")
+		return
+	}
+	if params.ID == "" {
+		cfg.metrics.Webhooks.WithLabelValues("unknown", "invalid").Inc()
+		respondWithError(w, 400, "Missing event ID")
 		return
 	}
-	defer r.Body.Close()
 
-	// Process webhook event
-	switch params.Event {
-	case "user.upgraded":
-		// Upgrade user to Chirpy Red in database
-		err := cfg.dbQueries.UpgradeUserToChirpyRed(r.Context(), params.Data.UserID)
+	// Events we don't handle are acknowledged, so Polka stops retrying them
+	// They're recorded as ignored, and would still be processed if support is added and Polka resends them
+	err = checkSubscriptionEvent(params.Event, params.Data)
+	if errors.Is(err, errUnsupportedEvent) {
+		_, err := cfg.store.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+			ID:     params.ID,
+			Event:  params.Event,
+			Result: webhookEventIgnored,
+		})
 		if err != nil {
-			respondWithJSON(w, 404, "")
+			slog.ErrorContext(r.Context(), "Error recording webhook event", "error", err)
+			respondWithError(w, 500, "Error processing webhook")
 			return
 		}
-		respondWithJSON(w, 204, "Processed ok")
+		// Counted together to keep the label set small
+		cfg.metrics.Webhooks.WithLabelValues("other", "unsupported").Inc()
+		w.WriteHeader(204)
+		return
+	} else if err != nil {
+		cfg.metrics.Webhooks.WithLabelValues(params.Event, "invalid").Inc()
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	// Record the event and process it together, so a failure leaves it to be redelivered
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, 500, "Error processing webhook")
+		return
+	}
+	defer tx.Rollback()
+
+	recorded, err := tx.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+		ID:     params.ID,
+		Event:  params.Event,
+		Result: webhookEventProcessed,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error recording webhook event", "error", err)
+		respondWithError(w, 500, "Error processing webhook")
+		return
+	}
+	if recorded == 0 {
+		cfg.metrics.Webhooks.WithLabelValues(params.Event, "duplicate").Inc()
+		w.WriteHeader(204)
+		return
+	}
+
+	// Process webhook event, updating the user's subscription
+	found, err := applySubscriptionEvent(r.Context(), tx, params.Event, params.Data)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating subscription", "error", err)
+		respondWithError(w, 500, "Error processing webhook")
+		return
+	}
+	if !found {
+		cfg.metrics.Webhooks.WithLabelValues(params.Event, "user_not_found").Inc()
+		if params.Event == polkaEventUserUpgraded {
+			respondWithError(w, 404, "User not found")
+		} else {
+			respondWithError(w, 404, "Subscription not found")
+		}
 		return
-	default:
-		respondWithJSON(w, 204, "No event")
+	}
+	if params.Event == polkaEventUserUpgraded {
+		err := queueWebhookEvent(r.Context(), tx, webhookEventUserUpgraded, params.Data.UserID, userUpgradedData{UserID: params.Data.UserID})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error queueing webhooks", "error", err)
+			respondWithError(w, 500, "Error processing webhook")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing webhook event", "error", err)
+		respondWithError(w, 500, "Error processing webhook")
 		return
 	}
+	cfg.metrics.Webhooks.WithLabelValues(params.Event, "processed").Inc()
 
+	// Response section
+	w.WriteHeader(204)
 }
@@ -1,10 +1,36 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"regexp"
 	"strings"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/entitlements"
+	"github.com/frogonabike/chirpy/internal/logging"
+	"github.com/frogonabike/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+// Patterns for #hashtags, @mentions and user handles
+// Tags and mentions must start a word, so emails like bob@example.com aren't mentions
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])#([\p{L}\p{N}_]+)`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])@([\p{L}\p{N}_.+-]+)`)
+	handlePattern  = regexp.MustCompile(`^[A-Za-z0-9_]{1,30}$`)
+)
+
+// Errors returned by authenticate, worded for the API response
+var (
+	errMissingToken = errors.New("Missing or invalid Authorization header")
+	errInvalidToken = errors.New("Invalid token")
+	errSuspended    = errors.New("Account suspended")
 )
 
 type returnVals struct {
@@ -19,7 +45,7 @@ func respondWithError(w http.ResponseWriter, code int, msg string) {
 	}
 	dat, err := json.Marshal(respBody)
 	if err != nil {
-		log.Printf("Something went wrong")
+		slog.Error("Error encoding response", "error", err)
 		w.WriteHeader(400)
 		return
 	}
@@ -33,7 +59,7 @@ func respondWithError(w http.ResponseWriter, code int, msg string) {
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Something went wrong")
+		slog.Error("Error encoding response", "error", err)
 		w.WriteHeader(400)
 		return
 	}
@@ -42,17 +68,167 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(dat)
 }
 
-// Profanity filter function
-func profanityFilter(body string) string {
-	// List of bad words to filter, can be expanded easily
-	badWords := []string{"kerfuffle", "sharbert", "fornax"}
-	cleanedBody := strings.Split(body, " ")
-	for i, bodyWord := range cleanedBody {
-		for _, badWord := range badWords {
-			if strings.ToLower(bodyWord) == badWord {
-				cleanedBody[i] = "****"
-			}
+// Helper function to extract and validate the JWT from the Authorization header
+// Returns the ID of the authenticated user, who must not be suspended
+func (cfg *apiConfig) authenticate(r *http.Request) (uuid.UUID, error) {
+	userID, _, err := cfg.authenticateWithRole(r)
+	return userID, err
+}
+
+// Helper function like authenticate that also returns the role claim from the JWT
+func (cfg *apiConfig) authenticateWithRole(r *http.Request) (uuid.UUID, auth.Role, error) {
+	userID, claims, err := cfg.authenticateClaims(r)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return userID, claims.Role, nil
+}
+
+// Helper function like authenticate that also returns all the JWT claims
+func (cfg *apiConfig) authenticateClaims(r *http.Request) (uuid.UUID, *auth.Claims, error) {
+	jwtToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, nil, errMissingToken
+	}
+	claims, err := auth.ValidateJWTClaims(jwtToken, cfg.jwtSecret, cfg.denylist)
+	if err != nil {
+		return uuid.Nil, nil, errInvalidToken
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, nil, errInvalidToken
+	}
+	logging.SetUserID(r.Context(), userID)
+
+	// Tokens issued before a suspension stay valid until they expire, so check every time
+	suspended, err := cfg.store.IsUserSuspended(r.Context(), userID)
+	if err != nil {
+		// Also covers tokens for users that no longer exist
+		return uuid.Nil, nil, errInvalidToken
+	}
+	if suspended {
+		return uuid.Nil, nil, errSuspended
+	}
+	return userID, claims, nil
+}
+
+// Helper function to respond to a failed authenticate call
+// Suspended users are forbidden, anything else is unauthorized
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errSuspended) {
+		respondWithError(w, 403, err.Error())
+		return
+	}
+	respondWithError(w, 401, err.Error())
+}
+
+// Helper function for endpoints where logging in is optional
+// Returns a NULL user ID when no Authorization header was sent
+func (cfg *apiConfig) authenticateOptional(r *http.Request) (uuid.NullUUID, error) {
+	viewerID, _, err := cfg.authenticateOptionalWithRole(r)
+	return viewerID, err
+}
+
+// Helper function like authenticateOptional that also returns the viewer's role
+// Anonymous viewers have no role
+func (cfg *apiConfig) authenticateOptionalWithRole(r *http.Request) (uuid.NullUUID, auth.Role, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.NullUUID{}, "", nil
+	}
+	userID, role, err := cfg.authenticateWithRole(r)
+	if err != nil {
+		return uuid.NullUUID{}, "", err
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}, role, nil
+}
+
+// Helper function to look up what a user's plan entitles them to
+// Chirpy Red is read from the database rather than the JWT, so changes apply straight away
+func (cfg *apiConfig) userPlan(ctx context.Context, userID uuid.UUID) (entitlements.Plan, error) {
+	user, err := cfg.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return entitlements.Plan{}, err
+	}
+	return entitlements.For(user.IsChirpyRed), nil
+}
+
+// Helper function to validate a chirp body against the plan's length limit and the moderation rules
+// The result's Text holds the body with any banned words masked
+func (cfg *apiConfig) validateChirpBody(body string, plan entitlements.Plan) (moderation.Result, error) {
+	if len(body) > plan.MaxChirpLength {
+		return moderation.Result{}, errors.New("Chirp is too long")
+	}
+	result := cfg.moderation.Check(body)
+	if result.Rejected() {
+		return result, errors.New("Chirp contains a banned word")
+	}
+	return result, nil
+}
+
+// Extract the distinct #hashtags from a chirp body, lowercased and without the #
+func extractHashtags(body string) []string {
+	return extractDistinct(hashtagPattern, body)
+}
+
+// Extract the distinct @mentions from a chirp body, lowercased and without the @
+func extractMentions(body string) []string {
+	return extractDistinct(mentionPattern, body)
+}
+
+// Shared body of extractHashtags and extractMentions
+func extractDistinct(pattern *regexp.Regexp, body string) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, match := range pattern.FindAllStringSubmatch(body, -1) {
+		// Drop punctuation that ends a sentence, e.g. "thanks @bob."
+		name := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// Helper function to get the client's IP address, for recording where sessions were used and rate limiting
+// Behind proxies listed in TRUSTED_PROXIES, this is the right-most X-Forwarded-For address that isn't one of them,
+// since addresses to its left were sent by the client and can't be trusted
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !cfg.trustedProxy(addr) {
+		return host
+	}
+
+	hops := []string{}
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Stop at anything that isn't an address, rather than trusting what the client sent
+			break
+		}
+		addr = hop.Unmap()
+		if !cfg.trustedProxy(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+// Whether an address is one of the proxies in TRUSTED_PROXIES
+func (cfg *apiConfig) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range cfg.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
 		}
 	}
-	return strings.Join(cleanedBody, " ")
+	return false
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"none", "just a chirp", []string{}},
+		{"single", "loving #golang today", []string{"golang"}},
+		{"lowercased and distinct", "#Go #go #GO", []string{"go"}},
+		{"punctuation", "(#chirpy), #bootdev!", []string{"chirpy", "bootdev"}},
+		{"not mid word", "issue#42 isn't a tag", []string{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := extractHashtags(tc.body)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"none", "just a chirp", []string{}},
+		{"handle", "hey @Frog_On_A_Bike", []string{"frog_on_a_bike"}},
+		{"email local part", "cc @jane.doe.", []string{"jane.doe"}},
+		{"not an email address", "mail bob@example.com", []string{}},
+		{"distinct", "@bob @Bob @alice", []string{"bob", "alice"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := extractMentions(tc.body)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -39,18 +40,93 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 	return match, nil
 }
 
+// User roles, from least to most privileged
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Rank of each role, used to compare them
+var roleRanks = map[Role]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// Function to parse a role name, as stored in the database
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return role, nil
+}
+
+// Reports whether the role grants at least the privileges of min
+// Unknown roles grant nothing
+func (r Role) AtLeast(min Role) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[min]
+}
+
+// How long access tokens are valid for
+const AccessTokenLifetime = time.Hour
+
+// Error for a valid token whose ID or session is on the denylist
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// JWT claims issued by chirpy
+type Claims struct {
+	jwt.RegisteredClaims
+	Role      Role   `json:"role,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+}
+
+// Session the token was issued for, if it was issued at login or refresh
+func (c *Claims) Session() (uuid.UUID, bool) {
+	sessionID, err := uuid.Parse(c.SessionID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return sessionID, true
+}
+
 // Function to create a JWT token for a given user ID
+// The token carries the plain user role
 func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
+	return MakeJWTWithRole(userID, RoleUser, tokenSecret)
+}
+
+// Function to create a JWT token for a given user ID and role
+func MakeJWTWithRole(userID uuid.UUID, role Role, tokenSecret string) (string, error) {
+	return makeJWT(userID, role, "", tokenSecret)
+}
+
+// Function to create a JWT token for a user's login session
+// The session ID is the refresh token family the token was issued with
+func MakeSessionJWT(userID uuid.UUID, role Role, sessionID uuid.UUID, tokenSecret string) (string, error) {
+	return makeJWT(userID, role, sessionID.String(), tokenSecret)
+}
+
+func makeJWT(userID uuid.UUID, role Role, sessionID string, tokenSecret string) (string, error) {
 
 	// Define the signing key
 	mySigningKey := []byte(tokenSecret)
 
-	// Create the JWT claims, which includes the user ID and expiry time
-	claims := &jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		Subject:   userID.String(),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	// Create the JWT claims, which includes the token ID, user ID, role, session and expiry time
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    "chirpy",
+			Subject:   userID.String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenLifetime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		Role:      role,
+		SessionID: sessionID,
 	}
 	// Create the token using the claims
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -64,23 +140,62 @@ func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
 }
 
 // Function to parse and validate a JWT token, returning the user ID if valid
-func ValidateJWT(tokenString string, tokenSecret string) (uuid.UUID, error) {
+// Pass a denylist to also reject revoked tokens
+func ValidateJWT(tokenString string, tokenSecret string, denylist ...*Denylist) (uuid.UUID, error) {
+	userID, _, err := ValidateJWTWithRole(tokenString, tokenSecret, denylist...)
+	return userID, err
+}
+
+// Function to parse and validate a JWT token, returning the user ID and role if valid
+// Tokens issued without a role claim are treated as belonging to a plain user
+func ValidateJWTWithRole(tokenString string, tokenSecret string, denylist ...*Denylist) (uuid.UUID, Role, error) {
+	claims, err := ValidateJWTClaims(tokenString, tokenSecret, denylist...)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return userID, claims.Role, nil
+}
+
+// Function to parse and validate a JWT token, returning all its claims if valid
+// A missing role claim is filled in as a plain user
+// With a denylist, tokens whose ID or session is on it fail with ErrTokenRevoked
+func ValidateJWTClaims(tokenString string, tokenSecret string, denylist ...*Denylist) (*Claims, error) {
 
 	// Define the signing key
 	mySigningKey := []byte(tokenSecret)
 
 	// Parse the token
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return mySigningKey, nil
 	})
 	if err != nil {
-		return uuid.Nil, err
-	} else if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok {
-		return uuid.Parse(claims.Subject)
-	} else {
-		return uuid.Nil, nil
+		return nil, err
 	}
 
+	if _, err := uuid.Parse(claims.Subject); err != nil {
+		return nil, err
+	}
+	for _, d := range denylist {
+		if tokenID, err := uuid.Parse(claims.ID); err == nil && d.Denied(tokenID) {
+			return nil, ErrTokenRevoked
+		}
+		if sessionID, ok := claims.Session(); ok && d.Denied(sessionID) {
+			return nil, ErrTokenRevoked
+		}
+	}
+	if claims.Role == "" {
+		claims.Role = RoleUser
+		return claims, nil
+	}
+	if _, err := ParseRole(string(claims.Role)); err != nil {
+		return nil, err
+	}
+	return claims, nil
 }
 
 // Function to extract Bearer token from HTTP headers
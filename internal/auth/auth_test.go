@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"errors"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -55,3 +58,184 @@ func TestJWTCreationAndValidation(t *testing.T) {
 		t.Errorf("Expected user ID %s, got %s", uid, returnedUserID)
 	}
 }
+
+func TestJWTRoleClaim(t *testing.T) {
+	uid := uuid.New()
+	tokenSecret := "testsecret"
+
+	token, err := MakeJWTWithRole(uid, RoleModerator, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %s", err)
+	}
+
+	returnedUserID, role, err := ValidateJWTWithRole(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error validating JWT: %s", err)
+	}
+	if returnedUserID != uid {
+		t.Errorf("Expected user ID %s, got %s", uid, returnedUserID)
+	}
+	if role != RoleModerator {
+		t.Errorf("Expected role %s, got %s", RoleModerator, role)
+	}
+
+	// Tokens from MakeJWT carry the plain user role
+	token, err = MakeJWT(uid, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %s", err)
+	}
+	_, role, err = ValidateJWTWithRole(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error validating JWT: %s", err)
+	}
+	if role != RoleUser {
+		t.Errorf("Expected role %s, got %s", RoleUser, role)
+	}
+}
+
+func TestJWTSessionClaim(t *testing.T) {
+	uid := uuid.New()
+	sessionID := uuid.New()
+	tokenSecret := "testsecret"
+
+	token, err := MakeSessionJWT(uid, RoleAdmin, sessionID, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %s", err)
+	}
+	claims, err := ValidateJWTClaims(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error validating JWT: %s", err)
+	}
+	if got, ok := claims.Session(); !ok || got != sessionID {
+		t.Errorf("Expected session %s, got %s", sessionID, got)
+	}
+	if claims.Role != RoleAdmin {
+		t.Errorf("Expected role %s, got %s", RoleAdmin, claims.Role)
+	}
+
+	// Tokens issued outside a session have no sid claim
+	token, err = MakeJWT(uid, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %s", err)
+	}
+	claims, err = ValidateJWTClaims(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error validating JWT: %s", err)
+	}
+	if _, ok := claims.Session(); ok {
+		t.Errorf("Expected no session, got %q", claims.SessionID)
+	}
+}
+
+func TestJWTDenylist(t *testing.T) {
+	uid := uuid.New()
+	sessionID := uuid.New()
+	tokenSecret := "testsecret"
+
+	token, err := MakeSessionJWT(uid, RoleUser, sessionID, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %s", err)
+	}
+	other, err := MakeSessionJWT(uid, RoleUser, uuid.New(), tokenSecret)
+	if err != nil {
+		t.Fatalf("Error creating JWT: %s", err)
+	}
+	claims, err := ValidateJWTClaims(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("Error validating JWT: %s", err)
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		t.Fatalf("Expected a UUID jti, got %q", claims.ID)
+	}
+
+	tests := []struct {
+		name    string
+		denied  uuid.UUID
+		expires time.Duration
+		wantErr bool
+	}{
+		{"nothing denied", uuid.New(), time.Hour, false},
+		{"token denied", tokenID, time.Hour, true},
+		{"session denied", sessionID, time.Hour, true},
+		{"denial expired", tokenID, -time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denylist := NewDenylist()
+			denylist.Add(time.Now().Add(tt.expires), tt.denied)
+			_, err := ValidateJWT(token, tokenSecret, denylist)
+			if tt.wantErr && !errors.Is(err, ErrTokenRevoked) {
+				t.Errorf("Expected ErrTokenRevoked, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected token to be valid, got %s", err)
+			}
+			if _, err := ValidateJWT(other, tokenSecret, denylist); err != nil {
+				t.Errorf("Expected other token to be valid, got %s", err)
+			}
+		})
+	}
+
+	// Loading keeps entries added meanwhile and drops expired ones
+	denylist := NewDenylist()
+	denylist.Add(time.Now().Add(time.Hour), sessionID)
+	denylist.Load(map[uuid.UUID]time.Time{tokenID: time.Now().Add(-time.Second)})
+	if !denylist.Denied(sessionID) || denylist.Denied(tokenID) {
+		t.Errorf("Expected only the session to be denied after loading")
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	keys := []string{"new-key", "old-key"}
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		body      []byte
+		timestamp string
+		signature string
+		wantErr   error
+	}{
+		{"current key", body, strconv.FormatInt(now, 10), SignWebhook("new-key", now, body), nil},
+		{"old key", body, strconv.FormatInt(now, 10), SignWebhook("old-key", now, body), nil},
+		{"unknown key", body, strconv.FormatInt(now, 10), SignWebhook("other-key", now, body), ErrInvalidSignature},
+		{"changed body", []byte(`{"id":"evt_2"}`), strconv.FormatInt(now, 10), SignWebhook("new-key", now, body), ErrInvalidSignature},
+		{"changed timestamp", body, strconv.FormatInt(now-1, 10), SignWebhook("new-key", now, body), ErrInvalidSignature},
+		{"too old", body, strconv.FormatInt(now-600, 10), SignWebhook("new-key", now-600, body), ErrSignatureExpired},
+		{"too far ahead", body, strconv.FormatInt(now+600, 10), SignWebhook("new-key", now+600, body), ErrSignatureExpired},
+		{"not hex", body, strconv.FormatInt(now, 10), "not-a-signature", ErrInvalidSignature},
+		{"missing signature", body, strconv.FormatInt(now, 10), "", ErrMissingSignature},
+		{"missing timestamp", body, "", SignWebhook("new-key", now, body), ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.body, tt.timestamp, tt.signature, keys, 5*time.Minute)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRoleAtLeast(t *testing.T) {
+	tests := []struct {
+		role Role
+		min  Role
+		want bool
+	}{
+		{RoleUser, RoleUser, true},
+		{RoleUser, RoleModerator, false},
+		{RoleModerator, RoleModerator, true},
+		{RoleModerator, RoleAdmin, false},
+		{RoleAdmin, RoleModerator, true},
+		{Role("owner"), RoleUser, false},
+	}
+
+	for _, tc := range tests {
+		if got := tc.role.AtLeast(tc.min); got != tc.want {
+			t.Errorf("Expected %s.AtLeast(%s) to be %v, got %v", tc.role, tc.min, tc.want, got)
+		}
+	}
+}
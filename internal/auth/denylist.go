@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Denylist holds the IDs of revoked access tokens and sessions, until the tokens they cover expire.
+// A token is denied if its jti or its sid is on the list.
+// It is safe for concurrent use.
+type Denylist struct {
+	mu      sync.RWMutex
+	entries map[uuid.UUID]time.Time // ID to when it can be forgotten
+}
+
+// Create an empty denylist
+func NewDenylist() *Denylist {
+	return &Denylist{entries: map[uuid.UUID]time.Time{}}
+}
+
+// Add IDs, keeping the later expiry for any that are already denied
+func (d *Denylist) Add(expiresAt time.Time, ids ...uuid.UUID) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, id := range ids {
+		if existing, ok := d.entries[id]; !ok || expiresAt.After(existing) {
+			d.entries[id] = expiresAt
+		}
+	}
+}
+
+// Add the entries stored in the database, and forget the ones that have expired
+// IDs are never taken off the list early, so entries added meanwhile are kept
+func (d *Denylist) Load(entries map[uuid.UUID]time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for id, expiresAt := range entries {
+		if existing, ok := d.entries[id]; !ok || expiresAt.After(existing) {
+			d.entries[id] = expiresAt
+		}
+	}
+	for id, expiresAt := range d.entries {
+		if !expiresAt.After(now) {
+			delete(d.entries, id)
+		}
+	}
+}
+
+// Reports whether a token or session ID is denied
+// Entries stop applying once they expire, and are dropped on the next Load
+func (d *Denylist) Denied(id uuid.UUID) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	expiresAt, ok := d.entries[id]
+	return ok && time.Now().Before(expiresAt)
+}
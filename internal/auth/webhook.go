@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Errors returned by VerifyWebhookSignature
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrSignatureExpired = errors.New("webhook timestamp is outside the tolerance window")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Function to sign a webhook body sent at a Unix timestamp
+// The signature is the hex HMAC-SHA256 of "timestamp.body", so the timestamp can't be changed either
+func SignWebhook(key string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Function to check a webhook's signature and timestamp against its raw body
+// Any of the keys is accepted, so a new key can be added before the old one is retired
+// The timestamp must be within tolerance of now, so captured requests can't be replayed later
+func VerifyWebhookSignature(body []byte, timestamp string, signature string, keys []string, tolerance time.Duration) error {
+	if timestamp == "" || signature == "" {
+		return ErrMissingSignature
+	}
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := time.Since(time.Unix(sentAt, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	for _, key := range keys {
+		want, _ := hex.DecodeString(SignWebhook(key, sentAt, body))
+		if hmac.Equal(got, want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Function to generate a random secret for signing outbound webhooks
+func MakeWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("make webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
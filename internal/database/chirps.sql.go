@@ -7,29 +7,48 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const adjustChirpLikeCount = `-- name: AdjustChirpLikeCount :exec
+UPDATE chirps
+SET like_count = like_count + $2::integer
+WHERE id = $1
+`
+
+type AdjustChirpLikeCountParams struct {
+	ID    uuid.UUID
+	Delta int32
+}
+
+func (q *Queries) AdjustChirpLikeCount(ctx context.Context, arg AdjustChirpLikeCountParams) error {
+	_, err := q.db.ExecContext(ctx, adjustChirpLikeCount, arg.ID, arg.Delta)
+	return err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to)
 VALUES (
    gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2 
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.NullUUID
+	Body      string
+	UserID    uuid.NullUUID
+	InReplyTo uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.InReplyTo)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,15 +56,23 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.HiddenAt,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    deleted_at = NOW()
 WHERE 
     id = $1
     AND user_id = $2
+    AND deleted_at IS NULL
 `
 
 type DeleteChirpParams struct {
@@ -53,18 +80,282 @@ type DeleteChirpParams struct {
 	UserID uuid.NullUUID
 }
 
+// Soft delete, so replies can still point at the chirp as a tombstone
 func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error {
 	_, err := q.db.ExecContext(ctx, deleteChirp, arg.ID, arg.UserID)
 	return err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT parent.id, parent.in_reply_to, 1
+    FROM chirps AS parent
+    WHERE parent.id = (SELECT start.in_reply_to FROM chirps AS start WHERE start.id = $1)
+    UNION ALL
+    SELECT c.id, c.in_reply_to, a.depth + 1
+    FROM chirps AS c
+    JOIN ancestors AS a ON c.id = a.in_reply_to
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+ORDER BY ancestors.depth DESC
+`
+
+// Walks up the in_reply_to chain, returning the root of the thread first
+func (q *Queries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants (id) AS (
+    SELECT reply.id
+    FROM chirps AS reply
+    WHERE reply.in_reply_to = $1::uuid
+    UNION ALL
+    SELECT c.id
+    FROM chirps AS c
+    JOIN descendants AS d ON c.in_reply_to = d.id
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at FROM chirps
+JOIN descendants ON chirps.id = descendants.id
+ORDER BY chirps.created_at ASC, chirps.id ASC
+`
+
+// Walks down every reply chain below a chirp, oldest replies first
+func (q *Queries) GetChirpDescendants(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = $1::uuid OR $2::boolean)
+    AND ($3::uuid IS NULL OR user_id = $3::uuid)
+    AND (
+        $4::timestamp IS NULL
+        OR (created_at, id) > ($4::timestamp, $5::uuid)
+    )
+ORDER BY created_at ASC, id ASC
+LIMIT $6
+`
+
+type ListChirpsAscParams struct {
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsByLikes = `-- name: ListChirpsByLikes :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = $1::uuid OR $2::boolean)
+    AND ($3::uuid IS NULL OR user_id = $3::uuid)
+    AND (
+        $4::timestamp IS NULL
+        OR (like_count, created_at, id) < ($5::integer, $4::timestamp, $6::uuid)
+    )
+ORDER BY like_count DESC, created_at DESC, id DESC
+LIMIT $7
+`
+
+type ListChirpsByLikesParams struct {
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorLikeCount sql.NullInt32
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+// Most liked chirps first, for the popular view
+func (q *Queries) ListChirpsByLikes(ctx context.Context, arg ListChirpsByLikesParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByLikes,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorLikeCount,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = $1::uuid OR $2::boolean)
+    AND ($3::uuid IS NULL OR user_id = $3::uuid)
+    AND (
+        $4::timestamp IS NULL
+        OR (created_at, id) < ($4::timestamp, $5::uuid)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT $6
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+type ListChirpsDescParams struct {
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -78,6 +369,76 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimeline = `-- name: ListTimeline :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = $1::uuid OR $2::boolean)
+    AND (
+        user_id = $1::uuid
+        OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1::uuid)
+    )
+    AND (
+        $3::timestamp IS NULL
+        OR (created_at, id) < ($3::timestamp, $4::uuid)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type ListTimelineParams struct {
+	UserID          uuid.UUID
+	IncludeHidden   bool
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+// Chirps by the user and everyone they follow, newest first
+// Hidden chirps are only included if they're the user's, or with include_hidden for moderators
+func (q *Queries) ListTimeline(ctx context.Context, arg ListTimelineParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimeline,
+		arg.UserID,
+		arg.IncludeHidden,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -93,12 +454,67 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const returnChirp = `-- name: ReturnChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE
+    id = $1
+    AND deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = $2::uuid OR $3::boolean)
+`
+
+type ReturnChirpParams struct {
+	ID            uuid.UUID
+	ViewerID      uuid.NullUUID
+	IncludeHidden bool
+}
+
+// Hidden chirps are only returned to their author, or with include_hidden for moderators
+func (q *Queries) ReturnChirp(ctx context.Context, arg ReturnChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, returnChirp, arg.ID, arg.ViewerID, arg.IncludeHidden)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const returnChirpForUpdate = `-- name: ReturnChirpForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE id = $1 AND deleted_at IS NULL
+FOR UPDATE
+`
+
+func (q *Queries) ReturnChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, returnChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const returnChirpIncludingDeleted = `-- name: ReturnChirpIncludingDeleted :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
 WHERE id = $1
 `
 
-func (q *Queries) ReturnChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, returnChirp, id)
+func (q *Queries) ReturnChirpIncludingDeleted(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, returnChirpIncludingDeleted, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -106,31 +522,93 @@ func (q *Queries) ReturnChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.HiddenAt,
 	)
 	return i, err
 }
 
-const returnUserChirps = `-- name: ReturnUserChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC
+const searchChirps = `-- name: SearchChirps :many
+SELECT
+    chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at,
+    ts_rank(to_tsvector('english', chirps.body), query)::real AS rank,
+    ts_headline(
+        'english',
+        replace(replace(replace(replace(replace(chirps.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;'),
+        query,
+        'StartSel=<mark>, StopSel=</mark>'
+    )::text AS snippet
+FROM chirps, websearch_to_tsquery('english', $1) AS query
+WHERE
+    chirps.deleted_at IS NULL
+    AND (chirps.hidden_at IS NULL OR chirps.user_id = $2::uuid OR $3::boolean)
+    AND to_tsvector('english', chirps.body) @@ query
+    AND ($4::uuid IS NULL OR chirps.user_id = $4::uuid)
+    AND ($5::timestamp IS NULL OR chirps.created_at >= $5::timestamp)
+    AND ($6::timestamp IS NULL OR chirps.created_at < $6::timestamp)
+    AND (
+        $7::timestamp IS NULL
+        OR (ts_rank(to_tsvector('english', chirps.body), query), chirps.created_at, chirps.id) < ($8::real, $7::timestamp, $9::uuid)
+    )
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT $10
 `
 
-func (q *Queries) ReturnUserChirps(ctx context.Context, userID uuid.NullUUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, returnUserChirps, userID)
+type SearchChirpsParams struct {
+	Query           string
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorRank      sql.NullFloat64
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+type SearchChirpsRow struct {
+	Chirp   Chirp
+	Rank    float32
+	Snippet string
+}
+
+// Full-text search, best matches first, with the matching words highlighted
+// ts_headline doesn't escape the body, so it's HTML-escaped first and <mark> is the only markup in the snippet
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorRank,
+		arg.CursorID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []SearchChirpsRow
 	for rows.Next() {
-		var i Chirp
+		var i SearchChirpsRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.Body,
-			&i.UserID,
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.HiddenAt,
+			&i.Rank,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -144,3 +622,48 @@ func (q *Queries) ReturnUserChirps(ctx context.Context, userID uuid.NullUUID) ([
 	}
 	return items, nil
 }
+
+const unhideChirp = `-- name: UnhideChirp :execrows
+UPDATE chirps
+SET hidden_at = NULL
+WHERE id = $1 AND hidden_at IS NOT NULL
+`
+
+func (q *Queries) UnhideChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unhideChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    body = $2
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.HiddenAt,
+	)
+	return i, err
+}
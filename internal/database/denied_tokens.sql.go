@@ -0,0 +1,81 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: denied_tokens.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const deleteExpiredDeniedTokens = `-- name: DeleteExpiredDeniedTokens :execrows
+DELETE FROM denied_tokens
+WHERE expires_at <= NOW()
+`
+
+func (q *Queries) DeleteExpiredDeniedTokens(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredDeniedTokens)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const denyToken = `-- name: DenyToken :exec
+INSERT INTO denied_tokens (id, created_at, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2
+)
+ON CONFLICT (id) DO UPDATE
+SET expires_at = GREATEST(denied_tokens.expires_at, EXCLUDED.expires_at)
+`
+
+type DenyTokenParams struct {
+	ID        uuid.UUID
+	ExpiresAt time.Time
+}
+
+// Denying an ID again keeps whichever expiry is later
+func (q *Queries) DenyToken(ctx context.Context, arg DenyTokenParams) error {
+	_, err := q.db.ExecContext(ctx, denyToken, arg.ID, arg.ExpiresAt)
+	return err
+}
+
+const listDeniedTokens = `-- name: ListDeniedTokens :many
+SELECT id, expires_at FROM denied_tokens
+WHERE expires_at > NOW()
+`
+
+type ListDeniedTokensRow struct {
+	ID        uuid.UUID
+	ExpiresAt time.Time
+}
+
+func (q *Queries) ListDeniedTokens(ctx context.Context) ([]ListDeniedTokensRow, error) {
+	rows, err := q.db.QueryContext(ctx, listDeniedTokens)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDeniedTokensRow
+	for rows.Next() {
+		var i ListDeniedTokensRow
+		if err := rows.Scan(&i.ID, &i.ExpiresAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,138 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: mentions.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const addChirpMentions = `-- name: AddChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, created_at)
+SELECT $1, user_id, NOW()
+FROM unnest($2::uuid[]) AS user_id
+ON CONFLICT (chirp_id, user_id) DO NOTHING
+`
+
+type AddChirpMentionsParams struct {
+	ChirpID uuid.UUID
+	UserIds []uuid.UUID
+}
+
+func (q *Queries) AddChirpMentions(ctx context.Context, arg AddChirpMentionsParams) error {
+	_, err := q.db.ExecContext(ctx, addChirpMentions, arg.ChirpID, pq.Array(arg.UserIds))
+	return err
+}
+
+const deleteChirpMentions = `-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1
+`
+
+func (q *Queries) DeleteChirpMentions(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirpMentions, chirpID)
+	return err
+}
+
+const findMentionCandidates = `-- name: FindMentionCandidates :many
+SELECT id, email, handle FROM users
+WHERE
+    LOWER(handle) = ANY($1::text[])
+    OR LOWER(split_part(email, '@', 1)) = ANY($1::text[])
+`
+
+type FindMentionCandidatesRow struct {
+	ID     uuid.UUID
+	Email  string
+	Handle sql.NullString
+}
+
+// Users whose handle or email local part matches one of the names
+func (q *Queries) FindMentionCandidates(ctx context.Context, names []string) ([]FindMentionCandidatesRow, error) {
+	rows, err := q.db.QueryContext(ctx, findMentionCandidates, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindMentionCandidatesRow
+	for rows.Next() {
+		var i FindMentionCandidatesRow
+		if err := rows.Scan(&i.ID, &i.Email, &i.Handle); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMentions = `-- name: ListMentions :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at FROM chirps
+JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE
+    chirp_mentions.user_id = $1
+    AND chirps.deleted_at IS NULL
+    AND chirps.hidden_at IS NULL
+    AND (
+        $2::timestamp IS NULL
+        OR (chirps.created_at, chirps.id) < ($2::timestamp, $3::uuid)
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
+`
+
+type ListMentionsParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListMentions(ctx context.Context, arg ListMentionsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listMentions,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
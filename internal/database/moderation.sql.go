@@ -0,0 +1,190 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: moderation.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createModerationFlag = `-- name: CreateModerationFlag :exec
+INSERT INTO moderation_flags (id, created_at, chirp_id, word, reviewed_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    NULL
+)
+`
+
+type CreateModerationFlagParams struct {
+	ChirpID uuid.UUID
+	Word    string
+}
+
+func (q *Queries) CreateModerationFlag(ctx context.Context, arg CreateModerationFlagParams) error {
+	_, err := q.db.ExecContext(ctx, createModerationFlag, arg.ChirpID, arg.Word)
+	return err
+}
+
+const deleteModerationWord = `-- name: DeleteModerationWord :execrows
+DELETE FROM moderation_words
+WHERE word = $1
+`
+
+func (q *Queries) DeleteModerationWord(ctx context.Context, word string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteModerationWord, word)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listModerationWords = `-- name: ListModerationWords :many
+SELECT word, created_at, updated_at, action FROM moderation_words
+ORDER BY word ASC
+`
+
+func (q *Queries) ListModerationWords(ctx context.Context) ([]ModerationWord, error) {
+	rows, err := q.db.QueryContext(ctx, listModerationWords)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationWord
+	for rows.Next() {
+		var i ModerationWord
+		if err := rows.Scan(
+			&i.Word,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Action,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingModerationFlags = `-- name: ListPendingModerationFlags :many
+SELECT moderation_flags.id, moderation_flags.created_at, moderation_flags.chirp_id, moderation_flags.word, moderation_flags.reviewed_at, chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at
+FROM moderation_flags
+JOIN chirps ON chirps.id = moderation_flags.chirp_id
+WHERE
+    moderation_flags.reviewed_at IS NULL
+    AND (
+        $1::timestamp IS NULL
+        OR (moderation_flags.created_at, moderation_flags.id) > ($1::timestamp, $2::uuid)
+    )
+ORDER BY moderation_flags.created_at ASC, moderation_flags.id ASC
+LIMIT $3
+`
+
+type ListPendingModerationFlagsParams struct {
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+type ListPendingModerationFlagsRow struct {
+	ModerationFlag ModerationFlag
+	Chirp          Chirp
+}
+
+// Flags waiting for review, oldest first, with the flagged chirp
+func (q *Queries) ListPendingModerationFlags(ctx context.Context, arg ListPendingModerationFlagsParams) ([]ListPendingModerationFlagsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPendingModerationFlags, arg.CursorCreatedAt, arg.CursorID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPendingModerationFlagsRow
+	for rows.Next() {
+		var i ListPendingModerationFlagsRow
+		if err := rows.Scan(
+			&i.ModerationFlag.ID,
+			&i.ModerationFlag.CreatedAt,
+			&i.ModerationFlag.ChirpID,
+			&i.ModerationFlag.Word,
+			&i.ModerationFlag.ReviewedAt,
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reviewModerationFlag = `-- name: ReviewModerationFlag :execrows
+UPDATE moderation_flags
+SET reviewed_at = NOW()
+WHERE id = $1 AND reviewed_at IS NULL
+`
+
+func (q *Queries) ReviewModerationFlag(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, reviewModerationFlag, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertModerationWord = `-- name: UpsertModerationWord :one
+INSERT INTO moderation_words (word, created_at, updated_at, action)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2
+)
+ON CONFLICT (word) DO UPDATE
+SET
+    updated_at = NOW(),
+    action = EXCLUDED.action
+RETURNING word, created_at, updated_at, action
+`
+
+type UpsertModerationWordParams struct {
+	Word   string
+	Action string
+}
+
+func (q *Queries) UpsertModerationWord(ctx context.Context, arg UpsertModerationWordParams) (ModerationWord, error) {
+	row := q.db.QueryRowContext(ctx, upsertModerationWord, arg.Word, arg.Action)
+	var i ModerationWord
+	err := row.Scan(
+		&i.Word,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Action,
+	)
+	return i, err
+}
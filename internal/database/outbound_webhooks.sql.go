@@ -0,0 +1,388 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: outbound_webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const addWebhookSubscriptionEvents = `-- name: AddWebhookSubscriptionEvents :exec
+INSERT INTO webhook_subscription_events (subscription_id, event)
+SELECT $1, event
+FROM unnest($2::text[]) AS event
+ON CONFLICT (subscription_id, event) DO NOTHING
+`
+
+type AddWebhookSubscriptionEventsParams struct {
+	SubscriptionID uuid.UUID
+	Events         []string
+}
+
+func (q *Queries) AddWebhookSubscriptionEvents(ctx context.Context, arg AddWebhookSubscriptionEventsParams) error {
+	_, err := q.db.ExecContext(ctx, addWebhookSubscriptionEvents, arg.SubscriptionID, pq.Array(arg.Events))
+	return err
+}
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET
+    updated_at = NOW(),
+    next_attempt_at = $1::timestamp
+WHERE id IN (
+    SELECT due.id FROM webhook_deliveries AS due
+    WHERE due.status = 'pending' AND due.next_attempt_at <= $2::timestamp
+    ORDER BY due.next_attempt_at ASC, due.id ASC
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, subscription_id, event, payload, status, attempts, next_attempt_at
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil time.Time
+	DueBy      time.Time
+	PageLimit  int32
+}
+
+// Pending deliveries that are due, leased until lease_until so no other instance sends them meanwhile
+// A delivery whose sender died is sent again once its lease runs out
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.DueBy, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SubscriptionID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhookSubscription = `-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, created_at, user_id, url, secret)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING id, created_at, user_id, url, secret
+`
+
+type CreateWebhookSubscriptionParams struct {
+	UserID uuid.NullUUID
+	Url    string
+	Secret string
+}
+
+func (q *Queries) CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookSubscription, arg.UserID, arg.Url, arg.Secret)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+	)
+	return i, err
+}
+
+const deleteOldWebhookDeliveries = `-- name: DeleteOldWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending' AND updated_at < $1
+`
+
+// Finished deliveries, and their attempts, are kept for the delivery log until they're older than before
+func (q *Queries) DeleteOldWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOldWebhookDeliveries, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteWebhookSubscription = `-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2::uuid
+`
+
+type DeleteWebhookSubscriptionParams struct {
+	ID     uuid.UUID
+	UserID uuid.NullUUID
+}
+
+func (q *Queries) DeleteWebhookSubscription(ctx context.Context, arg DeleteWebhookSubscriptionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhookSubscription, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enqueueWebhookDeliveries = `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, updated_at, subscription_id, event, payload, status, attempts, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), webhook_subscriptions.id, webhook_subscription_events.event, $1::text, 'pending', 0, NOW()
+FROM webhook_subscriptions
+JOIN webhook_subscription_events ON webhook_subscription_events.subscription_id = webhook_subscriptions.id
+WHERE
+    webhook_subscription_events.event = $2::text
+    AND (webhook_subscriptions.user_id IS NULL OR webhook_subscriptions.user_id = $3::uuid)
+`
+
+type EnqueueWebhookDeliveriesParams struct {
+	Payload string
+	Event   string
+	UserID  uuid.UUID
+}
+
+// Queue an event for every subscription that wants it: the admin ones, and the user's own
+func (q *Queries) EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enqueueWebhookDeliveries, arg.Payload, arg.Event, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getWebhookSubscription = `-- name: GetWebhookSubscription :one
+SELECT id, created_at, user_id, url, secret FROM webhook_subscriptions
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (WebhookSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookSubscription, id)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+	)
+	return i, err
+}
+
+const listWebhookDeliveryAttempts = `-- name: ListWebhookDeliveryAttempts :many
+SELECT
+    webhook_delivery_attempts.id,
+    webhook_delivery_attempts.created_at,
+    webhook_delivery_attempts.delivery_id,
+    webhook_deliveries.event,
+    webhook_deliveries.status,
+    webhook_delivery_attempts.response_status,
+    webhook_delivery_attempts.response_body,
+    webhook_delivery_attempts.error
+FROM webhook_delivery_attempts
+JOIN webhook_deliveries ON webhook_deliveries.id = webhook_delivery_attempts.delivery_id
+WHERE
+    webhook_deliveries.subscription_id = $1
+    AND (
+        $2::timestamp IS NULL
+        OR (webhook_delivery_attempts.created_at, webhook_delivery_attempts.id) < ($2::timestamp, $3::uuid)
+    )
+ORDER BY webhook_delivery_attempts.created_at DESC, webhook_delivery_attempts.id DESC
+LIMIT $4
+`
+
+type ListWebhookDeliveryAttemptsParams struct {
+	SubscriptionID  uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+type ListWebhookDeliveryAttemptsRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	DeliveryID     uuid.UUID
+	Event          string
+	Status         string
+	ResponseStatus sql.NullInt32
+	ResponseBody   string
+	Error          sql.NullString
+}
+
+// A subscription's delivery log, newest attempt first
+func (q *Queries) ListWebhookDeliveryAttempts(ctx context.Context, arg ListWebhookDeliveryAttemptsParams) ([]ListWebhookDeliveryAttemptsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveryAttempts,
+		arg.SubscriptionID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWebhookDeliveryAttemptsRow
+	for rows.Next() {
+		var i ListWebhookDeliveryAttemptsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.DeliveryID,
+			&i.Event,
+			&i.Status,
+			&i.ResponseStatus,
+			&i.ResponseBody,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscriptionEvents = `-- name: ListWebhookSubscriptionEvents :many
+SELECT event FROM webhook_subscription_events
+WHERE subscription_id = $1
+ORDER BY event ASC
+`
+
+func (q *Queries) ListWebhookSubscriptionEvents(ctx context.Context, subscriptionID uuid.UUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookSubscriptionEvents, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var event string
+		if err := rows.Scan(&event); err != nil {
+			return nil, err
+		}
+		items = append(items, event)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscriptions = `-- name: ListWebhookSubscriptions :many
+SELECT id, created_at, user_id, url, secret FROM webhook_subscriptions
+WHERE user_id IS NOT DISTINCT FROM $1::uuid
+ORDER BY created_at ASC, id ASC
+`
+
+// A user's subscriptions, or the admin ones when user_id is NULL
+func (q *Queries) ListWebhookSubscriptions(ctx context.Context, userID uuid.NullUUID) ([]WebhookSubscription, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookSubscriptions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookSubscription
+	for rows.Next() {
+		var i WebhookSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWebhookDeliveryAttempt = `-- name: RecordWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, created_at, delivery_id, response_status, response_body, error)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+`
+
+type RecordWebhookDeliveryAttemptParams struct {
+	DeliveryID     uuid.UUID
+	ResponseStatus sql.NullInt32
+	ResponseBody   string
+	Error          sql.NullString
+}
+
+func (q *Queries) RecordWebhookDeliveryAttempt(ctx context.Context, arg RecordWebhookDeliveryAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, recordWebhookDeliveryAttempt,
+		arg.DeliveryID,
+		arg.ResponseStatus,
+		arg.ResponseBody,
+		arg.Error,
+	)
+	return err
+}
+
+const updateWebhookDelivery = `-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET
+    updated_at = NOW(),
+    status = $1,
+    attempts = attempts + 1,
+    next_attempt_at = $2
+WHERE id = $3
+`
+
+type UpdateWebhookDeliveryParams struct {
+	Status        string
+	NextAttemptAt time.Time
+	ID            uuid.UUID
+}
+
+// Record the outcome of an attempt: delivered, failed for good, or pending a retry at next_attempt_at
+func (q *Queries) UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, updateWebhookDelivery, arg.Status, arg.NextAttemptAt, arg.ID)
+	return err
+}
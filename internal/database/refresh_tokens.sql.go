@@ -7,30 +7,70 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const createRToken = `-- name: CreateRToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, user_agent, ip, last_used_at)
 VALUES (
    $1,
     NOW(),
     NOW(),
     $2,
     NOW() + INTERVAL '60 days',
-    NULL 
+    NULL,
+    $3,
+    $4,
+    $5,
+    NOW()
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, user_agent, ip, last_used_at
 `
 
 type CreateRTokenParams struct {
-	Token  string
-	UserID uuid.NullUUID
+	Token     string
+	UserID    uuid.NullUUID
+	FamilyID  uuid.UUID
+	UserAgent string
+	Ip        string
 }
 
 func (q *Queries) CreateRToken(ctx context.Context, arg CreateRTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRToken, arg.Token, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createRToken,
+		arg.Token,
+		arg.UserID,
+		arg.FamilyID,
+		arg.UserAgent,
+		arg.Ip,
+	)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.UserAgent,
+		&i.Ip,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const getRTokenForUpdate = `-- name: GetRTokenForUpdate :one
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, user_agent, ip, last_used_at FROM refresh_tokens
+WHERE token = $1
+FOR UPDATE
+`
+
+// Locks the token, so concurrent refreshes with it take turns
+// Revoked and expired tokens are returned too, for the caller to check
+func (q *Queries) GetRTokenForUpdate(ctx context.Context, token string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRTokenForUpdate, token)
 	var i RefreshToken
 	err := row.Scan(
 		&i.Token,
@@ -39,32 +79,211 @@ func (q *Queries) CreateRToken(ctx context.Context, arg CreateRTokenParams) (Ref
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.UserAgent,
+		&i.Ip,
+		&i.LastUsedAt,
 	)
 	return i, err
 }
 
-const getUserFromRToken = `-- name: GetUserFromRToken :one
-SELECT refresh_tokens.user_id
-FROM refresh_tokens
-WHERE refresh_tokens.token = $1 AND refresh_tokens.expires_at > NOW() AND refresh_tokens.revoked_at IS NULL
+const listUserSessions = `-- name: ListUserSessions :many
+SELECT
+    active.family_id,
+    first_token.created_at AS signed_in_at,
+    active.last_used_at,
+    active.expires_at,
+    active.user_agent,
+    active.ip
+FROM refresh_tokens AS active
+JOIN refresh_tokens AS first_token ON first_token.family_id = active.family_id
+WHERE
+    active.user_id = $1
+    AND active.revoked_at IS NULL
+    AND active.expires_at > NOW()
+    AND NOT EXISTS (
+        SELECT 1 FROM refresh_tokens AS older
+        WHERE
+            older.family_id = first_token.family_id
+            AND (older.created_at, older.token) < (first_token.created_at, first_token.token)
+    )
+ORDER BY active.last_used_at DESC, active.family_id ASC
 `
 
-func (q *Queries) GetUserFromRToken(ctx context.Context, token string) (uuid.NullUUID, error) {
-	row := q.db.QueryRowContext(ctx, getUserFromRToken, token)
-	var user_id uuid.NullUUID
-	err := row.Scan(&user_id)
-	return user_id, err
+type ListUserSessionsRow struct {
+	FamilyID   uuid.UUID
+	SignedInAt time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	UserAgent  string
+	Ip         string
 }
 
-const revokeRToken = `-- name: RevokeRToken :exec
+// A session is a token family, described by its active token and signed in when its first token was issued
+func (q *Queries) ListUserSessions(ctx context.Context, userID uuid.NullUUID) ([]ListUserSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserSessionsRow
+	for rows.Next() {
+		var i ListUserSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.SignedInAt,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.UserAgent,
+			&i.Ip,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeOtherUserRTokens = `-- name: RevokeOtherUserRTokens :many
+UPDATE refresh_tokens
+SET
+    revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL
+RETURNING family_id
+`
+
+type RevokeOtherUserRTokensParams struct {
+	UserID       uuid.NullUUID
+	KeepFamilyID uuid.UUID
+}
+
+// Revokes every session but one, as after a password change, returning the revoked families
+func (q *Queries) RevokeOtherUserRTokens(ctx context.Context, arg RevokeOtherUserRTokensParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, revokeOtherUserRTokens, arg.UserID, arg.KeepFamilyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var family_id uuid.UUID
+		if err := rows.Scan(&family_id); err != nil {
+			return nil, err
+		}
+		items = append(items, family_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeRToken = `-- name: RevokeRToken :many
 UPDATE refresh_tokens
 SET 
     revoked_at = NOW(),
     updated_at = NOW()
 WHERE token = $1
+RETURNING family_id
 `
 
-func (q *Queries) RevokeRToken(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, revokeRToken, token)
+// Returns the token's family, so its access tokens can be denied too
+func (q *Queries) RevokeRToken(ctx context.Context, token string) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, revokeRToken, token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var family_id uuid.UUID
+		if err := rows.Scan(&family_id); err != nil {
+			return nil, err
+		}
+		items = append(items, family_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeRTokenFamily = `-- name: RevokeRTokenFamily :exec
+UPDATE refresh_tokens
+SET
+    revoked_at = NOW(),
+    updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeRTokenFamily, familyID)
 	return err
 }
+
+const revokeUserRTokens = `-- name: RevokeUserRTokens :many
+UPDATE refresh_tokens
+SET 
+    revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+RETURNING family_id
+`
+
+// Returns the families of the revoked sessions
+func (q *Queries) RevokeUserRTokens(ctx context.Context, userID uuid.NullUUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, revokeUserRTokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var family_id uuid.UUID
+		if err := rows.Scan(&family_id); err != nil {
+			return nil, err
+		}
+		items = append(items, family_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeUserSession = `-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+SET
+    revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeUserSessionParams struct {
+	UserID   uuid.NullUUID
+	FamilyID uuid.UUID
+}
+
+func (q *Queries) RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserSession, arg.UserID, arg.FamilyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,676 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: chirps.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const adjustChirpLikeCount = `-- name: AdjustChirpLikeCount :exec
+UPDATE chirps
+SET like_count = like_count + ?1
+WHERE id = ?2
+`
+
+type AdjustChirpLikeCountParams struct {
+	Delta int64
+	ID    uuid.UUID
+}
+
+func (q *Queries) AdjustChirpLikeCount(ctx context.Context, arg AdjustChirpLikeCountParams) error {
+	_, err := q.db.ExecContext(ctx, adjustChirpLikeCount, arg.Delta, arg.ID)
+	return err
+}
+
+const createChirp = `-- name: CreateChirp :one
+INSERT INTO chirps (created_at, updated_at, body, user_id, in_reply_to)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2,
+    ?3
+)
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at
+`
+
+type CreateChirpParams struct {
+	Body      string
+	UserID    uuid.NullUUID
+	InReplyTo uuid.NullUUID
+}
+
+func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.InReplyTo)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const deleteChirp = `-- name: DeleteChirp :exec
+UPDATE chirps
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    deleted_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE
+    id = ?1
+    AND user_id = ?2
+    AND deleted_at IS NULL
+`
+
+type DeleteChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.NullUUID
+}
+
+// Soft delete, so replies can still point at the chirp as a tombstone
+func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error {
+	_, err := q.db.ExecContext(ctx, deleteChirp, arg.ID, arg.UserID)
+	return err
+}
+
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT parent.id, parent.in_reply_to, 1
+    FROM chirps AS parent
+    WHERE parent.id = (SELECT start.in_reply_to FROM chirps AS start WHERE start.id = ?1)
+    UNION ALL
+    SELECT c.id, c.in_reply_to, a.depth + 1
+    FROM chirps AS c
+    JOIN ancestors AS a ON c.id = a.in_reply_to
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+ORDER BY ancestors.depth DESC
+`
+
+// Walks up the in_reply_to chain, returning the root of the thread first
+func (q *Queries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants (id) AS (
+    SELECT reply.id
+    FROM chirps AS reply
+    WHERE reply.in_reply_to = ?1
+    UNION ALL
+    SELECT c.id
+    FROM chirps AS c
+    JOIN descendants AS d ON c.in_reply_to = d.id
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at FROM chirps
+JOIN descendants ON chirps.id = descendants.id
+ORDER BY chirps.created_at ASC, chirps.id ASC
+`
+
+// Walks down every reply chain below a chirp, oldest replies first
+func (q *Queries) GetChirpDescendants(ctx context.Context, id uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?1 AND hidden_at IS NULL
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = ?1 OR CAST(?2 AS BOOLEAN))
+    AND (user_id = ?3 OR ?3 IS NULL)
+    AND (
+        ?4 IS NULL
+        OR created_at > strftime('%Y-%m-%d %H:%M:%f', ?4)
+        OR (created_at = strftime('%Y-%m-%d %H:%M:%f', ?4) AND id > ?5)
+    )
+ORDER BY created_at ASC, id ASC
+LIMIT ?6
+`
+
+type ListChirpsAscParams struct {
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt interface{}
+	CursorID        uuid.NullUUID
+	PageLimit       int64
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsByLikes = `-- name: ListChirpsByLikes :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = ?1 OR CAST(?2 AS BOOLEAN))
+    AND (user_id = ?3 OR ?3 IS NULL)
+    AND (
+        ?4 IS NULL
+        OR like_count < ?5
+        OR (like_count = ?5 AND (
+            created_at < strftime('%Y-%m-%d %H:%M:%f', ?4)
+            OR (created_at = strftime('%Y-%m-%d %H:%M:%f', ?4) AND id < ?6)
+        ))
+    )
+ORDER BY like_count DESC, created_at DESC, id DESC
+LIMIT ?7
+`
+
+type ListChirpsByLikesParams struct {
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt interface{}
+	CursorLikeCount sql.NullInt64
+	CursorID        uuid.NullUUID
+	PageLimit       int64
+}
+
+// Most liked chirps first, for the popular view
+func (q *Queries) ListChirpsByLikes(ctx context.Context, arg ListChirpsByLikesParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByLikes,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorLikeCount,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = ?1 OR CAST(?2 AS BOOLEAN))
+    AND (user_id = ?3 OR ?3 IS NULL)
+    AND (
+        ?4 IS NULL
+        OR created_at < strftime('%Y-%m-%d %H:%M:%f', ?4)
+        OR (created_at = strftime('%Y-%m-%d %H:%M:%f', ?4) AND id < ?5)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT ?6
+`
+
+type ListChirpsDescParams struct {
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt interface{}
+	CursorID        uuid.NullUUID
+	PageLimit       int64
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimeline = `-- name: ListTimeline :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = ?1 OR CAST(?2 AS BOOLEAN))
+    AND (
+        user_id = ?1
+        OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = ?1)
+    )
+    AND (
+        ?3 IS NULL
+        OR created_at < strftime('%Y-%m-%d %H:%M:%f', ?3)
+        OR (created_at = strftime('%Y-%m-%d %H:%M:%f', ?3) AND id < ?4)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT ?5
+`
+
+type ListTimelineParams struct {
+	UserID          uuid.NullUUID
+	IncludeHidden   bool
+	CursorCreatedAt interface{}
+	CursorID        uuid.NullUUID
+	PageLimit       int64
+}
+
+// Chirps by the user and everyone they follow, newest first
+// Hidden chirps are only included if they're the user's, or with include_hidden for moderators
+func (q *Queries) ListTimeline(ctx context.Context, arg ListTimelineParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimeline,
+		arg.UserID,
+		arg.IncludeHidden,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const returnChirp = `-- name: ReturnChirp :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE
+    id = ?1
+    AND deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = ?2 OR CAST(?3 AS BOOLEAN))
+`
+
+type ReturnChirpParams struct {
+	ID            uuid.UUID
+	ViewerID      uuid.NullUUID
+	IncludeHidden bool
+}
+
+// Hidden chirps are only returned to their author, or with include_hidden for moderators
+func (q *Queries) ReturnChirp(ctx context.Context, arg ReturnChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, returnChirp, arg.ID, arg.ViewerID, arg.IncludeHidden)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const returnChirpForUpdate = `-- name: ReturnChirpForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE id = ?1 AND deleted_at IS NULL
+`
+
+// Transactions take the write lock when they begin, so there's no FOR UPDATE
+func (q *Queries) ReturnChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, returnChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const returnChirpIncludingDeleted = `-- name: ReturnChirpIncludingDeleted :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at FROM chirps
+WHERE id = ?1
+`
+
+func (q *Queries) ReturnChirpIncludingDeleted(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, returnChirpIncludingDeleted, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT
+    chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at,
+    CAST(round(-bm25(chirps_fts), 4) AS REAL) AS search_rank,
+    highlight(chirps_fts, 0, char(2), char(3)) AS snippet
+FROM chirps_fts
+JOIN chirps ON chirps.rowid = chirps_fts.rowid
+WHERE
+    chirps_fts.body MATCH ?1
+    AND chirps.deleted_at IS NULL
+    AND (chirps.hidden_at IS NULL OR chirps.user_id = ?2 OR CAST(?3 AS BOOLEAN))
+    AND (chirps.user_id = ?4 OR ?4 IS NULL)
+    AND (?5 IS NULL OR chirps.created_at >= strftime('%Y-%m-%d %H:%M:%f', ?5))
+    AND (?6 IS NULL OR chirps.created_at < strftime('%Y-%m-%d %H:%M:%f', ?6))
+    AND (
+        ?7 IS NULL
+        OR round(-bm25(chirps_fts), 4) < round(?8, 4)
+        OR (round(-bm25(chirps_fts), 4) = round(?8, 4) AND (
+            chirps.created_at < strftime('%Y-%m-%d %H:%M:%f', ?7)
+            OR (chirps.created_at = strftime('%Y-%m-%d %H:%M:%f', ?7) AND chirps.id < ?9)
+        ))
+    )
+ORDER BY search_rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT ?10
+`
+
+type SearchChirpsParams struct {
+	Query           string
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	AuthorID        uuid.NullUUID
+	Since           interface{}
+	Until           interface{}
+	CursorCreatedAt interface{}
+	CursorRank      sql.NullFloat64
+	CursorID        uuid.NullUUID
+	PageLimit       int64
+}
+
+type SearchChirpsRow struct {
+	Chirp      Chirp
+	SearchRank float64
+	Snippet    string
+}
+
+// Full-text search, best matches first, with the matching words highlighted
+// The query is in FTS5 syntax, and ranks are rounded so a cursor's rank compares equal to its row's
+// Matches are marked with control characters, so the adapter can escape the body before adding <mark> tags
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorRank,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.HiddenAt,
+			&i.SearchRank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const unhideChirp = `-- name: UnhideChirp :execrows
+UPDATE chirps
+SET hidden_at = NULL
+WHERE id = ?1 AND hidden_at IS NOT NULL
+`
+
+func (q *Queries) UnhideChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unhideChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    body = ?1
+WHERE id = ?2
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, like_count, hidden_at
+`
+
+type UpdateChirpBodyParams struct {
+	Body string
+	ID   uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.HiddenAt,
+	)
+	return i, err
+}
@@ -0,0 +1,175 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: mentions.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const addChirpMentions = `-- name: AddChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, created_at)
+SELECT ?1, id, strftime('%Y-%m-%d %H:%M:%f', 'now')
+FROM users
+WHERE id IN (/*SLICE:user_ids*/?)
+ON CONFLICT (chirp_id, user_id) DO NOTHING
+`
+
+type AddChirpMentionsParams struct {
+	ChirpID uuid.UUID
+	UserIds []uuid.UUID
+}
+
+func (q *Queries) AddChirpMentions(ctx context.Context, arg AddChirpMentionsParams) error {
+	query := addChirpMentions
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.ChirpID)
+	if len(arg.UserIds) > 0 {
+		for _, v := range arg.UserIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:user_ids*/?", strings.Repeat(",?", len(arg.UserIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:user_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const deleteChirpMentions = `-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = ?1
+`
+
+func (q *Queries) DeleteChirpMentions(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirpMentions, chirpID)
+	return err
+}
+
+const findMentionCandidates = `-- name: FindMentionCandidates :many
+SELECT id, email, handle FROM users
+WHERE
+    handle_key IN (/*SLICE:handles*/?)
+    OR email_local_part IN (/*SLICE:local_parts*/?)
+`
+
+type FindMentionCandidatesParams struct {
+	Handles    []sql.NullString
+	LocalParts []sql.NullString
+}
+
+type FindMentionCandidatesRow struct {
+	ID     uuid.UUID
+	Email  string
+	Handle sql.NullString
+}
+
+// Users whose handle or email local part matches one of the names
+// Pass the same names twice, as each slice can only be used once
+func (q *Queries) FindMentionCandidates(ctx context.Context, arg FindMentionCandidatesParams) ([]FindMentionCandidatesRow, error) {
+	query := findMentionCandidates
+	var queryParams []interface{}
+	if len(arg.Handles) > 0 {
+		for _, v := range arg.Handles {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:handles*/?", strings.Repeat(",?", len(arg.Handles))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:handles*/?", "NULL", 1)
+	}
+	if len(arg.LocalParts) > 0 {
+		for _, v := range arg.LocalParts {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:local_parts*/?", strings.Repeat(",?", len(arg.LocalParts))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:local_parts*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindMentionCandidatesRow
+	for rows.Next() {
+		var i FindMentionCandidatesRow
+		if err := rows.Scan(&i.ID, &i.Email, &i.Handle); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMentions = `-- name: ListMentions :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at FROM chirps
+JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE
+    chirp_mentions.user_id = ?1
+    AND chirps.deleted_at IS NULL
+    AND chirps.hidden_at IS NULL
+    AND (
+        ?2 IS NULL
+        OR chirps.created_at < strftime('%Y-%m-%d %H:%M:%f', ?2)
+        OR (chirps.created_at = strftime('%Y-%m-%d %H:%M:%f', ?2) AND chirps.id < ?3)
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT ?4
+`
+
+type ListMentionsParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt interface{}
+	CursorID        uuid.NullUUID
+	PageLimit       int64
+}
+
+func (q *Queries) ListMentions(ctx context.Context, arg ListMentionsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listMentions,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,196 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: reports.sql
+
+package sqlitedb
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const createReport = `-- name: CreateReport :one
+INSERT INTO reports (created_at, updated_at, chirp_id, reporter_id, reason, status, resolved_at)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2,
+    ?3,
+    'open',
+    NULL
+)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING id, created_at, updated_at, chirp_id, reporter_id, reason, status, resolved_at
+`
+
+type CreateReportParams struct {
+	ChirpID    uuid.UUID
+	ReporterID uuid.UUID
+	Reason     string
+}
+
+// Returns no rows if the user has already reported the chirp
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, createReport, arg.ChirpID, arg.ReporterID, arg.Reason)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ChirpID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.Status,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const listReports = `-- name: ListReports :many
+SELECT reports.id, reports.created_at, reports.updated_at, reports.chirp_id, reports.reporter_id, reports.reason, reports.status, reports.resolved_at, chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at
+FROM reports
+JOIN chirps ON chirps.id = reports.chirp_id
+WHERE
+    reports.status = ?1
+    AND (
+        ?2 IS NULL
+        OR reports.created_at > strftime('%Y-%m-%d %H:%M:%f', ?2)
+        OR (reports.created_at = strftime('%Y-%m-%d %H:%M:%f', ?2) AND reports.id > ?3)
+    )
+ORDER BY reports.created_at ASC, reports.id ASC
+LIMIT ?4
+`
+
+type ListReportsParams struct {
+	Status          string
+	CursorCreatedAt interface{}
+	CursorID        uuid.NullUUID
+	PageLimit       int64
+}
+
+type ListReportsRow struct {
+	Report Report
+	Chirp  Chirp
+}
+
+// Reports with a given status, oldest first, with the reported chirp
+func (q *Queries) ListReports(ctx context.Context, arg ListReportsParams) ([]ListReportsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listReports,
+		arg.Status,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReportsRow
+	for rows.Next() {
+		var i ListReportsRow
+		if err := rows.Scan(
+			&i.Report.ID,
+			&i.Report.CreatedAt,
+			&i.Report.UpdatedAt,
+			&i.Report.ChirpID,
+			&i.Report.ReporterID,
+			&i.Report.Reason,
+			&i.Report.Status,
+			&i.Report.ResolvedAt,
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resolveOpenChirpReports = `-- name: ResolveOpenChirpReports :exec
+UPDATE reports
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    status = ?1,
+    resolved_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE chirp_id = ?2 AND status = 'open'
+`
+
+type ResolveOpenChirpReportsParams struct {
+	Status  string
+	ChirpID uuid.UUID
+}
+
+// Close any other open reports about the same chirp once it has been dealt with
+func (q *Queries) ResolveOpenChirpReports(ctx context.Context, arg ResolveOpenChirpReportsParams) error {
+	_, err := q.db.ExecContext(ctx, resolveOpenChirpReports, arg.Status, arg.ChirpID)
+	return err
+}
+
+const resolveReport = `-- name: ResolveReport :one
+UPDATE reports
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    status = ?1,
+    resolved_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?2 AND status = 'open'
+RETURNING id, created_at, updated_at, chirp_id, reporter_id, reason, status, resolved_at
+`
+
+type ResolveReportParams struct {
+	Status string
+	ID     uuid.UUID
+}
+
+func (q *Queries) ResolveReport(ctx context.Context, arg ResolveReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, resolveReport, arg.Status, arg.ID)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ChirpID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.Status,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const returnReport = `-- name: ReturnReport :one
+SELECT id, created_at, updated_at, chirp_id, reporter_id, reason, status, resolved_at FROM reports
+WHERE id = ?1
+`
+
+func (q *Queries) ReturnReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRowContext(ctx, returnReport, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ChirpID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.Status,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,151 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: scheduled_chirps.sql
+
+package sqlitedb
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const createScheduledChirp = `-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (created_at, user_id, body, in_reply_to, publish_at)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2,
+    ?3,
+    strftime('%Y-%m-%d %H:%M:%f', ?4)
+)
+RETURNING id, created_at, user_id, body, in_reply_to, publish_at
+`
+
+type CreateScheduledChirpParams struct {
+	UserID    uuid.UUID
+	Body      string
+	InReplyTo uuid.NullUUID
+	PublishAt interface{}
+}
+
+func (q *Queries) CreateScheduledChirp(ctx context.Context, arg CreateScheduledChirpParams) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, createScheduledChirp,
+		arg.UserID,
+		arg.Body,
+		arg.InReplyTo,
+		arg.PublishAt,
+	)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.InReplyTo,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const deleteScheduledChirp = `-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = ?1 AND user_id = ?2
+`
+
+type DeleteScheduledChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteScheduledChirp(ctx context.Context, arg DeleteScheduledChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteScheduledChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listDueScheduledChirps = `-- name: ListDueScheduledChirps :many
+SELECT scheduled_chirps.id, scheduled_chirps.created_at, scheduled_chirps.user_id, scheduled_chirps.body, scheduled_chirps.in_reply_to, scheduled_chirps.publish_at FROM scheduled_chirps
+JOIN users ON users.id = scheduled_chirps.user_id
+WHERE
+    scheduled_chirps.publish_at <= strftime('%Y-%m-%d %H:%M:%f', ?1)
+    AND users.suspended_at IS NULL
+ORDER BY scheduled_chirps.publish_at ASC, scheduled_chirps.id ASC
+LIMIT ?2
+`
+
+type ListDueScheduledChirpsParams struct {
+	PublishBefore interface{}
+	PageLimit     int64
+}
+
+// Suspended users' chirps wait until they're unsuspended
+// Transactions take the write lock when they begin, so there's no FOR UPDATE
+func (q *Queries) ListDueScheduledChirps(ctx context.Context, arg ListDueScheduledChirpsParams) ([]ScheduledChirp, error) {
+	rows, err := q.db.QueryContext(ctx, listDueScheduledChirps, arg.PublishBefore, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledChirp
+	for rows.Next() {
+		var i ScheduledChirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Body,
+			&i.InReplyTo,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserScheduledChirps = `-- name: ListUserScheduledChirps :many
+SELECT id, created_at, user_id, body, in_reply_to, publish_at FROM scheduled_chirps
+WHERE user_id = ?1
+ORDER BY publish_at ASC, id ASC
+`
+
+func (q *Queries) ListUserScheduledChirps(ctx context.Context, userID uuid.UUID) ([]ScheduledChirp, error) {
+	rows, err := q.db.QueryContext(ctx, listUserScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledChirp
+	for rows.Next() {
+		var i ScheduledChirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Body,
+			&i.InReplyTo,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,133 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: subscriptions.sql
+
+package sqlitedb
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const expireLapsedSubscriptions = `-- name: ExpireLapsedSubscriptions :many
+UPDATE subscriptions
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    status = 'expired'
+WHERE status <> 'expired' AND current_period_end <= strftime('%Y-%m-%d %H:%M:%f', 'now')
+RETURNING user_id
+`
+
+// Returns the users whose subscriptions expired
+func (q *Queries) ExpireLapsedSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, expireLapsedSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const syncUserChirpyRed = `-- name: SyncUserChirpyRed :exec
+UPDATE users
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    is_chirpy_red = NOT is_chirpy_red
+WHERE
+    id = ?1
+    AND is_chirpy_red <> EXISTS (
+        SELECT 1 FROM subscriptions
+        WHERE user_id = users.id AND status <> 'expired' AND current_period_end > strftime('%Y-%m-%d %H:%M:%f', 'now')
+    )
+`
+
+// Derives is_chirpy_red from the user's subscription
+func (q *Queries) SyncUserChirpyRed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, syncUserChirpyRed, id)
+	return err
+}
+
+const updateSubscriptionStatus = `-- name: UpdateSubscriptionStatus :execrows
+UPDATE subscriptions
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    status = ?1,
+    current_period_end = COALESCE(strftime('%Y-%m-%d %H:%M:%f', ?2), current_period_end)
+WHERE user_id = ?3
+`
+
+type UpdateSubscriptionStatusParams struct {
+	Status           string
+	CurrentPeriodEnd interface{}
+	UserID           uuid.UUID
+}
+
+// A NULL period end keeps the current one
+func (q *Queries) UpdateSubscriptionStatus(ctx context.Context, arg UpdateSubscriptionStatusParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateSubscriptionStatus, arg.Status, arg.CurrentPeriodEnd, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertSubscription = `-- name: UpsertSubscription :execrows
+INSERT INTO subscriptions (user_id, created_at, updated_at, plan, status, current_period_end, polka_customer_id)
+SELECT
+    users.id,
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2,
+    strftime('%Y-%m-%d %H:%M:%f', ?3),
+    ?4
+FROM users
+WHERE users.id = ?5
+ON CONFLICT (user_id) DO UPDATE
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    plan = excluded.plan,
+    status = excluded.status,
+    current_period_end = excluded.current_period_end,
+    polka_customer_id = COALESCE(NULLIF(excluded.polka_customer_id, ''), subscriptions.polka_customer_id)
+`
+
+type UpsertSubscriptionParams struct {
+	Plan             string
+	Status           string
+	CurrentPeriodEnd interface{}
+	PolkaCustomerID  string
+	UserID           uuid.UUID
+}
+
+// Starts or replaces a user's subscription, affecting no rows if the user doesn't exist
+// An empty customer ID keeps the one already stored
+func (q *Queries) UpsertSubscription(ctx context.Context, arg UpsertSubscriptionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upsertSubscription,
+		arg.Plan,
+		arg.Status,
+		arg.CurrentPeriodEnd,
+		arg.PolkaCustomerID,
+		arg.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,204 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: tags.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const addChirpTags = `-- name: AddChirpTags :exec
+INSERT INTO chirp_tags (chirp_id, tag_id, created_at)
+SELECT ?1, id, strftime('%Y-%m-%d %H:%M:%f', 'now')
+FROM tags
+WHERE id IN (/*SLICE:tag_ids*/?)
+ON CONFLICT (chirp_id, tag_id) DO NOTHING
+`
+
+type AddChirpTagsParams struct {
+	ChirpID uuid.UUID
+	TagIds  []uuid.UUID
+}
+
+func (q *Queries) AddChirpTags(ctx context.Context, arg AddChirpTagsParams) error {
+	query := addChirpTags
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.ChirpID)
+	if len(arg.TagIds) > 0 {
+		for _, v := range arg.TagIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:tag_ids*/?", strings.Repeat(",?", len(arg.TagIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:tag_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const deleteStaleChirpTags = `-- name: DeleteStaleChirpTags :exec
+DELETE FROM chirp_tags
+WHERE
+    chirp_id = ?1
+    AND tag_id NOT IN (SELECT id FROM tags WHERE id IN (/*SLICE:keep_tag_ids*/?))
+`
+
+type DeleteStaleChirpTagsParams struct {
+	ChirpID    uuid.UUID
+	KeepTagIds []uuid.UUID
+}
+
+// Removes the chirp's tags other than the ones kept, so kept tags keep their created_at
+// The kept IDs are looked up in tags so an empty list keeps nothing, rather than comparing with NULL
+func (q *Queries) DeleteStaleChirpTags(ctx context.Context, arg DeleteStaleChirpTagsParams) error {
+	query := deleteStaleChirpTags
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.ChirpID)
+	if len(arg.KeepTagIds) > 0 {
+		for _, v := range arg.KeepTagIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:keep_tag_ids*/?", strings.Repeat(",?", len(arg.KeepTagIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:keep_tag_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const listTagChirps = `-- name: ListTagChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+JOIN tags ON tags.id = chirp_tags.tag_id
+WHERE
+    tags.name = ?1
+    AND chirps.deleted_at IS NULL
+    AND (chirps.hidden_at IS NULL OR chirps.user_id = ?2 OR CAST(?3 AS BOOLEAN))
+    AND (
+        ?4 IS NULL
+        OR chirps.created_at < strftime('%Y-%m-%d %H:%M:%f', ?4)
+        OR (chirps.created_at = strftime('%Y-%m-%d %H:%M:%f', ?4) AND chirps.id < ?5)
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT ?6
+`
+
+type ListTagChirpsParams struct {
+	Tag             string
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	CursorCreatedAt interface{}
+	CursorID        uuid.NullUUID
+	PageLimit       int64
+}
+
+func (q *Queries) ListTagChirps(ctx context.Context, arg ListTagChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTagChirps,
+		arg.Tag,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrendingTags = `-- name: ListTrendingTags :many
+SELECT tags.name, COUNT(*) AS chirp_count
+FROM chirp_tags
+JOIN tags ON tags.id = chirp_tags.tag_id
+JOIN chirps ON chirps.id = chirp_tags.chirp_id
+WHERE
+    chirp_tags.created_at >= strftime('%Y-%m-%d %H:%M:%f', 'now', printf('-%f seconds', CAST(?1 AS REAL)))
+    AND chirps.deleted_at IS NULL
+    AND chirps.hidden_at IS NULL
+GROUP BY tags.name
+ORDER BY chirp_count DESC, tags.name ASC
+LIMIT ?2
+`
+
+type ListTrendingTagsParams struct {
+	WindowSeconds float64
+	TagLimit      int64
+}
+
+type ListTrendingTagsRow struct {
+	Name       string
+	ChirpCount int64
+}
+
+// Tags used by the most chirps in the last window_seconds, timed by the database clock
+func (q *Queries) ListTrendingTags(ctx context.Context, arg ListTrendingTagsParams) ([]ListTrendingTagsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTrendingTags, arg.WindowSeconds, arg.TagLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTrendingTagsRow
+	for rows.Next() {
+		var i ListTrendingTagsRow
+		if err := rows.Scan(&i.Name, &i.ChirpCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertTag = `-- name: UpsertTag :one
+INSERT INTO tags (created_at, name)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1
+)
+ON CONFLICT (name) DO UPDATE SET name = excluded.name
+RETURNING id
+`
+
+// Returns the ID of the named tag, creating it if it doesn't exist yet
+func (q *Queries) UpsertTag(ctx context.Context, name string) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, upsertTag, name)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
@@ -0,0 +1,42 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhook_events.sql
+
+package sqlitedb
+
+import (
+	"context"
+)
+
+const recordWebhookEvent = `-- name: RecordWebhookEvent :execrows
+INSERT INTO webhook_events (id, event, received_at, result)
+VALUES (
+    ?1,
+    ?2,
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?3
+)
+ON CONFLICT (id) DO UPDATE
+SET
+    event = excluded.event,
+    received_at = excluded.received_at,
+    result = excluded.result
+WHERE webhook_events.result = 'ignored' AND excluded.result = 'processed'
+`
+
+type RecordWebhookEventParams struct {
+	ID     string
+	Event  string
+	Result string
+}
+
+// Affects no rows if the event was already recorded
+// An ignored event can be recorded again as processed, so it's handled if support is added and Polka resends it
+func (q *Queries) RecordWebhookEvent(ctx context.Context, arg RecordWebhookEventParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, recordWebhookEvent, arg.ID, arg.Event, arg.Result)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
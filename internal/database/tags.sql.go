@@ -0,0 +1,194 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: tags.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const addChirpTags = `-- name: AddChirpTags :exec
+INSERT INTO chirp_tags (chirp_id, tag_id, created_at)
+SELECT $1, tag_id, NOW()
+FROM unnest($2::uuid[]) AS tag_id
+ON CONFLICT (chirp_id, tag_id) DO NOTHING
+`
+
+type AddChirpTagsParams struct {
+	ChirpID uuid.UUID
+	TagIds  []uuid.UUID
+}
+
+func (q *Queries) AddChirpTags(ctx context.Context, arg AddChirpTagsParams) error {
+	_, err := q.db.ExecContext(ctx, addChirpTags, arg.ChirpID, pq.Array(arg.TagIds))
+	return err
+}
+
+const deleteStaleChirpTags = `-- name: DeleteStaleChirpTags :exec
+DELETE FROM chirp_tags
+WHERE
+    chirp_id = $1
+    AND NOT (tag_id = ANY($2::uuid[]))
+`
+
+type DeleteStaleChirpTagsParams struct {
+	ChirpID    uuid.UUID
+	KeepTagIds []uuid.UUID
+}
+
+// Removes the chirp's tags other than the ones kept, so kept tags keep their created_at
+func (q *Queries) DeleteStaleChirpTags(ctx context.Context, arg DeleteStaleChirpTagsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteStaleChirpTags, arg.ChirpID, pq.Array(arg.KeepTagIds))
+	return err
+}
+
+const listTagChirps = `-- name: ListTagChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.in_reply_to, chirps.deleted_at, chirps.like_count, chirps.hidden_at FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+JOIN tags ON tags.id = chirp_tags.tag_id
+WHERE
+    tags.name = $1
+    AND chirps.deleted_at IS NULL
+    AND (chirps.hidden_at IS NULL OR chirps.user_id = $2::uuid OR $3::boolean)
+    AND (
+        $4::timestamp IS NULL
+        OR (chirps.created_at, chirps.id) < ($4::timestamp, $5::uuid)
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $6
+`
+
+type ListTagChirpsParams struct {
+	Tag             string
+	ViewerID        uuid.NullUUID
+	IncludeHidden   bool
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListTagChirps(ctx context.Context, arg ListTagChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTagChirps,
+		arg.Tag,
+		arg.ViewerID,
+		arg.IncludeHidden,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrendingTags = `-- name: ListTrendingTags :many
+SELECT tags.name, COUNT(*) AS chirp_count
+FROM chirp_tags
+JOIN tags ON tags.id = chirp_tags.tag_id
+JOIN chirps ON chirps.id = chirp_tags.chirp_id
+WHERE
+    chirp_tags.created_at >= NOW() - make_interval(secs => $1::float8)
+    AND chirps.deleted_at IS NULL
+    AND chirps.hidden_at IS NULL
+GROUP BY tags.name
+ORDER BY chirp_count DESC, tags.name ASC
+LIMIT $2
+`
+
+type ListTrendingTagsParams struct {
+	WindowSeconds float64
+	TagLimit      int32
+}
+
+type ListTrendingTagsRow struct {
+	Name       string
+	ChirpCount int64
+}
+
+// Tags used by the most chirps in the last window_seconds, timed by the database clock
+func (q *Queries) ListTrendingTags(ctx context.Context, arg ListTrendingTagsParams) ([]ListTrendingTagsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTrendingTags, arg.WindowSeconds, arg.TagLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTrendingTagsRow
+	for rows.Next() {
+		var i ListTrendingTagsRow
+		if err := rows.Scan(&i.Name, &i.ChirpCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertTags = `-- name: UpsertTags :many
+INSERT INTO tags (id, created_at, name)
+SELECT gen_random_uuid(), NOW(), name
+FROM unnest($1::text[]) AS name
+ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+RETURNING id
+`
+
+// Returns the IDs of the named tags, creating any that don't exist yet
+func (q *Queries) UpsertTags(ctx context.Context, names []string) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, upsertTags, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -7,26 +7,59 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const bootstrapAdmin = `-- name: BootstrapAdmin :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, role)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    'admin'
+)
+ON CONFLICT (email) DO UPDATE
+SET
+    updated_at = NOW(),
+    role = 'admin'
+RETURNING id
+`
+
+type BootstrapAdminParams struct {
+	Email          string
+	HashedPassword string
+}
+
+// Creates the admin user, or promotes an existing user with the same email
+func (q *Queries) BootstrapAdmin(ctx context.Context, arg BootstrapAdminParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, bootstrapAdmin, arg.Email, arg.HashedPassword)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
    gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, email,is_chirpy_red
+RETURNING id, created_at, updated_at, email,is_chirpy_red, handle
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 }
 
 type CreateUserRow struct {
@@ -35,10 +68,11 @@ type CreateUserRow struct {
 	UpdatedAt   time.Time
 	Email       string
 	IsChirpyRed bool
+	Handle      sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Handle)
 	var i CreateUserRow
 	err := row.Scan(
 		&i.ID,
@@ -46,10 +80,96 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateU
 		&i.UpdatedAt,
 		&i.Email,
 		&i.IsChirpyRed,
+		&i.Handle,
 	)
 	return i, err
 }
 
+const getUserAccess = `-- name: GetUserAccess :one
+SELECT role, suspended_at FROM users
+WHERE id = $1
+`
+
+type GetUserAccessRow struct {
+	Role        string
+	SuspendedAt sql.NullTime
+}
+
+func (q *Queries) GetUserAccess(ctx context.Context, id uuid.UUID) (GetUserAccessRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserAccess, id)
+	var i GetUserAccessRow
+	err := row.Scan(&i.Role, &i.SuspendedAt)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, is_chirpy_red, handle FROM users
+WHERE LOWER(handle) = LOWER($1)
+`
+
+type GetUserByHandleRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Email       string
+	IsChirpyRed bool
+	Handle      sql.NullString
+}
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle string) (GetUserByHandleRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i GetUserByHandleRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+		&i.Handle,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, is_chirpy_red, handle FROM users
+WHERE id = $1
+`
+
+type GetUserByIDRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Email       string
+	IsChirpyRed bool
+	Handle      sql.NullString
+}
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i GetUserByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+		&i.Handle,
+	)
+	return i, err
+}
+
+const isUserSuspended = `-- name: IsUserSuspended :one
+SELECT (suspended_at IS NOT NULL)::boolean AS suspended FROM users
+WHERE id = $1
+`
+
+func (q *Queries) IsUserSuspended(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isUserSuspended, id)
+	var suspended bool
+	err := row.Scan(&suspended)
+	return suspended, err
+}
+
 const resetUsers = `-- name: ResetUsers :exec
 DELETE FROM users *
 `
@@ -59,20 +179,72 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
-const updateUser = `-- name: UpdateUser :one
+const setUserRole = `-- name: SetUserRole :execrows
 UPDATE users
 SET
     updated_at = NOW(),
-    email = $2,
-    hashed_password = $3
+    role = $2
 WHERE id = $1
-RETURNING id, created_at, updated_at, email,is_chirpy_red
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserRole, arg.ID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    suspended_at = NOW()
+WHERE id = $1 AND suspended_at IS NULL
+`
+
+func (q *Queries) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, id)
+	return err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :execrows
+UPDATE users
+SET
+    updated_at = NOW(),
+    suspended_at = NULL
+WHERE id = $1 AND suspended_at IS NOT NULL
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unsuspendUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET
+    updated_at = NOW(),
+    email = $1,
+    hashed_password = $2,
+    handle = COALESCE($3, handle)
+WHERE id = $4
+RETURNING id, created_at, updated_at, email,is_chirpy_red, handle
 `
 
 type UpdateUserParams struct {
-	ID             uuid.UUID
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
+	ID             uuid.UUID
 }
 
 type UpdateUserRow struct {
@@ -81,10 +253,17 @@ type UpdateUserRow struct {
 	UpdatedAt   time.Time
 	Email       string
 	IsChirpyRed bool
+	Handle      sql.NullString
 }
 
+// The handle is only changed when a new one is given
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UpdateUserRow, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.ID, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Handle,
+		arg.ID,
+	)
 	var i UpdateUserRow
 	err := row.Scan(
 		&i.ID,
@@ -92,25 +271,13 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UpdateU
 		&i.UpdatedAt,
 		&i.Email,
 		&i.IsChirpyRed,
+		&i.Handle,
 	)
 	return i, err
 }
 
-const upgradeUserToChirpyRed = `-- name: UpgradeUserToChirpyRed :exec
-UPDATE users
-SET
-    updated_at = NOW(),
-    is_chirpy_red = TRUE
-WHERE id = $1
-`
-
-func (q *Queries) UpgradeUserToChirpyRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUserToChirpyRed, id)
-	return err
-}
-
 const userLogin = `-- name: UserLogin :one
-SELECT id, created_at, updated_at, email, hashed_password,is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password,is_chirpy_red, handle, suspended_at, role FROM users
 WHERE email = $1
 `
 
@@ -124,6 +291,9 @@ func (q *Queries) UserLogin(ctx context.Context, email string) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.SuspendedAt,
+		&i.Role,
 	)
 	return i, err
 }
@@ -0,0 +1,59 @@
+// Package entitlements defines what each kind of account is allowed to do.
+//
+// Handlers ask for a user's Plan and check it, rather than testing
+// is_chirpy_red themselves, so a new premium feature only needs a Feature
+// here and a check where it's used.
+package entitlements
+
+import "slices"
+
+// Feature is something only some plans include
+type Feature string
+
+const (
+	EditChirps     Feature = "edit_chirps"
+	ScheduleChirps Feature = "schedule_chirps"
+)
+
+// Plan is what an account is entitled to
+type Plan struct {
+	Name string
+
+	// Longest chirp body allowed, in bytes
+	MaxChirpLength int
+
+	// How many times the standard per-user rate limits the plan gets
+	RateLimitMultiplier int
+
+	features []Feature
+}
+
+var (
+	// Free is every account without Chirpy Red
+	Free = Plan{
+		Name:                "free",
+		MaxChirpLength:      140,
+		RateLimitMultiplier: 1,
+	}
+
+	// Red is every account with a current Chirpy Red subscription
+	Red = Plan{
+		Name:                "chirpy_red",
+		MaxChirpLength:      280,
+		RateLimitMultiplier: 5,
+		features:            []Feature{EditChirps, ScheduleChirps},
+	}
+)
+
+// For returns the plan of a user, given whether they have Chirpy Red
+func For(isChirpyRed bool) Plan {
+	if isChirpyRed {
+		return Red
+	}
+	return Free
+}
+
+// Allows reports whether the plan includes a feature
+func (p Plan) Allows(feature Feature) bool {
+	return slices.Contains(p.features, feature)
+}
@@ -0,0 +1,33 @@
+package entitlements
+
+import "testing"
+
+func TestPlans(t *testing.T) {
+	tests := []struct {
+		name        string
+		isChirpyRed bool
+		feature     Feature
+		want        bool
+	}{
+		{"free can't edit", false, EditChirps, false},
+		{"free can't schedule", false, ScheduleChirps, false},
+		{"red can edit", true, EditChirps, true},
+		{"red can schedule", true, ScheduleChirps, true},
+		{"unknown feature", true, Feature("time_travel"), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := For(tc.isChirpyRed).Allows(tc.feature); got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	// Red should never get less than free
+	if Red.MaxChirpLength <= Free.MaxChirpLength {
+		t.Errorf("Expected Red to allow longer chirps than %d, got %d", Free.MaxChirpLength, Red.MaxChirpLength)
+	}
+	if Red.RateLimitMultiplier <= Free.RateLimitMultiplier {
+		t.Errorf("Expected Red to have higher rate limits than %dx, got %dx", Free.RateLimitMultiplier, Red.RateLimitMultiplier)
+	}
+}
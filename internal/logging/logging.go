@@ -0,0 +1,131 @@
+// Package logging sets up structured logging with per-request IDs and an access log.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Header used to pass request IDs between services
+const RequestIDHeader = "X-Request-ID"
+
+// Request IDs sent by clients are only honored if they look like this
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type contextKey struct{}
+
+// Per-request state kept in the context
+// Handlers fill in the user ID once they know it, so the access log can include it
+type requestState struct {
+	requestID string
+
+	mu     sync.Mutex
+	userID uuid.UUID
+}
+
+// RequestID returns the ID of the request the context belongs to, or "" outside a request
+func RequestID(ctx context.Context) string {
+	if state, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		return state.requestID
+	}
+	return ""
+}
+
+// SetUserID records the authenticated user for the request's access log line
+func SetUserID(ctx context.Context, userID uuid.UUID) {
+	if state, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		state.mu.Lock()
+		state.userID = userID
+		state.mu.Unlock()
+	}
+}
+
+// Handler is a slog.Handler that adds the request ID from the context to every record
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler wraps a slog.Handler so records logged with a request context carry its ID
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
+
+// Middleware gives each request an ID and writes an access log line once it's done
+// The ID comes from the X-Request-ID header when it's well formed, and is echoed in the response
+// It must wrap the ServeMux itself, so the matched route pattern is known once the mux returns
+func Middleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		state := &requestState{requestID: requestID}
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, state))
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", r.Pattern),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		state.mu.Lock()
+		if state.userID != uuid.Nil {
+			attrs = append(attrs, slog.String("user_id", state.userID.String()))
+		}
+		state.mu.Unlock()
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+	})
+}
+
+// statusWriter remembers the status code written to a response
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
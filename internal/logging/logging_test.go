@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Decode the JSON log lines written to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	lines := []map[string]any{}
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		line := map[string]any{}
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("Error decoding log line: %s", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestMiddleware(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name      string
+		requestID string
+		wantSame  bool
+	}{
+		{"honors header", "abc-123", true},
+		{"generates when missing", "", false},
+		{"replaces malformed", "bad id\n", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			logger := slog.New(NewHandler(slog.NewJSONHandler(buf, nil)))
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+				SetUserID(r.Context(), userID)
+				logger.ErrorContext(r.Context(), "Error retrieving chirp")
+				w.WriteHeader(404)
+			})
+
+			req := httptest.NewRequest("GET", "/api/chirps/1", nil)
+			if tc.requestID != "" {
+				req.Header.Set(RequestIDHeader, tc.requestID)
+			}
+			rec := httptest.NewRecorder()
+			Middleware(logger, mux).ServeHTTP(rec, req)
+
+			gotID := rec.Header().Get(RequestIDHeader)
+			if tc.wantSame && gotID != tc.requestID {
+				t.Errorf("Expected request ID %q, got %q", tc.requestID, gotID)
+			}
+			if !tc.wantSame && (gotID == "" || gotID == tc.requestID) {
+				t.Errorf("Expected a generated request ID, got %q", gotID)
+			}
+
+			lines := logLines(t, buf)
+			if len(lines) != 2 {
+				t.Fatalf("Expected 2 log lines, got %d", len(lines))
+			}
+			for _, line := range lines {
+				if line["request_id"] != gotID {
+					t.Errorf("Expected request_id %q on %q, got %v", gotID, line["msg"], line["request_id"])
+				}
+			}
+
+			access := lines[1]
+			if access["route"] != "GET /api/chirps/{chirpID}" {
+				t.Errorf("Expected route pattern, got %v", access["route"])
+			}
+			if access["status"] != float64(404) {
+				t.Errorf("Expected status 404, got %v", access["status"])
+			}
+			if access["user_id"] != userID.String() {
+				t.Errorf("Expected user_id %s, got %v", userID, access["user_id"])
+			}
+		})
+	}
+}
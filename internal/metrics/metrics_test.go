@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsByRoutePattern(t *testing.T) {
+	m := New(nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	})
+	handler := m.Middleware(mux)
+
+	for _, path := range []string{"/api/chirps/1", "/api/chirps/2", "/nowhere"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	got := testutil.ToFloat64(m.requests.WithLabelValues("GET /api/chirps/{chirpID}", "GET", "404"))
+	if got != 2 {
+		t.Errorf("Expected 2 requests for the chirp route, got %v", got)
+	}
+	got = testutil.ToFloat64(m.requests.WithLabelValues(unmatchedRoute, "GET", "404"))
+	if got != 1 {
+		t.Errorf("Expected 1 unmatched request, got %v", got)
+	}
+}
+
+func TestHandlerExposesMetrics(t *testing.T) {
+	m := New(nil)
+	m.ChirpsCreated.Inc()
+	m.Logins.WithLabelValues("succeeded").Inc()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"chirpy_chirps_created_total 1",
+		`chirpy_logins_total{result="succeeded"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected output to contain %q", want)
+		}
+	}
+	if CounterValue(m.ChirpsCreated) != 1 {
+		t.Errorf("Expected CounterValue of 1, got %v", CounterValue(m.ChirpsCreated))
+	}
+}
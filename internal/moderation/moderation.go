@@ -0,0 +1,243 @@
+// Package moderation checks chirp text against a list of banned words.
+//
+// Words are matched after normalizing both the rule and the text, so
+// "KERFUFFLE!", "kerfüffle" and "k3rfuffl3" all match a rule for "kerfuffle".
+// Each rule carries an action: mask the word, reject the whole text, or
+// flag it for a moderator to review.
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Action to take when a rule matches
+type Action string
+
+const (
+	ActionMask   Action = "mask"
+	ActionFlag   Action = "flag"
+	ActionReject Action = "reject"
+)
+
+// Replacement for masked words
+const Mask = "****"
+
+// Rule is a banned word and what to do when it's used
+type Rule struct {
+	Word   string
+	Action Action
+}
+
+// Match is a rule that matched somewhere in the checked text
+type Match struct {
+	Word   string
+	Action Action
+}
+
+// Result of checking a piece of text
+type Result struct {
+	// Text with every masked word replaced by Mask
+	Text    string
+	Matches []Match
+}
+
+// Engine holds the active rules. It is safe for concurrent use, and the
+// rules can be swapped at any time with SetRules.
+type Engine struct {
+	mu    sync.RWMutex
+	rules map[string][]indexedRule // by collapsed word
+}
+
+// A rule with the run lengths of its normalized word
+type indexedRule struct {
+	Rule
+	runs []int
+}
+
+// Parse an action name, as used in word files and the admin API
+func ParseAction(s string) (Action, error) {
+	switch a := Action(strings.ToLower(strings.TrimSpace(s))); a {
+	case ActionMask, ActionFlag, ActionReject:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown moderation action %q", s)
+	}
+}
+
+// Create an engine with an initial set of rules
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{}
+	e.SetRules(rules)
+	return e
+}
+
+// Replace the active rules
+// If several rules normalize to the same word, the strictest action wins
+func (e *Engine) SetRules(rules []Rule) {
+	byWord := make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		word := Normalize(rule.Word)
+		if word == "" {
+			continue
+		}
+		if existing, ok := byWord[word]; ok && severity(existing.Action) >= severity(rule.Action) {
+			continue
+		}
+		byWord[word] = rule
+	}
+	byKey := make(map[string][]indexedRule, len(byWord))
+	for word, rule := range byWord {
+		key, runs := collapse(word)
+		byKey[key] = append(byKey[key], indexedRule{Rule: rule, runs: runs})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = byKey
+}
+
+// Find the rule a word matches, if any
+// A word matches a rule when it's the rule's word with letters repeated, so "kerrrfuffle"
+// matches "kerfuffle" but "pop" doesn't match "poop". The strictest matching rule wins.
+func (e *Engine) lookup(word string) (Rule, bool) {
+	key, runs := collapse(Normalize(word))
+	var match Rule
+	found := false
+	for _, rule := range e.rules[key] {
+		if stretches(runs, rule.runs) && (!found || severity(rule.Action) > severity(match.Action)) {
+			match, found = rule.Rule, true
+		}
+	}
+	return match, found
+}
+
+// Check text against the rules, masking words as needed
+func (e *Engine) Check(text string) Result {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := Result{}
+	var out strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			out.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		// Find the end of this token
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		out.WriteString(e.checkToken(runes[i:j], &result))
+		i = j
+	}
+	result.Text = out.String()
+	return result
+}
+
+// Check a single token, returning it with any masking applied
+// The whole token is tried first, then the token with leading and trailing
+// symbols trimmed, so "kerfuffle!" masks to "****!" while "sh@rbert" still matches.
+func (e *Engine) checkToken(token []rune, result *Result) string {
+	if rule, ok := e.lookup(string(token)); ok {
+		return applyRule(rule, string(token), result)
+	}
+
+	start, end := 0, len(token)
+	for start < end && !isLetterOrDigit(token[start]) {
+		start++
+	}
+	for end > start && !isLetterOrDigit(token[end-1]) {
+		end--
+	}
+	if start == end || (start == 0 && end == len(token)) {
+		return string(token)
+	}
+	if rule, ok := e.lookup(string(token[start:end])); ok {
+		return string(token[:start]) + applyRule(rule, string(token[start:end]), result) + string(token[end:])
+	}
+	return string(token)
+}
+
+// Record a matched rule and return what the word should be replaced with
+func applyRule(rule Rule, word string, result *Result) string {
+	result.Matches = append(result.Matches, Match{Word: rule.Word, Action: rule.Action})
+	if rule.Action == ActionMask {
+		return Mask
+	}
+	return word
+}
+
+// Whether any matched rule rejects the text
+func (r Result) Rejected() bool {
+	return r.has(ActionReject)
+}
+
+// Whether any matched rule flags the text for review
+func (r Result) Flagged() bool {
+	return r.has(ActionFlag)
+}
+
+func (r Result) has(action Action) bool {
+	for _, match := range r.Matches {
+		if match.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Load rules from a word file
+// Each line holds a word and an optional action, defaulting to mask.
+// Blank lines and lines starting with # are ignored.
+func LoadRulesFile(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open moderation word file: %w", err)
+	}
+	defer f.Close()
+
+	rules := []Rule{}
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		rule := Rule{Word: fields[0], Action: ActionMask}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("%s:%d: expected a word and an optional action", path, lineNum)
+		}
+		if len(fields) == 2 {
+			rule.Action, err = ParseAction(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read moderation word file: %w", err)
+	}
+	return rules, nil
+}
+
+func severity(a Action) int {
+	switch a {
+	case ActionReject:
+		return 3
+	case ActionFlag:
+		return 2
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,152 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func defaultRules() []Rule {
+	return []Rule{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "sharbert", Action: ActionMask},
+		{Word: "fornax", Action: ActionMask},
+		{Word: "zorp", Action: ActionReject},
+		{Word: "blorb", Action: ActionFlag},
+	}
+}
+
+func TestCheckMasking(t *testing.T) {
+	engine := NewEngine(defaultRules())
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"clean", "This is a kerfuffle opinion I need to share", "This is a **** opinion I need to share"},
+		{"uppercase", "I hear Mastodon is better than Chirpy. sharbert I need to migrate", "I hear Mastodon is better than Chirpy. **** I need to migrate"},
+		{"punctuation", "What a kerfuffle! Fornax, really?", "What a ****! ****, really?"},
+		{"multiple spaces", "oh  kerfuffle   fornax", "oh  ****   ****"},
+		{"accents", "what a kerfüffle", "what a ****"},
+		{"leetspeak", "k3rfuffl3 and sh@rb3rt", "**** and ****"},
+		{"homoglyphs", "fоrnаx", "****"},
+		{"fullwidth", "ｋｅｒｆｕｆｆｌｅ", "****"},
+		{"repeated letters", "kerrrfuffffle", "****"},
+		{"apostrophe", "the kerfuffle's end", "the ****'s end"},
+		{"no partial matches", "kerfufflement fornaxes", "kerfufflement fornaxes"},
+		{"hashtag and mention", "#kerfuffle @sharbert", "#**** @****"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := engine.Check(tc.text)
+			if result.Text != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, result.Text)
+			}
+		})
+	}
+}
+
+func TestCheckActions(t *testing.T) {
+	engine := NewEngine(defaultRules())
+
+	result := engine.Check("zorp off")
+	if !result.Rejected() {
+		t.Errorf("Expected text to be rejected")
+	}
+	if result.Text != "zorp off" {
+		t.Errorf("Rejected words should not be masked, got %q", result.Text)
+	}
+
+	result = engine.Check("a blorb appears")
+	if !result.Flagged() || result.Rejected() {
+		t.Errorf("Expected text to be flagged only, got %+v", result.Matches)
+	}
+	if result.Text != "a blorb appears" {
+		t.Errorf("Flagged words should not be masked, got %q", result.Text)
+	}
+
+	result = engine.Check("nothing to see here")
+	if len(result.Matches) != 0 {
+		t.Errorf("Expected no matches, got %+v", result.Matches)
+	}
+}
+
+func TestCheckRepeatedLetters(t *testing.T) {
+	engine := NewEngine([]Rule{
+		{Word: "ass", Action: ActionMask},
+		{Word: "poop", Action: ActionMask},
+	})
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"stretched", "asssss and pooooop", "**** and ****"},
+		{"shorter runs", "as a pop song", "as a pop song"},
+		{"doubled leetspeak", "p00p", "****"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := engine.Check(tc.text).Text; got != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	// A shorter word with the same letters isn't masked by the longer rule
+	engine = NewEngine([]Rule{{Word: "books", Action: ActionMask}})
+	if got := engine.Check("boks").Text; got != "boks" {
+		t.Errorf("Expected boks not to match books, got %q", got)
+	}
+}
+
+func TestSetRulesStrictestWins(t *testing.T) {
+	engine := NewEngine([]Rule{
+		{Word: "Fornax", Action: ActionReject},
+		{Word: "f0rnax", Action: ActionMask},
+	})
+	if !engine.Check("fornax").Rejected() {
+		t.Errorf("Expected the reject rule to win")
+	}
+
+	engine.SetRules(nil)
+	if len(engine.Check("fornax").Matches) != 0 {
+		t.Errorf("Expected no matches after clearing rules")
+	}
+}
+
+func TestLoadRulesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	content := "# banned words\nkerfuffle\n\nzorp reject\nblorb FLAG\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Error writing word file: %s", err)
+	}
+
+	rules, err := LoadRulesFile(path)
+	if err != nil {
+		t.Fatalf("Error loading word file: %s", err)
+	}
+	want := []Rule{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "zorp", Action: ActionReject},
+		{Word: "blorb", Action: ActionFlag},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("Expected %d rules, got %d", len(want), len(rules))
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("Expected rule %+v, got %+v", want[i], rules[i])
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("zorp obliterate\n"), 0o600); err != nil {
+		t.Fatalf("Error writing word file: %s", err)
+	}
+	if _, err := LoadRulesFile(path); err == nil {
+		t.Errorf("Expected an error for an unknown action")
+	}
+}
@@ -0,0 +1,87 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Look-alike letters from other scripts, mapped to the Latin letter they imitate
+var homoglyphs = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+}
+
+// Digits and symbols commonly swapped in for letters
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b',
+	'@': 'a', '$': 's', '!': 'i', '|': 'l', '+': 't', '€': 'e',
+}
+
+// Normalize a word for matching
+// Compatibility forms and accents are removed, the word is lowercased, and homoglyphs
+// and leetspeak are mapped back to plain letters. Repeated letters are kept - see collapse.
+func Normalize(word string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(word) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if mapped, ok := homoglyphs[r]; ok {
+			r = mapped
+		} else if mapped, ok := leetspeak[r]; ok {
+			r = mapped
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Collapse each run of a repeated letter in a normalized word to one letter, returning
+// the collapsed word and the length of each run
+// Words with the same collapsed form are told apart by their runs, so "keeerfuffle"
+// can match "kerfuffle" without "as" matching "ass".
+func collapse(word string) (string, []int) {
+	var b strings.Builder
+	runs := []int{}
+	var last rune
+	for i, r := range []rune(word) {
+		if i > 0 && r == last {
+			runs[len(runs)-1]++
+			continue
+		}
+		b.WriteRune(r)
+		runs = append(runs, 1)
+		last = r
+	}
+	return b.String(), runs
+}
+
+// Whether a word's runs stretch a rule's runs, repeating letters but never dropping them
+func stretches(runs, ruleRuns []int) bool {
+	for i := range ruleRuns {
+		if runs[i] < ruleRuns[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether a rune can be part of a word, including leetspeak symbols
+func isWordRune(r rune) bool {
+	_, leet := leetspeak[r]
+	return isLetterOrDigit(r) || leet || unicode.Is(unicode.Mn, r)
+}
+
+func isLetterOrDigit(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
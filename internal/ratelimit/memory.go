@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps buckets in memory, so each node limits requests on its own.
+// It is safe for concurrent use.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+var _ Backend = (*Memory)(nil)
+
+// Create a backend with no buckets
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]bucket{}, now: time.Now}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+
+	// Refill the bucket for the time since it was last used - a new bucket starts full
+	tokens := float64(limit.Burst)
+	if b, ok := m.buckets[key]; ok {
+		tokens = min(tokens, b.tokens+now.Sub(b.updatedAt).Seconds()*limit.rate())
+	}
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	m.buckets[key] = bucket{tokens: tokens, updatedAt: now}
+	return resultFor(tokens, allowed, limit), nil
+}
+
+func (m *Memory) Prune(ctx context.Context, idle time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cutoff := m.now().Add(-idle)
+	var pruned int64
+	for key, b := range m.buckets {
+		if b.updatedAt.Before(cutoff) {
+			delete(m.buckets, key)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/database"
+)
+
+// Postgres keeps buckets in the rate_limit_buckets table, so replicas share them.
+// Each request is a single upsert, timed by the database clock.
+type Postgres struct {
+	q *database.Queries
+}
+
+var _ Backend = (*Postgres)(nil)
+
+// Create a backend using a Postgres connection pool
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{q: database.New(db)}
+}
+
+func (p *Postgres) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	row, err := p.q.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: float64(limit.Burst),
+		Rate:  limit.rate(),
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return resultFor(row.Tokens, row.Allowed, limit), nil
+}
+
+func (p *Postgres) Prune(ctx context.Context, idle time.Duration) (int64, error) {
+	return p.q.DeleteIdleRateLimitBuckets(ctx, idle.Seconds())
+}
@@ -0,0 +1,157 @@
+// Package ratelimit limits request rates with token buckets.
+//
+// Buckets are kept by a Backend: Memory for a single node, or Postgres when
+// several replicas need to share them. Which requests share a bucket, and
+// which limit applies, is up to the caller.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket that holds Burst requests and refills completely over Per
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// Tokens added per second
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+// Scale returns the limit with n times the burst, refilled over the same period, so n times the rate
+func (l Limit) Scale(n int) Limit {
+	return Limit{Burst: l.Burst * n, Per: l.Per}
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Burst, l.Per)
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed bool
+
+	// Size of the bucket, and whole tokens left in it
+	Limit     int
+	Remaining int
+
+	// How long until the next token, when the request wasn't allowed
+	RetryAfter time.Duration
+
+	// How long until the bucket is full again
+	Reset time.Duration
+}
+
+// Work out the result from the tokens left after a request, and whether it took one
+func resultFor(tokens float64, allowed bool, limit Limit) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.rate()),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / limit.rate())
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// SetHeaders adds the X-RateLimit-* headers, and Retry-After when the request wasn't allowed
+// Times are in whole seconds, rounded up so clients don't come back too early
+func SetHeaders(h http.Header, res Result) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Backend keeps token buckets
+type Backend interface {
+	// Take a token from the bucket with key, creating it full if it doesn't exist
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+
+	// Prune forgets buckets unused for longer than idle, which should be at least the longest Limit.Per
+	Prune(ctx context.Context, idle time.Duration) (int64, error)
+}
+
+// Rules maps route patterns, as registered on the ServeMux, to their limits
+type Rules map[string]Limit
+
+// Longest returns the longest refill period of any rule
+func (r Rules) Longest() time.Duration {
+	longest := time.Duration(0)
+	for _, limit := range r {
+		longest = max(longest, limit.Per)
+	}
+	return longest
+}
+
+// ParseRules reads rules written as comma-separated pattern=burst/period entries,
+// such as "POST /api/login=5/1m, POST /api/chirps=30/1m", on top of base
+// A limit of off removes the route's rule from base
+func ParseRules(s string, base Rules) (Rules, error) {
+	rules := Rules{}
+	for pattern, limit := range base {
+		rules[pattern] = limit
+	}
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("rate limit %q must look like pattern=burst/period", entry)
+		}
+		pattern, value := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		if pattern == "" {
+			return nil, fmt.Errorf("rate limit %q is missing its route pattern", entry)
+		}
+		if value == "off" {
+			delete(rules, pattern)
+			continue
+		}
+		limit, err := parseLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit for %s: %w", pattern, err)
+		}
+		rules[pattern] = limit
+	}
+	return rules, nil
+}
+
+// Parse a limit written as burst/period, such as 5/1m
+func parseLimit(s string) (Limit, error) {
+	burst, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, errors.New("limit must look like burst/period, such as 5/1m")
+	}
+	n, err := strconv.Atoi(burst)
+	if err != nil || n < 1 {
+		return Limit{}, errors.New("burst must be a positive integer")
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, errors.New("period must be a positive duration such as 1m")
+	}
+	return Limit{Burst: n, Per: d}, nil
+}
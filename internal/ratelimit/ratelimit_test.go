@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryTake(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	limit := Limit{Burst: 3, Per: time.Minute}
+	ctx := context.Background()
+
+	// A new bucket starts full
+	for i := range 3 {
+		res, err := m.Take(ctx, "ip:192.0.2.1", limit)
+		if err != nil {
+			t.Fatalf("Error taking a token: %s", err)
+		}
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("Expected request %d allowed with %d remaining, got %+v", i+1, 2-i, res)
+		}
+	}
+	res, _ := m.Take(ctx, "ip:192.0.2.1", limit)
+	if res.Allowed {
+		t.Fatalf("Expected the fourth request to be denied")
+	}
+	if res.RetryAfter != 20*time.Second {
+		t.Errorf("Expected to retry after 20s, got %s", res.RetryAfter)
+	}
+	if res.Reset != time.Minute {
+		t.Errorf("Expected the bucket to be full after 1m, got %s", res.Reset)
+	}
+
+	// Other keys have their own buckets
+	if res, _ := m.Take(ctx, "ip:192.0.2.2", limit); !res.Allowed {
+		t.Errorf("Expected another key to be allowed")
+	}
+
+	// Tokens refill over time, but never past the burst
+	now = now.Add(20 * time.Second)
+	if res, _ := m.Take(ctx, "ip:192.0.2.1", limit); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("Expected a refilled token to be allowed, got %+v", res)
+	}
+	now = now.Add(time.Hour)
+	if res, _ := m.Take(ctx, "ip:192.0.2.1", limit); res.Remaining != 2 {
+		t.Errorf("Expected 2 remaining after a long wait, got %d", res.Remaining)
+	}
+}
+
+func TestMemoryPrune(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+	limit := Limit{Burst: 1, Per: time.Minute}
+
+	m.Take(ctx, "old", limit)
+	now = now.Add(2 * time.Minute)
+	m.Take(ctx, "new", limit)
+
+	pruned, err := m.Prune(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("Error pruning: %s", err)
+	}
+	if pruned != 1 {
+		t.Errorf("Expected 1 bucket pruned, got %d", pruned)
+	}
+	if res, _ := m.Take(ctx, "new", limit); res.Allowed {
+		t.Errorf("Expected the recent bucket to be kept")
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	h := http.Header{}
+	SetHeaders(h, Result{Allowed: false, Limit: 5, Remaining: 0, RetryAfter: 100 * time.Millisecond, Reset: 1500 * time.Millisecond})
+	want := map[string]string{
+		"X-RateLimit-Limit":     "5",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     "2",
+		"Retry-After":           "1",
+	}
+	for name, value := range want {
+		if got := h.Get(name); got != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got)
+		}
+	}
+
+	h = http.Header{}
+	SetHeaders(h, Result{Allowed: true, Limit: 5, Remaining: 4})
+	if got := h.Get("Retry-After"); got != "" {
+		t.Errorf("Expected no Retry-After on an allowed request, got %q", got)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	base := Rules{
+		"POST /api/login":  {Burst: 5, Per: time.Minute},
+		"POST /api/chirps": {Burst: 30, Per: time.Minute},
+	}
+
+	rules, err := ParseRules("POST /api/login=3/30s, POST /api/chirps=off, GET /api/chirps/{chirpID}=100/1h", base)
+	if err != nil {
+		t.Fatalf("Error parsing rules: %s", err)
+	}
+	want := Rules{
+		"POST /api/login":           {Burst: 3, Per: 30 * time.Second},
+		"GET /api/chirps/{chirpID}": {Burst: 100, Per: time.Hour},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, rules)
+	}
+	for pattern, limit := range want {
+		if rules[pattern] != limit {
+			t.Errorf("Expected %s for %s, got %s", limit, pattern, rules[pattern])
+		}
+	}
+	if len(base) != 2 || base["POST /api/login"].Burst != 5 {
+		t.Errorf("Expected the base rules to be left alone, got %v", base)
+	}
+	if rules.Longest() != time.Hour {
+		t.Errorf("Expected the longest period to be 1h, got %s", rules.Longest())
+	}
+
+	for _, s := range []string{"POST /api/login", "=5/1m", "POST /api/login=5", "POST /api/login=0/1m", "POST /api/login=5/soon", "POST /api/login=5/-1m"} {
+		if _, err := ParseRules(s, base); err == nil {
+			t.Errorf("Expected an error parsing %q", s)
+		}
+	}
+}
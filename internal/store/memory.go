@@ -0,0 +1,1670 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// How long refresh tokens last, as in the CreateRToken query
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
+// Errors for the constraints the Postgres schema enforces
+var (
+	errDuplicateEmail  = errors.New("store: email already in use")
+	errDuplicateHandle = errors.New("store: handle already in use")
+	errDuplicateToken  = errors.New("store: refresh token already exists")
+	errInvalidRole     = errors.New("store: invalid role")
+	errInvalidAction   = errors.New("store: invalid moderation action")
+	errSelfFollow      = errors.New("store: users can't follow themselves")
+)
+
+// Words seeded by the moderation_words migration
+var defaultModerationWords = []string{"kerfuffle", "sharbert", "fornax"}
+
+type pairKey struct {
+	a, b uuid.UUID
+}
+
+type subscriptionEventKey struct {
+	subscriptionID uuid.UUID
+	event          string
+}
+
+// The tables, keyed by primary key
+type memoryData struct {
+	users           map[uuid.UUID]database.User
+	chirps          map[uuid.UUID]database.Chirp
+	revisions       map[uuid.UUID]database.ChirpRevision
+	likes           map[pairKey]time.Time // user, chirp
+	follows         map[pairKey]time.Time // follower, followee
+	tags            map[string]database.Tag
+	chirpTags       map[pairKey]time.Time // chirp, tag
+	mentions        map[pairKey]time.Time // chirp, user
+	moderationWords map[string]database.ModerationWord
+	moderationFlags map[uuid.UUID]database.ModerationFlag
+	reports         map[uuid.UUID]database.Report
+	refreshTokens   map[string]database.RefreshToken
+	deniedTokens    map[uuid.UUID]time.Time // ID, expiry
+	webhookEvents   map[string]database.WebhookEvent
+	subscriptions   map[uuid.UUID]database.Subscription
+	scheduledChirps map[uuid.UUID]database.ScheduledChirp
+
+	webhookSubscriptions      map[uuid.UUID]database.WebhookSubscription
+	webhookSubscriptionEvents map[subscriptionEventKey]bool
+	webhookDeliveries         map[uuid.UUID]database.WebhookDelivery
+	webhookDeliveryAttempts   map[uuid.UUID]database.WebhookDeliveryAttempt
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		users:           map[uuid.UUID]database.User{},
+		chirps:          map[uuid.UUID]database.Chirp{},
+		revisions:       map[uuid.UUID]database.ChirpRevision{},
+		likes:           map[pairKey]time.Time{},
+		follows:         map[pairKey]time.Time{},
+		tags:            map[string]database.Tag{},
+		chirpTags:       map[pairKey]time.Time{},
+		mentions:        map[pairKey]time.Time{},
+		moderationWords: map[string]database.ModerationWord{},
+		moderationFlags: map[uuid.UUID]database.ModerationFlag{},
+		reports:         map[uuid.UUID]database.Report{},
+		refreshTokens:   map[string]database.RefreshToken{},
+		deniedTokens:    map[uuid.UUID]time.Time{},
+		webhookEvents:   map[string]database.WebhookEvent{},
+		subscriptions:   map[uuid.UUID]database.Subscription{},
+		scheduledChirps: map[uuid.UUID]database.ScheduledChirp{},
+
+		webhookSubscriptions:      map[uuid.UUID]database.WebhookSubscription{},
+		webhookSubscriptionEvents: map[subscriptionEventKey]bool{},
+		webhookDeliveries:         map[uuid.UUID]database.WebhookDelivery{},
+		webhookDeliveryAttempts:   map[uuid.UUID]database.WebhookDeliveryAttempt{},
+	}
+}
+
+// Rows are values, so copying the maps is enough for a snapshot
+func (d *memoryData) clone() *memoryData {
+	return &memoryData{
+		users:           maps.Clone(d.users),
+		chirps:          maps.Clone(d.chirps),
+		revisions:       maps.Clone(d.revisions),
+		likes:           maps.Clone(d.likes),
+		follows:         maps.Clone(d.follows),
+		tags:            maps.Clone(d.tags),
+		chirpTags:       maps.Clone(d.chirpTags),
+		mentions:        maps.Clone(d.mentions),
+		moderationWords: maps.Clone(d.moderationWords),
+		moderationFlags: maps.Clone(d.moderationFlags),
+		reports:         maps.Clone(d.reports),
+		refreshTokens:   maps.Clone(d.refreshTokens),
+		deniedTokens:    maps.Clone(d.deniedTokens),
+		webhookEvents:   maps.Clone(d.webhookEvents),
+		subscriptions:   maps.Clone(d.subscriptions),
+		scheduledChirps: maps.Clone(d.scheduledChirps),
+
+		webhookSubscriptions:      maps.Clone(d.webhookSubscriptions),
+		webhookSubscriptionEvents: maps.Clone(d.webhookSubscriptionEvents),
+		webhookDeliveries:         maps.Clone(d.webhookDeliveries),
+		webhookDeliveryAttempts:   maps.Clone(d.webhookDeliveryAttempts),
+	}
+}
+
+// Memory is an in-memory backend for tests and local development.
+// It is safe for concurrent use. A transaction holds the write lock until it
+// commits or rolls back, so transactions run one at a time.
+// Search approximates Postgres full-text search with exact, case-insensitive word matches.
+type Memory struct {
+	mu   *sync.RWMutex
+	data *memoryData
+	inTx bool
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory creates an empty in-memory store with the default moderation words
+func NewMemory() *Memory {
+	data := newMemoryData()
+	now := time.Now().UTC()
+	for _, word := range defaultModerationWords {
+		data.moderationWords[word] = database.ModerationWord{Word: word, CreatedAt: now, UpdatedAt: now, Action: "mask"}
+	}
+	return &Memory{mu: &sync.RWMutex{}, data: data}
+}
+
+// Take the write lock, unless a transaction already holds it
+func (m *Memory) lock() func() {
+	if m.inTx {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+// Take the read lock, unless a transaction already holds the write lock
+func (m *Memory) rlock() func() {
+	if m.inTx {
+		return func() {}
+	}
+	m.mu.RLock()
+	return m.mu.RUnlock
+}
+
+func (m *Memory) Begin(ctx context.Context) (Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	return &memoryTx{
+		Memory:   &Memory{mu: m.mu, data: m.data, inTx: true},
+		snapshot: m.data.clone(),
+	}, nil
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// memoryTx runs queries against the live data while holding the write lock,
+// and puts the snapshot back if it's rolled back
+type memoryTx struct {
+	*Memory
+	snapshot *memoryData
+	done     bool
+}
+
+func (t *memoryTx) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *memoryTx) Rollback() error {
+	if t.done {
+		return nil
+	}
+	*t.data = *t.snapshot
+	t.done = true
+	t.mu.Unlock()
+	return nil
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// Compare (created_at, id) keys, like a Postgres row comparison
+func compareKeys(t1 time.Time, id1 uuid.UUID, t2 time.Time, id2 uuid.UUID) int {
+	if c := t1.Compare(t2); c != 0 {
+		return c
+	}
+	return bytes.Compare(id1[:], id2[:])
+}
+
+// Whether a key is past the cursor in the direction of the listing
+func afterCursor(createdAt time.Time, id uuid.UUID, cursorCreatedAt sql.NullTime, cursorID uuid.NullUUID, desc bool) bool {
+	if !cursorCreatedAt.Valid {
+		return true
+	}
+	c := compareKeys(createdAt, id, cursorCreatedAt.Time, cursorID.UUID)
+	if desc {
+		return c < 0
+	}
+	return c > 0
+}
+
+func sortChirps(chirps []database.Chirp, desc bool) {
+	slices.SortFunc(chirps, func(a, b database.Chirp) int {
+		c := compareKeys(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+		if desc {
+			return -c
+		}
+		return c
+	})
+}
+
+func limitRows[T any](rows []T, limit int32) []T {
+	if limit >= 0 && int(limit) < len(rows) {
+		return rows[:limit]
+	}
+	return rows
+}
+
+// The hidden_at filter shared by the chirp listings
+func visibleTo(chirp database.Chirp, viewerID uuid.NullUUID, includeHidden bool) bool {
+	return !chirp.HiddenAt.Valid || includeHidden || (viewerID.Valid && chirp.UserID == viewerID)
+}
+
+// Chirps
+
+func (m *Memory) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	defer m.lock()()
+	t := now()
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		InReplyTo: arg.InReplyTo,
+	}
+	m.data.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (m *Memory) ReturnChirp(ctx context.Context, arg database.ReturnChirpParams) (database.Chirp, error) {
+	defer m.rlock()()
+	chirp, ok := m.data.chirps[arg.ID]
+	if !ok || chirp.DeletedAt.Valid || !visibleTo(chirp, arg.ViewerID, arg.IncludeHidden) {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (m *Memory) ReturnChirpIncludingDeleted(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	defer m.rlock()()
+	chirp, ok := m.data.chirps[id]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (m *Memory) ReturnChirpForUpdate(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	defer m.rlock()()
+	chirp, ok := m.data.chirps[id]
+	if !ok || chirp.DeletedAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+// Undeleted chirps visible to the viewer, optionally by one author
+func (m *Memory) listChirps(viewerID uuid.NullUUID, includeHidden bool, authorID uuid.NullUUID, keep func(database.Chirp) bool) []database.Chirp {
+	chirps := []database.Chirp{}
+	for _, chirp := range m.data.chirps {
+		if chirp.DeletedAt.Valid || !visibleTo(chirp, viewerID, includeHidden) {
+			continue
+		}
+		if authorID.Valid && chirp.UserID != authorID {
+			continue
+		}
+		if keep(chirp) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	return chirps
+}
+
+func (m *Memory) ListChirpsAsc(ctx context.Context, arg database.ListChirpsAscParams) ([]database.Chirp, error) {
+	defer m.rlock()()
+	chirps := m.listChirps(arg.ViewerID, arg.IncludeHidden, arg.AuthorID, func(chirp database.Chirp) bool {
+		return afterCursor(chirp.CreatedAt, chirp.ID, arg.CursorCreatedAt, arg.CursorID, false)
+	})
+	sortChirps(chirps, false)
+	return limitRows(chirps, arg.PageLimit), nil
+}
+
+func (m *Memory) ListChirpsDesc(ctx context.Context, arg database.ListChirpsDescParams) ([]database.Chirp, error) {
+	defer m.rlock()()
+	chirps := m.listChirps(arg.ViewerID, arg.IncludeHidden, arg.AuthorID, func(chirp database.Chirp) bool {
+		return afterCursor(chirp.CreatedAt, chirp.ID, arg.CursorCreatedAt, arg.CursorID, true)
+	})
+	sortChirps(chirps, true)
+	return limitRows(chirps, arg.PageLimit), nil
+}
+
+func compareByLikes(likes1 int32, t1 time.Time, id1 uuid.UUID, likes2 int32, t2 time.Time, id2 uuid.UUID) int {
+	if likes1 != likes2 {
+		if likes1 < likes2 {
+			return -1
+		}
+		return 1
+	}
+	return compareKeys(t1, id1, t2, id2)
+}
+
+func (m *Memory) ListChirpsByLikes(ctx context.Context, arg database.ListChirpsByLikesParams) ([]database.Chirp, error) {
+	defer m.rlock()()
+	chirps := m.listChirps(arg.ViewerID, arg.IncludeHidden, arg.AuthorID, func(chirp database.Chirp) bool {
+		if !arg.CursorCreatedAt.Valid {
+			return true
+		}
+		return compareByLikes(chirp.LikeCount, chirp.CreatedAt, chirp.ID, arg.CursorLikeCount.Int32, arg.CursorCreatedAt.Time, arg.CursorID.UUID) < 0
+	})
+	slices.SortFunc(chirps, func(a, b database.Chirp) int {
+		return -compareByLikes(a.LikeCount, a.CreatedAt, a.ID, b.LikeCount, b.CreatedAt, b.ID)
+	})
+	return limitRows(chirps, arg.PageLimit), nil
+}
+
+func (m *Memory) ListTimeline(ctx context.Context, arg database.ListTimelineParams) ([]database.Chirp, error) {
+	defer m.rlock()()
+	userID := uuid.NullUUID{UUID: arg.UserID, Valid: true}
+	chirps := m.listChirps(userID, arg.IncludeHidden, uuid.NullUUID{}, func(chirp database.Chirp) bool {
+		if chirp.UserID != userID {
+			if _, ok := m.data.follows[pairKey{arg.UserID, chirp.UserID.UUID}]; !ok || !chirp.UserID.Valid {
+				return false
+			}
+		}
+		return afterCursor(chirp.CreatedAt, chirp.ID, arg.CursorCreatedAt, arg.CursorID, true)
+	})
+	sortChirps(chirps, true)
+	return limitRows(chirps, arg.PageLimit), nil
+}
+
+var searchWordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// A parsed search query: every include word must appear and no exclude word may
+type searchQuery struct {
+	include []string
+	exclude []string
+}
+
+// Parse a query the way websearch_to_tsquery reads it, minus stemming and OR
+func parseSearchQuery(query string) searchQuery {
+	var q searchQuery
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		negated := strings.HasPrefix(field, "-")
+		words := searchWordPattern.FindAllString(field, -1)
+		if !negated && len(words) == 1 && words[0] == "or" {
+			continue
+		}
+		if negated {
+			q.exclude = append(q.exclude, words...)
+		} else {
+			q.include = append(q.include, words...)
+		}
+	}
+	return q
+}
+
+// Rank a chirp body against the query, returning false if it doesn't match
+func (q searchQuery) rank(body string) (float32, bool) {
+	if len(q.include) == 0 {
+		return 0, false
+	}
+	counts := map[string]int{}
+	for _, word := range searchWordPattern.FindAllString(strings.ToLower(body), -1) {
+		counts[word]++
+	}
+	for _, word := range q.exclude {
+		if counts[word] > 0 {
+			return 0, false
+		}
+	}
+	hits := 0
+	for _, word := range q.include {
+		if counts[word] == 0 {
+			return 0, false
+		}
+		hits += counts[word]
+	}
+	return float32(hits) / 10, true
+}
+
+// HTML-escape the body and wrap the words matching the query in <mark> tags
+func (q searchQuery) snippet(body string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range searchWordPattern.FindAllStringIndex(body, -1) {
+		b.WriteString(html.EscapeString(body[last:loc[0]]))
+		word := body[loc[0]:loc[1]]
+		if slices.Contains(q.include, strings.ToLower(word)) {
+			word = "<mark>" + word + "</mark>"
+		}
+		b.WriteString(word)
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(body[last:]))
+	return b.String()
+}
+
+func compareSearchRows(rank1 float32, t1 time.Time, id1 uuid.UUID, rank2 float32, t2 time.Time, id2 uuid.UUID) int {
+	if rank1 != rank2 {
+		if rank1 < rank2 {
+			return -1
+		}
+		return 1
+	}
+	return compareKeys(t1, id1, t2, id2)
+}
+
+func (m *Memory) SearchChirps(ctx context.Context, arg database.SearchChirpsParams) ([]database.SearchChirpsRow, error) {
+	defer m.rlock()()
+	query := parseSearchQuery(arg.Query)
+	rows := []database.SearchChirpsRow{}
+	for _, chirp := range m.listChirps(arg.ViewerID, arg.IncludeHidden, arg.AuthorID, func(database.Chirp) bool { return true }) {
+		if arg.Since.Valid && chirp.CreatedAt.Before(arg.Since.Time) {
+			continue
+		}
+		if arg.Until.Valid && !chirp.CreatedAt.Before(arg.Until.Time) {
+			continue
+		}
+		rank, ok := query.rank(chirp.Body)
+		if !ok {
+			continue
+		}
+		if arg.CursorCreatedAt.Valid && compareSearchRows(rank, chirp.CreatedAt, chirp.ID, float32(arg.CursorRank.Float64), arg.CursorCreatedAt.Time, arg.CursorID.UUID) >= 0 {
+			continue
+		}
+		rows = append(rows, database.SearchChirpsRow{Chirp: chirp, Rank: rank, Snippet: query.snippet(chirp.Body)})
+	}
+	slices.SortFunc(rows, func(a, b database.SearchChirpsRow) int {
+		return -compareSearchRows(a.Rank, a.Chirp.CreatedAt, a.Chirp.ID, b.Rank, b.Chirp.CreatedAt, b.Chirp.ID)
+	})
+	return limitRows(rows, arg.PageLimit), nil
+}
+
+func (m *Memory) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]database.Chirp, error) {
+	defer m.rlock()()
+	ancestors := []database.Chirp{}
+	start, ok := m.data.chirps[id]
+	if !ok {
+		return ancestors, nil
+	}
+	seen := map[uuid.UUID]bool{id: true}
+	for parentID := start.InReplyTo; parentID.Valid && !seen[parentID.UUID]; {
+		parent, ok := m.data.chirps[parentID.UUID]
+		if !ok {
+			break
+		}
+		seen[parent.ID] = true
+		ancestors = append(ancestors, parent)
+		parentID = parent.InReplyTo
+	}
+	slices.Reverse(ancestors)
+	return ancestors, nil
+}
+
+func (m *Memory) GetChirpDescendants(ctx context.Context, id uuid.UUID) ([]database.Chirp, error) {
+	defer m.rlock()()
+	children := map[uuid.UUID][]database.Chirp{}
+	for _, chirp := range m.data.chirps {
+		if chirp.InReplyTo.Valid {
+			children[chirp.InReplyTo.UUID] = append(children[chirp.InReplyTo.UUID], chirp)
+		}
+	}
+	descendants := []database.Chirp{}
+	seen := map[uuid.UUID]bool{id: true}
+	queue := []uuid.UUID{id}
+	for len(queue) > 0 {
+		parentID := queue[0]
+		queue = queue[1:]
+		for _, reply := range children[parentID] {
+			if seen[reply.ID] {
+				continue
+			}
+			seen[reply.ID] = true
+			descendants = append(descendants, reply)
+			queue = append(queue, reply.ID)
+		}
+	}
+	sortChirps(descendants, false)
+	return descendants, nil
+}
+
+func (m *Memory) UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error) {
+	defer m.lock()()
+	chirp, ok := m.data.chirps[arg.ID]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	chirp.Body = arg.Body
+	chirp.UpdatedAt = now()
+	m.data.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (m *Memory) DeleteChirp(ctx context.Context, arg database.DeleteChirpParams) error {
+	defer m.lock()()
+	chirp, ok := m.data.chirps[arg.ID]
+	if !ok || !arg.UserID.Valid || chirp.UserID != arg.UserID || chirp.DeletedAt.Valid {
+		return nil
+	}
+	t := now()
+	chirp.UpdatedAt = t
+	chirp.DeletedAt = sql.NullTime{Time: t, Valid: true}
+	m.data.chirps[chirp.ID] = chirp
+	return nil
+}
+
+func (m *Memory) HideChirp(ctx context.Context, id uuid.UUID) error {
+	defer m.lock()()
+	chirp, ok := m.data.chirps[id]
+	if !ok || chirp.HiddenAt.Valid {
+		return nil
+	}
+	chirp.HiddenAt = sql.NullTime{Time: now(), Valid: true}
+	m.data.chirps[id] = chirp
+	return nil
+}
+
+func (m *Memory) UnhideChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	defer m.lock()()
+	chirp, ok := m.data.chirps[id]
+	if !ok || !chirp.HiddenAt.Valid {
+		return 0, nil
+	}
+	chirp.HiddenAt = sql.NullTime{}
+	m.data.chirps[id] = chirp
+	return 1, nil
+}
+
+func (m *Memory) CreateChirpRevision(ctx context.Context, arg database.CreateChirpRevisionParams) (database.ChirpRevision, error) {
+	defer m.lock()()
+	revision := database.ChirpRevision{ID: uuid.New(), CreatedAt: now(), ChirpID: arg.ChirpID, Body: arg.Body}
+	m.data.revisions[revision.ID] = revision
+	return revision, nil
+}
+
+func (m *Memory) ListChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]database.ChirpRevision, error) {
+	defer m.rlock()()
+	revisions := []database.ChirpRevision{}
+	for _, revision := range m.data.revisions {
+		if revision.ChirpID == chirpID {
+			revisions = append(revisions, revision)
+		}
+	}
+	slices.SortFunc(revisions, func(a, b database.ChirpRevision) int {
+		return compareKeys(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	})
+	return revisions, nil
+}
+
+// Scheduled chirps
+
+func (m *Memory) CreateScheduledChirp(ctx context.Context, arg database.CreateScheduledChirpParams) (database.ScheduledChirp, error) {
+	defer m.lock()()
+	chirp := database.ScheduledChirp{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    arg.UserID,
+		Body:      arg.Body,
+		InReplyTo: arg.InReplyTo,
+		PublishAt: arg.PublishAt.UTC(),
+	}
+	m.data.scheduledChirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (m *Memory) ListUserScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.ScheduledChirp, error) {
+	defer m.rlock()()
+	return m.listScheduledChirps(func(chirp database.ScheduledChirp) bool {
+		return chirp.UserID == userID
+	}, -1), nil
+}
+
+func (m *Memory) DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error) {
+	defer m.lock()()
+	chirp, ok := m.data.scheduledChirps[arg.ID]
+	if !ok || chirp.UserID != arg.UserID {
+		return 0, nil
+	}
+	delete(m.data.scheduledChirps, arg.ID)
+	return 1, nil
+}
+
+// Suspended users' chirps wait until they're unsuspended
+func (m *Memory) ListDueScheduledChirps(ctx context.Context, arg database.ListDueScheduledChirpsParams) ([]database.ScheduledChirp, error) {
+	defer m.rlock()()
+	return m.listScheduledChirps(func(chirp database.ScheduledChirp) bool {
+		return !chirp.PublishAt.After(arg.PublishBefore) && !m.data.users[chirp.UserID].SuspendedAt.Valid
+	}, int(arg.PageLimit)), nil
+}
+
+// Scheduled chirps matching keep, soonest first, up to limit or all of them if limit is negative
+func (m *Memory) listScheduledChirps(keep func(database.ScheduledChirp) bool, limit int) []database.ScheduledChirp {
+	chirps := []database.ScheduledChirp{}
+	for _, chirp := range m.data.scheduledChirps {
+		if keep(chirp) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	slices.SortFunc(chirps, func(a, b database.ScheduledChirp) int {
+		return compareKeys(a.PublishAt, a.ID, b.PublishAt, b.ID)
+	})
+	if limit >= 0 && len(chirps) > limit {
+		chirps = chirps[:limit]
+	}
+	return chirps
+}
+
+// Likes
+
+func (m *Memory) LikeChirp(ctx context.Context, arg database.LikeChirpParams) (int64, error) {
+	defer m.lock()()
+	key := pairKey{arg.UserID, arg.ChirpID}
+	if _, ok := m.data.likes[key]; ok {
+		return 0, nil
+	}
+	m.data.likes[key] = now()
+	return 1, nil
+}
+
+func (m *Memory) UnlikeChirp(ctx context.Context, arg database.UnlikeChirpParams) (int64, error) {
+	defer m.lock()()
+	key := pairKey{arg.UserID, arg.ChirpID}
+	if _, ok := m.data.likes[key]; !ok {
+		return 0, nil
+	}
+	delete(m.data.likes, key)
+	return 1, nil
+}
+
+func (m *Memory) AdjustChirpLikeCount(ctx context.Context, arg database.AdjustChirpLikeCountParams) error {
+	defer m.lock()()
+	if chirp, ok := m.data.chirps[arg.ID]; ok {
+		chirp.LikeCount += arg.Delta
+		m.data.chirps[arg.ID] = chirp
+	}
+	return nil
+}
+
+func (m *Memory) ListLikedChirpIDs(ctx context.Context, arg database.ListLikedChirpIDsParams) ([]uuid.UUID, error) {
+	defer m.rlock()()
+	liked := []uuid.UUID{}
+	for _, chirpID := range arg.ChirpIds {
+		if _, ok := m.data.likes[pairKey{arg.UserID, chirpID}]; ok && !slices.Contains(liked, chirpID) {
+			liked = append(liked, chirpID)
+		}
+	}
+	return liked, nil
+}
+
+// Tags
+
+func (m *Memory) UpsertTags(ctx context.Context, names []string) ([]uuid.UUID, error) {
+	defer m.lock()()
+	ids := make([]uuid.UUID, 0, len(names))
+	for _, name := range names {
+		tag, ok := m.data.tags[name]
+		if !ok {
+			tag = database.Tag{ID: uuid.New(), CreatedAt: now(), Name: name}
+			m.data.tags[name] = tag
+		}
+		ids = append(ids, tag.ID)
+	}
+	return ids, nil
+}
+
+func (m *Memory) AddChirpTags(ctx context.Context, arg database.AddChirpTagsParams) error {
+	defer m.lock()()
+	for _, tagID := range arg.TagIds {
+		key := pairKey{arg.ChirpID, tagID}
+		if _, ok := m.data.chirpTags[key]; !ok {
+			m.data.chirpTags[key] = now()
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteStaleChirpTags(ctx context.Context, arg database.DeleteStaleChirpTagsParams) error {
+	defer m.lock()()
+	maps.DeleteFunc(m.data.chirpTags, func(key pairKey, _ time.Time) bool {
+		return key.a == arg.ChirpID && !slices.Contains(arg.KeepTagIds, key.b)
+	})
+	return nil
+}
+
+func (m *Memory) ListTagChirps(ctx context.Context, arg database.ListTagChirpsParams) ([]database.Chirp, error) {
+	defer m.rlock()()
+	tag, ok := m.data.tags[arg.Tag]
+	if !ok {
+		return []database.Chirp{}, nil
+	}
+	chirps := m.listChirps(arg.ViewerID, arg.IncludeHidden, uuid.NullUUID{}, func(chirp database.Chirp) bool {
+		if _, ok := m.data.chirpTags[pairKey{chirp.ID, tag.ID}]; !ok {
+			return false
+		}
+		return afterCursor(chirp.CreatedAt, chirp.ID, arg.CursorCreatedAt, arg.CursorID, true)
+	})
+	sortChirps(chirps, true)
+	return limitRows(chirps, arg.PageLimit), nil
+}
+
+func (m *Memory) ListTrendingTags(ctx context.Context, arg database.ListTrendingTagsParams) ([]database.ListTrendingTagsRow, error) {
+	defer m.rlock()()
+	names := map[uuid.UUID]string{}
+	for _, tag := range m.data.tags {
+		names[tag.ID] = tag.Name
+	}
+	since := now().Add(-time.Duration(arg.WindowSeconds * float64(time.Second)))
+	counts := map[string]int64{}
+	for key, createdAt := range m.data.chirpTags {
+		chirp, ok := m.data.chirps[key.a]
+		if !ok || createdAt.Before(since) || chirp.DeletedAt.Valid || chirp.HiddenAt.Valid {
+			continue
+		}
+		counts[names[key.b]]++
+	}
+	rows := []database.ListTrendingTagsRow{}
+	for name, count := range counts {
+		rows = append(rows, database.ListTrendingTagsRow{Name: name, ChirpCount: count})
+	}
+	slices.SortFunc(rows, func(a, b database.ListTrendingTagsRow) int {
+		if a.ChirpCount != b.ChirpCount {
+			if a.ChirpCount > b.ChirpCount {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return limitRows(rows, arg.TagLimit), nil
+}
+
+// Mentions
+
+func (m *Memory) AddChirpMentions(ctx context.Context, arg database.AddChirpMentionsParams) error {
+	defer m.lock()()
+	for _, userID := range arg.UserIds {
+		key := pairKey{arg.ChirpID, userID}
+		if _, ok := m.data.mentions[key]; !ok {
+			m.data.mentions[key] = now()
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteChirpMentions(ctx context.Context, chirpID uuid.UUID) error {
+	defer m.lock()()
+	maps.DeleteFunc(m.data.mentions, func(key pairKey, _ time.Time) bool {
+		return key.a == chirpID
+	})
+	return nil
+}
+
+func (m *Memory) ListMentions(ctx context.Context, arg database.ListMentionsParams) ([]database.Chirp, error) {
+	defer m.rlock()()
+	chirps := m.listChirps(uuid.NullUUID{}, false, uuid.NullUUID{}, func(chirp database.Chirp) bool {
+		if _, ok := m.data.mentions[pairKey{chirp.ID, arg.UserID}]; !ok {
+			return false
+		}
+		return afterCursor(chirp.CreatedAt, chirp.ID, arg.CursorCreatedAt, arg.CursorID, true)
+	})
+	sortChirps(chirps, true)
+	return limitRows(chirps, arg.PageLimit), nil
+}
+
+// Users
+
+// Check the unique email and handle constraints, ignoring the user being updated
+func (m *Memory) checkUserUnique(id uuid.UUID, email string, handle sql.NullString) error {
+	for _, user := range m.data.users {
+		if user.ID == id {
+			continue
+		}
+		if user.Email == email {
+			return errDuplicateEmail
+		}
+		if handle.Valid && user.Handle.Valid && strings.EqualFold(user.Handle.String, handle.String) {
+			return errDuplicateHandle
+		}
+	}
+	return nil
+}
+
+func (m *Memory) insertUser(email, hashedPassword string, handle sql.NullString, role string) (database.User, error) {
+	if err := m.checkUserUnique(uuid.Nil, email, handle); err != nil {
+		return database.User{}, err
+	}
+	t := now()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      t,
+		UpdatedAt:      t,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Handle:         handle,
+		Role:           role,
+	}
+	m.data.users[user.ID] = user
+	return user, nil
+}
+
+func (m *Memory) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.CreateUserRow, error) {
+	defer m.lock()()
+	user, err := m.insertUser(arg.Email, arg.HashedPassword, arg.Handle, "user")
+	if err != nil {
+		return database.CreateUserRow{}, err
+	}
+	return database.CreateUserRow(publicUser(user)), nil
+}
+
+func (m *Memory) BootstrapAdmin(ctx context.Context, arg database.BootstrapAdminParams) (uuid.UUID, error) {
+	defer m.lock()()
+	for _, user := range m.data.users {
+		if user.Email == arg.Email {
+			user.Role = "admin"
+			user.UpdatedAt = now()
+			m.data.users[user.ID] = user
+			return user.ID, nil
+		}
+	}
+	user, err := m.insertUser(arg.Email, arg.HashedPassword, sql.NullString{}, "admin")
+	return user.ID, err
+}
+
+func (m *Memory) UserLogin(ctx context.Context, email string) (database.User, error) {
+	defer m.rlock()()
+	for _, user := range m.data.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+// The columns the user queries return
+func publicUser(user database.User) database.GetUserByIDRow {
+	return database.GetUserByIDRow{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+		Handle:      user.Handle,
+	}
+}
+
+func (m *Memory) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.UpdateUserRow, error) {
+	defer m.lock()()
+	user, ok := m.data.users[arg.ID]
+	if !ok {
+		return database.UpdateUserRow{}, sql.ErrNoRows
+	}
+	if err := m.checkUserUnique(user.ID, arg.Email, arg.Handle); err != nil {
+		return database.UpdateUserRow{}, err
+	}
+	user.UpdatedAt = now()
+	user.Email = arg.Email
+	user.HashedPassword = arg.HashedPassword
+	if arg.Handle.Valid {
+		user.Handle = arg.Handle
+	}
+	m.data.users[user.ID] = user
+	return database.UpdateUserRow(publicUser(user)), nil
+}
+
+func (m *Memory) GetUserByID(ctx context.Context, id uuid.UUID) (database.GetUserByIDRow, error) {
+	defer m.rlock()()
+	user, ok := m.data.users[id]
+	if !ok {
+		return database.GetUserByIDRow{}, sql.ErrNoRows
+	}
+	return publicUser(user), nil
+}
+
+func (m *Memory) GetUserByHandle(ctx context.Context, handle string) (database.GetUserByHandleRow, error) {
+	defer m.rlock()()
+	for _, user := range m.data.users {
+		if user.Handle.Valid && strings.EqualFold(user.Handle.String, handle) {
+			return database.GetUserByHandleRow(publicUser(user)), nil
+		}
+	}
+	return database.GetUserByHandleRow{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetUserAccess(ctx context.Context, id uuid.UUID) (database.GetUserAccessRow, error) {
+	defer m.rlock()()
+	user, ok := m.data.users[id]
+	if !ok {
+		return database.GetUserAccessRow{}, sql.ErrNoRows
+	}
+	return database.GetUserAccessRow{Role: user.Role, SuspendedAt: user.SuspendedAt}, nil
+}
+
+func (m *Memory) FindMentionCandidates(ctx context.Context, names []string) ([]database.FindMentionCandidatesRow, error) {
+	defer m.rlock()()
+	rows := []database.FindMentionCandidatesRow{}
+	for _, user := range m.data.users {
+		localPart, _, _ := strings.Cut(user.Email, "@")
+		if (user.Handle.Valid && slices.Contains(names, strings.ToLower(user.Handle.String))) || slices.Contains(names, strings.ToLower(localPart)) {
+			rows = append(rows, database.FindMentionCandidatesRow{ID: user.ID, Email: user.Email, Handle: user.Handle})
+		}
+	}
+	return rows, nil
+}
+
+func (m *Memory) IsUserSuspended(ctx context.Context, id uuid.UUID) (bool, error) {
+	defer m.rlock()()
+	user, ok := m.data.users[id]
+	if !ok {
+		return false, sql.ErrNoRows
+	}
+	return user.SuspendedAt.Valid, nil
+}
+
+func (m *Memory) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	defer m.lock()()
+	user, ok := m.data.users[id]
+	if !ok || user.SuspendedAt.Valid {
+		return nil
+	}
+	t := now()
+	user.UpdatedAt = t
+	user.SuspendedAt = sql.NullTime{Time: t, Valid: true}
+	m.data.users[id] = user
+	return nil
+}
+
+func (m *Memory) UnsuspendUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	defer m.lock()()
+	user, ok := m.data.users[id]
+	if !ok || !user.SuspendedAt.Valid {
+		return 0, nil
+	}
+	user.UpdatedAt = now()
+	user.SuspendedAt = sql.NullTime{}
+	m.data.users[id] = user
+	return 1, nil
+}
+
+func (m *Memory) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (int64, error) {
+	defer m.lock()()
+	if !slices.Contains([]string{"user", "moderator", "admin"}, arg.Role) {
+		return 0, fmt.Errorf("%w: %q", errInvalidRole, arg.Role)
+	}
+	user, ok := m.data.users[arg.ID]
+	if !ok {
+		return 0, nil
+	}
+	user.UpdatedAt = now()
+	user.Role = arg.Role
+	m.data.users[user.ID] = user
+	return 1, nil
+}
+
+// Deleting every user cascades to everything they own
+func (m *Memory) ResetUsers(ctx context.Context) error {
+	defer m.lock()()
+	tags := m.data.tags
+	words := m.data.moderationWords
+	*m.data = *newMemoryData()
+	m.data.tags = tags
+	m.data.moderationWords = words
+	return nil
+}
+
+// Follows
+
+func (m *Memory) FollowUser(ctx context.Context, arg database.FollowUserParams) error {
+	defer m.lock()()
+	if arg.FollowerID == arg.FolloweeID {
+		return errSelfFollow
+	}
+	key := pairKey{arg.FollowerID, arg.FolloweeID}
+	if _, ok := m.data.follows[key]; !ok {
+		m.data.follows[key] = now()
+	}
+	return nil
+}
+
+func (m *Memory) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error {
+	defer m.lock()()
+	delete(m.data.follows, pairKey{arg.FollowerID, arg.FolloweeID})
+	return nil
+}
+
+// Follows on one side of a user, newest first, as (other user, created_at) pairs
+func (m *Memory) listFollows(userID uuid.UUID, followers bool, cursorCreatedAt sql.NullTime, cursorID uuid.NullUUID, limit int32) []database.ListFollowersRow {
+	rows := []database.ListFollowersRow{}
+	for key, createdAt := range m.data.follows {
+		self, other := key.a, key.b
+		if followers {
+			self, other = key.b, key.a
+		}
+		if self == userID && afterCursor(createdAt, other, cursorCreatedAt, cursorID, true) {
+			rows = append(rows, database.ListFollowersRow{UserID: other, CreatedAt: createdAt})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ListFollowersRow) int {
+		return -compareKeys(a.CreatedAt, a.UserID, b.CreatedAt, b.UserID)
+	})
+	return limitRows(rows, limit)
+}
+
+func (m *Memory) ListFollowers(ctx context.Context, arg database.ListFollowersParams) ([]database.ListFollowersRow, error) {
+	defer m.rlock()()
+	return m.listFollows(arg.UserID, true, arg.CursorCreatedAt, arg.CursorID, arg.PageLimit), nil
+}
+
+func (m *Memory) ListFollowing(ctx context.Context, arg database.ListFollowingParams) ([]database.ListFollowingRow, error) {
+	defer m.rlock()()
+	rows := m.listFollows(arg.UserID, false, arg.CursorCreatedAt, arg.CursorID, arg.PageLimit)
+	following := make([]database.ListFollowingRow, len(rows))
+	for i, row := range rows {
+		following[i] = database.ListFollowingRow(row)
+	}
+	return following, nil
+}
+
+// Refresh tokens
+
+func (m *Memory) CreateRToken(ctx context.Context, arg database.CreateRTokenParams) (database.RefreshToken, error) {
+	defer m.lock()()
+	if _, ok := m.data.refreshTokens[arg.Token]; ok {
+		return database.RefreshToken{}, errDuplicateToken
+	}
+	t := now()
+	token := database.RefreshToken{
+		Token:      arg.Token,
+		CreatedAt:  t,
+		UpdatedAt:  t,
+		UserID:     arg.UserID,
+		ExpiresAt:  t.Add(refreshTokenLifetime),
+		FamilyID:   arg.FamilyID,
+		UserAgent:  arg.UserAgent,
+		Ip:         arg.Ip,
+		LastUsedAt: t,
+	}
+	m.data.refreshTokens[token.Token] = token
+	return token, nil
+}
+
+func (m *Memory) GetRTokenForUpdate(ctx context.Context, token string) (database.RefreshToken, error) {
+	defer m.rlock()()
+	rt, ok := m.data.refreshTokens[token]
+	if !ok {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return rt, nil
+}
+
+func (m *Memory) RevokeRToken(ctx context.Context, token string) ([]uuid.UUID, error) {
+	defer m.lock()()
+	rt, ok := m.data.refreshTokens[token]
+	if !ok {
+		return []uuid.UUID{}, nil
+	}
+	t := now()
+	rt.RevokedAt = sql.NullTime{Time: t, Valid: true}
+	rt.UpdatedAt = t
+	m.data.refreshTokens[token] = rt
+	return []uuid.UUID{rt.FamilyID}, nil
+}
+
+func (m *Memory) RevokeRTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	defer m.lock()()
+	t := now()
+	for key, rt := range m.data.refreshTokens {
+		if rt.FamilyID == familyID && !rt.RevokedAt.Valid {
+			rt.RevokedAt = sql.NullTime{Time: t, Valid: true}
+			rt.UpdatedAt = t
+			m.data.refreshTokens[key] = rt
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RevokeUserRTokens(ctx context.Context, userID uuid.NullUUID) ([]uuid.UUID, error) {
+	defer m.lock()()
+	t := now()
+	families := []uuid.UUID{}
+	for key, rt := range m.data.refreshTokens {
+		if userID.Valid && rt.UserID == userID && !rt.RevokedAt.Valid {
+			rt.RevokedAt = sql.NullTime{Time: t, Valid: true}
+			rt.UpdatedAt = t
+			m.data.refreshTokens[key] = rt
+			families = append(families, rt.FamilyID)
+		}
+	}
+	return families, nil
+}
+
+func (m *Memory) RevokeOtherUserRTokens(ctx context.Context, arg database.RevokeOtherUserRTokensParams) ([]uuid.UUID, error) {
+	defer m.lock()()
+	t := now()
+	families := []uuid.UUID{}
+	for key, rt := range m.data.refreshTokens {
+		if arg.UserID.Valid && rt.UserID == arg.UserID && rt.FamilyID != arg.KeepFamilyID && !rt.RevokedAt.Valid {
+			rt.RevokedAt = sql.NullTime{Time: t, Valid: true}
+			rt.UpdatedAt = t
+			m.data.refreshTokens[key] = rt
+			families = append(families, rt.FamilyID)
+		}
+	}
+	return families, nil
+}
+
+func (m *Memory) RevokeUserSession(ctx context.Context, arg database.RevokeUserSessionParams) (int64, error) {
+	defer m.lock()()
+	t := now()
+	var revoked int64
+	for key, rt := range m.data.refreshTokens {
+		if arg.UserID.Valid && rt.UserID == arg.UserID && rt.FamilyID == arg.FamilyID && !rt.RevokedAt.Valid {
+			rt.RevokedAt = sql.NullTime{Time: t, Valid: true}
+			rt.UpdatedAt = t
+			m.data.refreshTokens[key] = rt
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+// A session is a token family, described by its active token and signed in when its first token was issued
+func (m *Memory) ListUserSessions(ctx context.Context, userID uuid.NullUUID) ([]database.ListUserSessionsRow, error) {
+	defer m.rlock()()
+	signedIn := map[uuid.UUID]time.Time{}
+	for _, rt := range m.data.refreshTokens {
+		if first, ok := signedIn[rt.FamilyID]; !ok || rt.CreatedAt.Before(first) {
+			signedIn[rt.FamilyID] = rt.CreatedAt
+		}
+	}
+	sessions := []database.ListUserSessionsRow{}
+	t := now()
+	for _, rt := range m.data.refreshTokens {
+		if !userID.Valid || rt.UserID != userID || rt.RevokedAt.Valid || !rt.ExpiresAt.After(t) {
+			continue
+		}
+		sessions = append(sessions, database.ListUserSessionsRow{
+			FamilyID:   rt.FamilyID,
+			SignedInAt: signedIn[rt.FamilyID],
+			LastUsedAt: rt.LastUsedAt,
+			ExpiresAt:  rt.ExpiresAt,
+			UserAgent:  rt.UserAgent,
+			Ip:         rt.Ip,
+		})
+	}
+	slices.SortFunc(sessions, func(a, b database.ListUserSessionsRow) int {
+		if c := b.LastUsedAt.Compare(a.LastUsedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.FamilyID[:], b.FamilyID[:])
+	})
+	return sessions, nil
+}
+
+// Denying an ID again keeps whichever expiry is later
+func (m *Memory) DenyToken(ctx context.Context, arg database.DenyTokenParams) error {
+	defer m.lock()()
+	if expiresAt, ok := m.data.deniedTokens[arg.ID]; !ok || arg.ExpiresAt.After(expiresAt) {
+		m.data.deniedTokens[arg.ID] = arg.ExpiresAt.UTC()
+	}
+	return nil
+}
+
+func (m *Memory) ListDeniedTokens(ctx context.Context) ([]database.ListDeniedTokensRow, error) {
+	defer m.rlock()()
+	t := now()
+	denied := []database.ListDeniedTokensRow{}
+	for id, expiresAt := range m.data.deniedTokens {
+		if expiresAt.After(t) {
+			denied = append(denied, database.ListDeniedTokensRow{ID: id, ExpiresAt: expiresAt})
+		}
+	}
+	return denied, nil
+}
+
+func (m *Memory) DeleteExpiredDeniedTokens(ctx context.Context) (int64, error) {
+	defer m.lock()()
+	t := now()
+	var deleted int64
+	for id, expiresAt := range m.data.deniedTokens {
+		if !expiresAt.After(t) {
+			delete(m.data.deniedTokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Moderation
+
+func (m *Memory) ListModerationWords(ctx context.Context) ([]database.ModerationWord, error) {
+	defer m.rlock()()
+	words := slices.Collect(maps.Values(m.data.moderationWords))
+	slices.SortFunc(words, func(a, b database.ModerationWord) int {
+		return strings.Compare(a.Word, b.Word)
+	})
+	return words, nil
+}
+
+func (m *Memory) UpsertModerationWord(ctx context.Context, arg database.UpsertModerationWordParams) (database.ModerationWord, error) {
+	defer m.lock()()
+	if !slices.Contains([]string{"mask", "flag", "reject"}, arg.Action) {
+		return database.ModerationWord{}, fmt.Errorf("%w: %q", errInvalidAction, arg.Action)
+	}
+	t := now()
+	word, ok := m.data.moderationWords[arg.Word]
+	if !ok {
+		word = database.ModerationWord{Word: arg.Word, CreatedAt: t}
+	}
+	word.UpdatedAt = t
+	word.Action = arg.Action
+	m.data.moderationWords[arg.Word] = word
+	return word, nil
+}
+
+func (m *Memory) DeleteModerationWord(ctx context.Context, word string) (int64, error) {
+	defer m.lock()()
+	if _, ok := m.data.moderationWords[word]; !ok {
+		return 0, nil
+	}
+	delete(m.data.moderationWords, word)
+	return 1, nil
+}
+
+func (m *Memory) CreateModerationFlag(ctx context.Context, arg database.CreateModerationFlagParams) error {
+	defer m.lock()()
+	flag := database.ModerationFlag{ID: uuid.New(), CreatedAt: now(), ChirpID: arg.ChirpID, Word: arg.Word}
+	m.data.moderationFlags[flag.ID] = flag
+	return nil
+}
+
+func (m *Memory) ListPendingModerationFlags(ctx context.Context, arg database.ListPendingModerationFlagsParams) ([]database.ListPendingModerationFlagsRow, error) {
+	defer m.rlock()()
+	rows := []database.ListPendingModerationFlagsRow{}
+	for _, flag := range m.data.moderationFlags {
+		chirp, ok := m.data.chirps[flag.ChirpID]
+		if !ok || flag.ReviewedAt.Valid || !afterCursor(flag.CreatedAt, flag.ID, arg.CursorCreatedAt, arg.CursorID, false) {
+			continue
+		}
+		rows = append(rows, database.ListPendingModerationFlagsRow{ModerationFlag: flag, Chirp: chirp})
+	}
+	slices.SortFunc(rows, func(a, b database.ListPendingModerationFlagsRow) int {
+		return compareKeys(a.ModerationFlag.CreatedAt, a.ModerationFlag.ID, b.ModerationFlag.CreatedAt, b.ModerationFlag.ID)
+	})
+	return limitRows(rows, arg.PageLimit), nil
+}
+
+func (m *Memory) ReviewModerationFlag(ctx context.Context, id uuid.UUID) (int64, error) {
+	defer m.lock()()
+	flag, ok := m.data.moderationFlags[id]
+	if !ok || flag.ReviewedAt.Valid {
+		return 0, nil
+	}
+	flag.ReviewedAt = sql.NullTime{Time: now(), Valid: true}
+	m.data.moderationFlags[id] = flag
+	return 1, nil
+}
+
+// Reports
+
+func (m *Memory) CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error) {
+	defer m.lock()()
+	for _, report := range m.data.reports {
+		if report.ChirpID == arg.ChirpID && report.ReporterID == arg.ReporterID {
+			return database.Report{}, sql.ErrNoRows
+		}
+	}
+	t := now()
+	report := database.Report{
+		ID:         uuid.New(),
+		CreatedAt:  t,
+		UpdatedAt:  t,
+		ChirpID:    arg.ChirpID,
+		ReporterID: arg.ReporterID,
+		Reason:     arg.Reason,
+		Status:     "open",
+	}
+	m.data.reports[report.ID] = report
+	return report, nil
+}
+
+func (m *Memory) ReturnReport(ctx context.Context, id uuid.UUID) (database.Report, error) {
+	defer m.rlock()()
+	report, ok := m.data.reports[id]
+	if !ok {
+		return database.Report{}, sql.ErrNoRows
+	}
+	return report, nil
+}
+
+func (m *Memory) ListReports(ctx context.Context, arg database.ListReportsParams) ([]database.ListReportsRow, error) {
+	defer m.rlock()()
+	rows := []database.ListReportsRow{}
+	for _, report := range m.data.reports {
+		chirp, ok := m.data.chirps[report.ChirpID]
+		if !ok || report.Status != arg.Status || !afterCursor(report.CreatedAt, report.ID, arg.CursorCreatedAt, arg.CursorID, false) {
+			continue
+		}
+		rows = append(rows, database.ListReportsRow{Report: report, Chirp: chirp})
+	}
+	slices.SortFunc(rows, func(a, b database.ListReportsRow) int {
+		return compareKeys(a.Report.CreatedAt, a.Report.ID, b.Report.CreatedAt, b.Report.ID)
+	})
+	return limitRows(rows, arg.PageLimit), nil
+}
+
+func resolve(report database.Report, status string) database.Report {
+	t := now()
+	report.UpdatedAt = t
+	report.Status = status
+	report.ResolvedAt = sql.NullTime{Time: t, Valid: true}
+	return report
+}
+
+func (m *Memory) ResolveReport(ctx context.Context, arg database.ResolveReportParams) (database.Report, error) {
+	defer m.lock()()
+	report, ok := m.data.reports[arg.ID]
+	if !ok || report.Status != "open" {
+		return database.Report{}, sql.ErrNoRows
+	}
+	report = resolve(report, arg.Status)
+	m.data.reports[report.ID] = report
+	return report, nil
+}
+
+func (m *Memory) ResolveOpenChirpReports(ctx context.Context, arg database.ResolveOpenChirpReportsParams) error {
+	defer m.lock()()
+	for id, report := range m.data.reports {
+		if report.ChirpID == arg.ChirpID && report.Status == "open" {
+			m.data.reports[id] = resolve(report, arg.Status)
+		}
+	}
+	return nil
+}
+
+// Webhooks
+
+// Affects no rows if the event was already recorded, unless it was ignored and is now processed
+func (m *Memory) RecordWebhookEvent(ctx context.Context, arg database.RecordWebhookEventParams) (int64, error) {
+	defer m.lock()()
+	if event, ok := m.data.webhookEvents[arg.ID]; ok && (event.Result != "ignored" || arg.Result != "processed") {
+		return 0, nil
+	}
+	m.data.webhookEvents[arg.ID] = database.WebhookEvent{
+		ID:         arg.ID,
+		Event:      arg.Event,
+		ReceivedAt: now(),
+		Result:     arg.Result,
+	}
+	return 1, nil
+}
+
+// Outbound webhooks
+
+func (m *Memory) CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	defer m.lock()()
+	sub := database.WebhookSubscription{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    arg.UserID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+	}
+	m.data.webhookSubscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+func (m *Memory) AddWebhookSubscriptionEvents(ctx context.Context, arg database.AddWebhookSubscriptionEventsParams) error {
+	defer m.lock()()
+	for _, event := range arg.Events {
+		m.data.webhookSubscriptionEvents[subscriptionEventKey{arg.SubscriptionID, event}] = true
+	}
+	return nil
+}
+
+func (m *Memory) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (database.WebhookSubscription, error) {
+	defer m.rlock()()
+	sub, ok := m.data.webhookSubscriptions[id]
+	if !ok {
+		return database.WebhookSubscription{}, sql.ErrNoRows
+	}
+	return sub, nil
+}
+
+// A user's subscriptions, or the admin ones when userID is NULL
+func (m *Memory) ListWebhookSubscriptions(ctx context.Context, userID uuid.NullUUID) ([]database.WebhookSubscription, error) {
+	defer m.rlock()()
+	subs := []database.WebhookSubscription{}
+	for _, sub := range m.data.webhookSubscriptions {
+		if sub.UserID == userID {
+			subs = append(subs, sub)
+		}
+	}
+	slices.SortFunc(subs, func(a, b database.WebhookSubscription) int {
+		return compareKeys(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	})
+	return subs, nil
+}
+
+func (m *Memory) ListWebhookSubscriptionEvents(ctx context.Context, subscriptionID uuid.UUID) ([]string, error) {
+	defer m.rlock()()
+	events := []string{}
+	for key := range m.data.webhookSubscriptionEvents {
+		if key.subscriptionID == subscriptionID {
+			events = append(events, key.event)
+		}
+	}
+	slices.Sort(events)
+	return events, nil
+}
+
+// Deletes the subscription's events, deliveries and attempts along with it
+func (m *Memory) DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) (int64, error) {
+	defer m.lock()()
+	sub, ok := m.data.webhookSubscriptions[arg.ID]
+	if !ok || sub.UserID != arg.UserID {
+		return 0, nil
+	}
+	delete(m.data.webhookSubscriptions, arg.ID)
+	maps.DeleteFunc(m.data.webhookSubscriptionEvents, func(key subscriptionEventKey, _ bool) bool {
+		return key.subscriptionID == arg.ID
+	})
+	m.deleteWebhookDeliveries(func(delivery database.WebhookDelivery) bool {
+		return delivery.SubscriptionID == arg.ID
+	})
+	return 1, nil
+}
+
+// Queues an event for every subscription that wants it: the admin ones, and the user's own
+func (m *Memory) EnqueueWebhookDeliveries(ctx context.Context, arg database.EnqueueWebhookDeliveriesParams) (int64, error) {
+	defer m.lock()()
+	t := now()
+	var queued int64
+	for _, sub := range m.data.webhookSubscriptions {
+		if !m.data.webhookSubscriptionEvents[subscriptionEventKey{sub.ID, arg.Event}] {
+			continue
+		}
+		if sub.UserID.Valid && sub.UserID.UUID != arg.UserID {
+			continue
+		}
+		delivery := database.WebhookDelivery{
+			ID:             uuid.New(),
+			CreatedAt:      t,
+			UpdatedAt:      t,
+			SubscriptionID: sub.ID,
+			Event:          arg.Event,
+			Payload:        arg.Payload,
+			Status:         "pending",
+			NextAttemptAt:  t,
+		}
+		m.data.webhookDeliveries[delivery.ID] = delivery
+		queued++
+	}
+	return queued, nil
+}
+
+// Pending deliveries that are due, leased until LeaseUntil
+func (m *Memory) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	defer m.lock()()
+	due := []database.WebhookDelivery{}
+	for _, delivery := range m.data.webhookDeliveries {
+		if delivery.Status == "pending" && !delivery.NextAttemptAt.After(arg.DueBy) {
+			due = append(due, delivery)
+		}
+	}
+	slices.SortFunc(due, func(a, b database.WebhookDelivery) int {
+		return compareKeys(a.NextAttemptAt, a.ID, b.NextAttemptAt, b.ID)
+	})
+	due = limitRows(due, arg.PageLimit)
+
+	t := now()
+	for i := range due {
+		due[i].UpdatedAt = t
+		due[i].NextAttemptAt = arg.LeaseUntil.UTC()
+		m.data.webhookDeliveries[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+func (m *Memory) RecordWebhookDeliveryAttempt(ctx context.Context, arg database.RecordWebhookDeliveryAttemptParams) error {
+	defer m.lock()()
+	attempt := database.WebhookDeliveryAttempt{
+		ID:             uuid.New(),
+		CreatedAt:      now(),
+		DeliveryID:     arg.DeliveryID,
+		ResponseStatus: arg.ResponseStatus,
+		ResponseBody:   arg.ResponseBody,
+		Error:          arg.Error,
+	}
+	m.data.webhookDeliveryAttempts[attempt.ID] = attempt
+	return nil
+}
+
+func (m *Memory) UpdateWebhookDelivery(ctx context.Context, arg database.UpdateWebhookDeliveryParams) error {
+	defer m.lock()()
+	delivery, ok := m.data.webhookDeliveries[arg.ID]
+	if !ok {
+		return nil
+	}
+	delivery.UpdatedAt = now()
+	delivery.Status = arg.Status
+	delivery.Attempts++
+	delivery.NextAttemptAt = arg.NextAttemptAt.UTC()
+	m.data.webhookDeliveries[arg.ID] = delivery
+	return nil
+}
+
+// A subscription's delivery log, newest attempt first
+func (m *Memory) ListWebhookDeliveryAttempts(ctx context.Context, arg database.ListWebhookDeliveryAttemptsParams) ([]database.ListWebhookDeliveryAttemptsRow, error) {
+	defer m.rlock()()
+	rows := []database.ListWebhookDeliveryAttemptsRow{}
+	for _, attempt := range m.data.webhookDeliveryAttempts {
+		delivery := m.data.webhookDeliveries[attempt.DeliveryID]
+		if delivery.SubscriptionID != arg.SubscriptionID {
+			continue
+		}
+		if !afterCursor(attempt.CreatedAt, attempt.ID, arg.CursorCreatedAt, arg.CursorID, true) {
+			continue
+		}
+		rows = append(rows, database.ListWebhookDeliveryAttemptsRow{
+			ID:             attempt.ID,
+			CreatedAt:      attempt.CreatedAt,
+			DeliveryID:     attempt.DeliveryID,
+			Event:          delivery.Event,
+			Status:         delivery.Status,
+			ResponseStatus: attempt.ResponseStatus,
+			ResponseBody:   attempt.ResponseBody,
+			Error:          attempt.Error,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.ListWebhookDeliveryAttemptsRow) int {
+		return compareKeys(b.CreatedAt, b.ID, a.CreatedAt, a.ID)
+	})
+	return limitRows(rows, arg.PageLimit), nil
+}
+
+// Finished deliveries, and their attempts, are kept until they're older than before
+func (m *Memory) DeleteOldWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	defer m.lock()()
+	return m.deleteWebhookDeliveries(func(delivery database.WebhookDelivery) bool {
+		return delivery.Status != "pending" && delivery.UpdatedAt.Before(before)
+	}), nil
+}
+
+// Delete the deliveries matching remove along with their attempts, returning how many were deleted
+func (m *Memory) deleteWebhookDeliveries(remove func(database.WebhookDelivery) bool) int64 {
+	var deleted int64
+	for id, delivery := range m.data.webhookDeliveries {
+		if remove(delivery) {
+			delete(m.data.webhookDeliveries, id)
+			deleted++
+		}
+	}
+	maps.DeleteFunc(m.data.webhookDeliveryAttempts, func(_ uuid.UUID, attempt database.WebhookDeliveryAttempt) bool {
+		_, ok := m.data.webhookDeliveries[attempt.DeliveryID]
+		return !ok
+	})
+	return deleted
+}
+
+// Subscriptions
+
+// Affects no rows if the user doesn't exist, and an empty customer ID keeps the one already stored
+func (m *Memory) UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (int64, error) {
+	defer m.lock()()
+	if _, ok := m.data.users[arg.UserID]; !ok {
+		return 0, nil
+	}
+	t := now()
+	sub, ok := m.data.subscriptions[arg.UserID]
+	if !ok {
+		sub = database.Subscription{UserID: arg.UserID, CreatedAt: t}
+	}
+	sub.UpdatedAt = t
+	sub.Plan = arg.Plan
+	sub.Status = arg.Status
+	sub.CurrentPeriodEnd = arg.CurrentPeriodEnd.UTC()
+	if arg.PolkaCustomerID != "" {
+		sub.PolkaCustomerID = arg.PolkaCustomerID
+	}
+	m.data.subscriptions[arg.UserID] = sub
+	return 1, nil
+}
+
+// A NULL period end keeps the current one
+func (m *Memory) UpdateSubscriptionStatus(ctx context.Context, arg database.UpdateSubscriptionStatusParams) (int64, error) {
+	defer m.lock()()
+	sub, ok := m.data.subscriptions[arg.UserID]
+	if !ok {
+		return 0, nil
+	}
+	sub.UpdatedAt = now()
+	sub.Status = arg.Status
+	if arg.CurrentPeriodEnd.Valid {
+		sub.CurrentPeriodEnd = arg.CurrentPeriodEnd.Time.UTC()
+	}
+	m.data.subscriptions[arg.UserID] = sub
+	return 1, nil
+}
+
+func (m *Memory) ExpireLapsedSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	defer m.lock()()
+	t := now()
+	expired := []uuid.UUID{}
+	for userID, sub := range m.data.subscriptions {
+		if sub.Status != "expired" && !sub.CurrentPeriodEnd.After(t) {
+			sub.UpdatedAt = t
+			sub.Status = "expired"
+			m.data.subscriptions[userID] = sub
+			expired = append(expired, userID)
+		}
+	}
+	return expired, nil
+}
+
+// Derives is_chirpy_red from the user's subscription
+func (m *Memory) SyncUserChirpyRed(ctx context.Context, id uuid.UUID) error {
+	defer m.lock()()
+	user, ok := m.data.users[id]
+	if !ok {
+		return nil
+	}
+	t := now()
+	sub, ok := m.data.subscriptions[id]
+	red := ok && sub.Status != "expired" && sub.CurrentPeriodEnd.After(t)
+	if user.IsChirpyRed != red {
+		user.IsChirpyRed = red
+		user.UpdatedAt = t
+		m.data.users[id] = user
+	}
+	return nil
+}
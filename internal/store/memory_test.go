@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestMemoryTransactions(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	user, err := m.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+	if err != nil {
+		t.Fatalf("Error creating user: %s", err)
+	}
+	author := uuid.NullUUID{UUID: user.ID, Valid: true}
+
+	// Rolled back writes disappear
+	tx, err := m.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Error starting transaction: %s", err)
+	}
+	rolledBack, err := tx.CreateChirp(ctx, database.CreateChirpParams{Body: "gone", UserID: author})
+	if err != nil {
+		t.Fatalf("Error creating chirp: %s", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Error rolling back: %s", err)
+	}
+	if _, err := m.ReturnChirpIncludingDeleted(ctx, rolledBack.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for a rolled back chirp, got %v", err)
+	}
+
+	// Committed writes stay, and a later Rollback is a no-op
+	tx, err = m.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Error starting transaction: %s", err)
+	}
+	committed, err := tx.CreateChirp(ctx, database.CreateChirpParams{Body: "kept", UserID: author})
+	if err != nil {
+		t.Fatalf("Error creating chirp: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Errorf("Expected Rollback after Commit to be a no-op, got %s", err)
+	}
+	if _, err := m.ReturnChirpIncludingDeleted(ctx, committed.ID); err != nil {
+		t.Errorf("Error retrieving committed chirp: %s", err)
+	}
+}
+
+func TestMemoryUniqueUsers(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	handle := sql.NullString{String: "Alice", Valid: true}
+	if _, err := m.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", Handle: handle}); err != nil {
+		t.Fatalf("Error creating user: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		params database.CreateUserParams
+	}{
+		{"same email", database.CreateUserParams{Email: "alice@example.com"}},
+		{"handle differs in case", database.CreateUserParams{Email: "other@example.com", Handle: sql.NullString{String: "alice", Valid: true}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := m.CreateUser(ctx, tc.params); err == nil {
+				t.Errorf("Expected a unique constraint error")
+			}
+		})
+	}
+}
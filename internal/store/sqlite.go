@@ -0,0 +1,859 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/frogonabike/chirpy/internal/database/sqlitedb"
+	"github.com/google/uuid"
+)
+
+// SQLite is the sqlc-generated SQLite backend, for single-node deployments.
+// The queries in sql/sqlite/queries mirror sql/queries, and this adapter converts
+// their parameters and rows to the Postgres types the handlers use.
+// Search uses an FTS5 index, so ranks differ from Postgres but the ordering rules are the same.
+type SQLite struct {
+	sqliteQueries
+	db *sql.DB
+}
+
+var _ Store = (*SQLite)(nil)
+
+// NewSQLite creates a store backed by a SQLite database.
+// Open it with foreign keys on and _txlock=immediate, so transactions take the write lock up front.
+func NewSQLite(db *sql.DB) *SQLite {
+	return &SQLite{sqliteQueries: sqliteQueries{q: sqlitedb.New(db)}, db: db}
+}
+
+func (s *SQLite) Begin(ctx context.Context) (Tx, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteTx{sqliteQueries: sqliteQueries{q: s.q.WithTx(tx)}, tx: tx}, nil
+}
+
+func (s *SQLite) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// sqliteTx runs the queries inside a database transaction
+type sqliteTx struct {
+	sqliteQueries
+	tx *sql.Tx
+}
+
+func (t *sqliteTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqliteTx) Rollback() error {
+	err := t.tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
+
+// sqliteQueries implements Queries on top of the generated SQLite queries
+type sqliteQueries struct {
+	q *sqlitedb.Queries
+}
+
+func chirpFromSQLite(c sqlitedb.Chirp) database.Chirp {
+	return database.Chirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserID:    c.UserID,
+		InReplyTo: c.InReplyTo,
+		DeletedAt: c.DeletedAt,
+		LikeCount: int32(c.LikeCount),
+		HiddenAt:  c.HiddenAt,
+	}
+}
+
+func chirpsFromSQLite(rows []sqlitedb.Chirp, err error) ([]database.Chirp, error) {
+	if err != nil {
+		return nil, err
+	}
+	chirps := make([]database.Chirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, chirpFromSQLite(row))
+	}
+	return chirps, nil
+}
+
+func scheduledChirpsFromSQLite(rows []sqlitedb.ScheduledChirp) []database.ScheduledChirp {
+	chirps := make([]database.ScheduledChirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, database.ScheduledChirp(row))
+	}
+	return chirps
+}
+
+// Nullable timestamps go through strftime in the queries, so sqlc types them as interface{}
+func nullTimeArg(t sql.NullTime) interface{} {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time
+}
+
+// Translate Postgres web search syntax into an FTS5 query: words are ANDed,
+// "or" between words ORs them, quoted text is a phrase and a leading - excludes a word.
+// FTS5 can't match on exclusions alone, so a query without any wanted words returns "".
+func ftsQuery(query string) string {
+	var include, exclude []string
+	orNext := false
+	for len(query) > 0 {
+		query = strings.TrimLeft(query, " \t\r\n")
+		if query == "" {
+			break
+		}
+		negated := false
+		if query[0] == '-' {
+			negated = true
+			query = query[1:]
+		}
+		var term string
+		if strings.HasPrefix(query, `"`) {
+			end := strings.Index(query[1:], `"`)
+			if end < 0 {
+				term, query = query[1:], ""
+			} else {
+				term, query = query[1:end+1], query[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(query, " \t\r\n")
+			if end < 0 {
+				end = len(query)
+			}
+			term, query = query[:end], query[end:]
+		}
+		if strings.TrimSpace(term) == "" {
+			continue
+		}
+		if !negated && strings.EqualFold(term, "or") {
+			orNext = len(include) > 0
+			continue
+		}
+		quoted := `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		switch {
+		case negated:
+			exclude = append(exclude, quoted)
+		case orNext:
+			include[len(include)-1] += " OR " + quoted
+		default:
+			include = append(include, quoted)
+		}
+		orNext = false
+	}
+	if len(include) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, term := range include {
+		if i > 0 {
+			b.WriteString(" AND ")
+		}
+		b.WriteString("(" + term + ")")
+	}
+	for _, term := range exclude {
+		b.WriteString(" NOT " + term)
+	}
+	return b.String()
+}
+
+// *** ChirpStore ***
+
+func (s sqliteQueries) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	row, err := s.q.CreateChirp(ctx, sqlitedb.CreateChirpParams(arg))
+	return chirpFromSQLite(row), err
+}
+
+func (s sqliteQueries) ReturnChirp(ctx context.Context, arg database.ReturnChirpParams) (database.Chirp, error) {
+	row, err := s.q.ReturnChirp(ctx, sqlitedb.ReturnChirpParams(arg))
+	return chirpFromSQLite(row), err
+}
+
+func (s sqliteQueries) ReturnChirpIncludingDeleted(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	row, err := s.q.ReturnChirpIncludingDeleted(ctx, id)
+	return chirpFromSQLite(row), err
+}
+
+func (s sqliteQueries) ReturnChirpForUpdate(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	row, err := s.q.ReturnChirpForUpdate(ctx, id)
+	return chirpFromSQLite(row), err
+}
+
+func (s sqliteQueries) ListChirpsAsc(ctx context.Context, arg database.ListChirpsAscParams) ([]database.Chirp, error) {
+	return chirpsFromSQLite(s.q.ListChirpsAsc(ctx, sqlitedb.ListChirpsAscParams{
+		ViewerID:        arg.ViewerID,
+		IncludeHidden:   arg.IncludeHidden,
+		AuthorID:        arg.AuthorID,
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	}))
+}
+
+func (s sqliteQueries) ListChirpsDesc(ctx context.Context, arg database.ListChirpsDescParams) ([]database.Chirp, error) {
+	return chirpsFromSQLite(s.q.ListChirpsDesc(ctx, sqlitedb.ListChirpsDescParams{
+		ViewerID:        arg.ViewerID,
+		IncludeHidden:   arg.IncludeHidden,
+		AuthorID:        arg.AuthorID,
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	}))
+}
+
+func (s sqliteQueries) ListChirpsByLikes(ctx context.Context, arg database.ListChirpsByLikesParams) ([]database.Chirp, error) {
+	return chirpsFromSQLite(s.q.ListChirpsByLikes(ctx, sqlitedb.ListChirpsByLikesParams{
+		ViewerID:        arg.ViewerID,
+		IncludeHidden:   arg.IncludeHidden,
+		AuthorID:        arg.AuthorID,
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorLikeCount: sql.NullInt64{Int64: int64(arg.CursorLikeCount.Int32), Valid: arg.CursorLikeCount.Valid},
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	}))
+}
+
+func (s sqliteQueries) ListTimeline(ctx context.Context, arg database.ListTimelineParams) ([]database.Chirp, error) {
+	return chirpsFromSQLite(s.q.ListTimeline(ctx, sqlitedb.ListTimelineParams{
+		UserID:          uuid.NullUUID{UUID: arg.UserID, Valid: true},
+		IncludeHidden:   arg.IncludeHidden,
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	}))
+}
+
+func (s sqliteQueries) SearchChirps(ctx context.Context, arg database.SearchChirpsParams) ([]database.SearchChirpsRow, error) {
+	query := ftsQuery(arg.Query)
+	if query == "" {
+		return []database.SearchChirpsRow{}, nil
+	}
+	rows, err := s.q.SearchChirps(ctx, sqlitedb.SearchChirpsParams{
+		Query:           query,
+		ViewerID:        arg.ViewerID,
+		IncludeHidden:   arg.IncludeHidden,
+		AuthorID:        arg.AuthorID,
+		Since:           nullTimeArg(arg.Since),
+		Until:           nullTimeArg(arg.Until),
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorRank:      arg.CursorRank,
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]database.SearchChirpsRow, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, database.SearchChirpsRow{
+			Chirp:   chirpFromSQLite(row.Chirp),
+			Rank:    float32(row.SearchRank),
+			Snippet: highlightedSnippet(row.Snippet, row.Chirp.Body),
+		})
+	}
+	return results, nil
+}
+
+// Markers the SearchChirps query puts around matching words
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// HTML-escape a snippet from the SearchChirps query and swap its markers for <mark> tags
+// A body that already contains the markers can't be told apart from them, so it isn't highlighted
+func highlightedSnippet(snippet, body string) string {
+	if strings.ContainsAny(body, highlightStart+highlightStop) {
+		return html.EscapeString(body)
+	}
+	snippet = html.EscapeString(snippet)
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(snippet)
+}
+
+func (s sqliteQueries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]database.Chirp, error) {
+	return chirpsFromSQLite(s.q.GetChirpAncestors(ctx, id))
+}
+
+func (s sqliteQueries) GetChirpDescendants(ctx context.Context, id uuid.UUID) ([]database.Chirp, error) {
+	return chirpsFromSQLite(s.q.GetChirpDescendants(ctx, uuid.NullUUID{UUID: id, Valid: true}))
+}
+
+func (s sqliteQueries) UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error) {
+	row, err := s.q.UpdateChirpBody(ctx, sqlitedb.UpdateChirpBodyParams{Body: arg.Body, ID: arg.ID})
+	return chirpFromSQLite(row), err
+}
+
+func (s sqliteQueries) DeleteChirp(ctx context.Context, arg database.DeleteChirpParams) error {
+	return s.q.DeleteChirp(ctx, sqlitedb.DeleteChirpParams(arg))
+}
+
+func (s sqliteQueries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	return s.q.HideChirp(ctx, id)
+}
+
+func (s sqliteQueries) UnhideChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	return s.q.UnhideChirp(ctx, id)
+}
+
+func (s sqliteQueries) CreateChirpRevision(ctx context.Context, arg database.CreateChirpRevisionParams) (database.ChirpRevision, error) {
+	row, err := s.q.CreateChirpRevision(ctx, sqlitedb.CreateChirpRevisionParams(arg))
+	return database.ChirpRevision(row), err
+}
+
+func (s sqliteQueries) ListChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]database.ChirpRevision, error) {
+	rows, err := s.q.ListChirpRevisions(ctx, chirpID)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]database.ChirpRevision, 0, len(rows))
+	for _, row := range rows {
+		revisions = append(revisions, database.ChirpRevision(row))
+	}
+	return revisions, nil
+}
+
+func (s sqliteQueries) CreateScheduledChirp(ctx context.Context, arg database.CreateScheduledChirpParams) (database.ScheduledChirp, error) {
+	row, err := s.q.CreateScheduledChirp(ctx, sqlitedb.CreateScheduledChirpParams{
+		UserID:    arg.UserID,
+		Body:      arg.Body,
+		InReplyTo: arg.InReplyTo,
+		PublishAt: arg.PublishAt,
+	})
+	return database.ScheduledChirp(row), err
+}
+
+func (s sqliteQueries) ListUserScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.ScheduledChirp, error) {
+	rows, err := s.q.ListUserScheduledChirps(ctx, userID)
+	return scheduledChirpsFromSQLite(rows), err
+}
+
+func (s sqliteQueries) DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error) {
+	return s.q.DeleteScheduledChirp(ctx, sqlitedb.DeleteScheduledChirpParams(arg))
+}
+
+func (s sqliteQueries) ListDueScheduledChirps(ctx context.Context, arg database.ListDueScheduledChirpsParams) ([]database.ScheduledChirp, error) {
+	rows, err := s.q.ListDueScheduledChirps(ctx, sqlitedb.ListDueScheduledChirpsParams{
+		PublishBefore: arg.PublishBefore,
+		PageLimit:     int64(arg.PageLimit),
+	})
+	return scheduledChirpsFromSQLite(rows), err
+}
+
+func (s sqliteQueries) LikeChirp(ctx context.Context, arg database.LikeChirpParams) (int64, error) {
+	return s.q.LikeChirp(ctx, sqlitedb.LikeChirpParams(arg))
+}
+
+func (s sqliteQueries) UnlikeChirp(ctx context.Context, arg database.UnlikeChirpParams) (int64, error) {
+	return s.q.UnlikeChirp(ctx, sqlitedb.UnlikeChirpParams(arg))
+}
+
+func (s sqliteQueries) AdjustChirpLikeCount(ctx context.Context, arg database.AdjustChirpLikeCountParams) error {
+	return s.q.AdjustChirpLikeCount(ctx, sqlitedb.AdjustChirpLikeCountParams{Delta: int64(arg.Delta), ID: arg.ID})
+}
+
+func (s sqliteQueries) ListLikedChirpIDs(ctx context.Context, arg database.ListLikedChirpIDsParams) ([]uuid.UUID, error) {
+	return s.q.ListLikedChirpIDs(ctx, sqlitedb.ListLikedChirpIDsParams(arg))
+}
+
+// SQLite has no unnest, so the tags are upserted one at a time
+func (s sqliteQueries) UpsertTags(ctx context.Context, names []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(names))
+	for _, name := range names {
+		id, err := s.q.UpsertTag(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s sqliteQueries) AddChirpTags(ctx context.Context, arg database.AddChirpTagsParams) error {
+	return s.q.AddChirpTags(ctx, sqlitedb.AddChirpTagsParams(arg))
+}
+
+func (s sqliteQueries) DeleteStaleChirpTags(ctx context.Context, arg database.DeleteStaleChirpTagsParams) error {
+	return s.q.DeleteStaleChirpTags(ctx, sqlitedb.DeleteStaleChirpTagsParams(arg))
+}
+
+func (s sqliteQueries) ListTagChirps(ctx context.Context, arg database.ListTagChirpsParams) ([]database.Chirp, error) {
+	return chirpsFromSQLite(s.q.ListTagChirps(ctx, sqlitedb.ListTagChirpsParams{
+		Tag:             arg.Tag,
+		ViewerID:        arg.ViewerID,
+		IncludeHidden:   arg.IncludeHidden,
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	}))
+}
+
+func (s sqliteQueries) ListTrendingTags(ctx context.Context, arg database.ListTrendingTagsParams) ([]database.ListTrendingTagsRow, error) {
+	rows, err := s.q.ListTrendingTags(ctx, sqlitedb.ListTrendingTagsParams{WindowSeconds: arg.WindowSeconds, TagLimit: int64(arg.TagLimit)})
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]database.ListTrendingTagsRow, 0, len(rows))
+	for _, row := range rows {
+		tags = append(tags, database.ListTrendingTagsRow(row))
+	}
+	return tags, nil
+}
+
+func (s sqliteQueries) AddChirpMentions(ctx context.Context, arg database.AddChirpMentionsParams) error {
+	return s.q.AddChirpMentions(ctx, sqlitedb.AddChirpMentionsParams(arg))
+}
+
+func (s sqliteQueries) DeleteChirpMentions(ctx context.Context, chirpID uuid.UUID) error {
+	return s.q.DeleteChirpMentions(ctx, chirpID)
+}
+
+func (s sqliteQueries) ListMentions(ctx context.Context, arg database.ListMentionsParams) ([]database.Chirp, error) {
+	return chirpsFromSQLite(s.q.ListMentions(ctx, sqlitedb.ListMentionsParams{
+		UserID:          arg.UserID,
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	}))
+}
+
+// *** UserStore ***
+
+func (s sqliteQueries) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.CreateUserRow, error) {
+	row, err := s.q.CreateUser(ctx, sqlitedb.CreateUserParams(arg))
+	return database.CreateUserRow(row), err
+}
+
+func (s sqliteQueries) BootstrapAdmin(ctx context.Context, arg database.BootstrapAdminParams) (uuid.UUID, error) {
+	return s.q.BootstrapAdmin(ctx, sqlitedb.BootstrapAdminParams(arg))
+}
+
+func (s sqliteQueries) UserLogin(ctx context.Context, email string) (database.User, error) {
+	row, err := s.q.UserLogin(ctx, email)
+	return database.User(row), err
+}
+
+func (s sqliteQueries) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.UpdateUserRow, error) {
+	row, err := s.q.UpdateUser(ctx, sqlitedb.UpdateUserParams(arg))
+	return database.UpdateUserRow(row), err
+}
+
+func (s sqliteQueries) GetUserByID(ctx context.Context, id uuid.UUID) (database.GetUserByIDRow, error) {
+	row, err := s.q.GetUserByID(ctx, id)
+	return database.GetUserByIDRow(row), err
+}
+
+func (s sqliteQueries) GetUserByHandle(ctx context.Context, handle string) (database.GetUserByHandleRow, error) {
+	row, err := s.q.GetUserByHandle(ctx, handle)
+	return database.GetUserByHandleRow(row), err
+}
+
+func (s sqliteQueries) GetUserAccess(ctx context.Context, id uuid.UUID) (database.GetUserAccessRow, error) {
+	row, err := s.q.GetUserAccess(ctx, id)
+	return database.GetUserAccessRow(row), err
+}
+
+// The query matches handles and email local parts in separate lists, so the names go in both
+func (s sqliteQueries) FindMentionCandidates(ctx context.Context, names []string) ([]database.FindMentionCandidatesRow, error) {
+	keys := make([]sql.NullString, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, sql.NullString{String: name, Valid: true})
+	}
+	rows, err := s.q.FindMentionCandidates(ctx, sqlitedb.FindMentionCandidatesParams{Handles: keys, LocalParts: keys})
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]database.FindMentionCandidatesRow, 0, len(rows))
+	for _, row := range rows {
+		candidates = append(candidates, database.FindMentionCandidatesRow(row))
+	}
+	return candidates, nil
+}
+
+func (s sqliteQueries) IsUserSuspended(ctx context.Context, id uuid.UUID) (bool, error) {
+	return s.q.IsUserSuspended(ctx, id)
+}
+
+func (s sqliteQueries) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	return s.q.SuspendUser(ctx, id)
+}
+
+func (s sqliteQueries) UnsuspendUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	return s.q.UnsuspendUser(ctx, id)
+}
+
+func (s sqliteQueries) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (int64, error) {
+	return s.q.SetUserRole(ctx, sqlitedb.SetUserRoleParams{Role: arg.Role, ID: arg.ID})
+}
+
+func (s sqliteQueries) ResetUsers(ctx context.Context) error {
+	return s.q.ResetUsers(ctx)
+}
+
+func (s sqliteQueries) FollowUser(ctx context.Context, arg database.FollowUserParams) error {
+	return s.q.FollowUser(ctx, sqlitedb.FollowUserParams(arg))
+}
+
+func (s sqliteQueries) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error {
+	return s.q.UnfollowUser(ctx, sqlitedb.UnfollowUserParams(arg))
+}
+
+func (s sqliteQueries) ListFollowers(ctx context.Context, arg database.ListFollowersParams) ([]database.ListFollowersRow, error) {
+	rows, err := s.q.ListFollowers(ctx, sqlitedb.ListFollowersParams{
+		UserID:          arg.UserID,
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	follows := make([]database.ListFollowersRow, 0, len(rows))
+	for _, row := range rows {
+		follows = append(follows, database.ListFollowersRow(row))
+	}
+	return follows, nil
+}
+
+func (s sqliteQueries) ListFollowing(ctx context.Context, arg database.ListFollowingParams) ([]database.ListFollowingRow, error) {
+	rows, err := s.q.ListFollowing(ctx, sqlitedb.ListFollowingParams{
+		UserID:          arg.UserID,
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	follows := make([]database.ListFollowingRow, 0, len(rows))
+	for _, row := range rows {
+		follows = append(follows, database.ListFollowingRow(row))
+	}
+	return follows, nil
+}
+
+// *** TokenStore ***
+
+func (s sqliteQueries) CreateRToken(ctx context.Context, arg database.CreateRTokenParams) (database.RefreshToken, error) {
+	row, err := s.q.CreateRToken(ctx, sqlitedb.CreateRTokenParams(arg))
+	return database.RefreshToken(row), err
+}
+
+func (s sqliteQueries) GetRTokenForUpdate(ctx context.Context, token string) (database.RefreshToken, error) {
+	row, err := s.q.GetRTokenForUpdate(ctx, token)
+	return database.RefreshToken(row), err
+}
+
+func (s sqliteQueries) RevokeRToken(ctx context.Context, token string) ([]uuid.UUID, error) {
+	return s.q.RevokeRToken(ctx, token)
+}
+
+func (s sqliteQueries) RevokeRTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	return s.q.RevokeRTokenFamily(ctx, familyID)
+}
+
+func (s sqliteQueries) RevokeUserRTokens(ctx context.Context, userID uuid.NullUUID) ([]uuid.UUID, error) {
+	return s.q.RevokeUserRTokens(ctx, userID)
+}
+
+func (s sqliteQueries) RevokeOtherUserRTokens(ctx context.Context, arg database.RevokeOtherUserRTokensParams) ([]uuid.UUID, error) {
+	return s.q.RevokeOtherUserRTokens(ctx, sqlitedb.RevokeOtherUserRTokensParams(arg))
+}
+
+func (s sqliteQueries) RevokeUserSession(ctx context.Context, arg database.RevokeUserSessionParams) (int64, error) {
+	return s.q.RevokeUserSession(ctx, sqlitedb.RevokeUserSessionParams(arg))
+}
+
+func (s sqliteQueries) ListUserSessions(ctx context.Context, userID uuid.NullUUID) ([]database.ListUserSessionsRow, error) {
+	rows, err := s.q.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]database.ListUserSessionsRow, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, database.ListUserSessionsRow(row))
+	}
+	return sessions, nil
+}
+
+func (s sqliteQueries) DenyToken(ctx context.Context, arg database.DenyTokenParams) error {
+	return s.q.DenyToken(ctx, sqlitedb.DenyTokenParams{ID: arg.ID, ExpiresAt: arg.ExpiresAt})
+}
+
+func (s sqliteQueries) ListDeniedTokens(ctx context.Context) ([]database.ListDeniedTokensRow, error) {
+	rows, err := s.q.ListDeniedTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	denied := make([]database.ListDeniedTokensRow, 0, len(rows))
+	for _, row := range rows {
+		denied = append(denied, database.ListDeniedTokensRow(row))
+	}
+	return denied, nil
+}
+
+func (s sqliteQueries) DeleteExpiredDeniedTokens(ctx context.Context) (int64, error) {
+	return s.q.DeleteExpiredDeniedTokens(ctx)
+}
+
+// *** ModerationStore ***
+
+func (s sqliteQueries) ListModerationWords(ctx context.Context) ([]database.ModerationWord, error) {
+	rows, err := s.q.ListModerationWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	words := make([]database.ModerationWord, 0, len(rows))
+	for _, row := range rows {
+		words = append(words, database.ModerationWord(row))
+	}
+	return words, nil
+}
+
+func (s sqliteQueries) UpsertModerationWord(ctx context.Context, arg database.UpsertModerationWordParams) (database.ModerationWord, error) {
+	row, err := s.q.UpsertModerationWord(ctx, sqlitedb.UpsertModerationWordParams(arg))
+	return database.ModerationWord(row), err
+}
+
+func (s sqliteQueries) DeleteModerationWord(ctx context.Context, word string) (int64, error) {
+	return s.q.DeleteModerationWord(ctx, word)
+}
+
+func (s sqliteQueries) CreateModerationFlag(ctx context.Context, arg database.CreateModerationFlagParams) error {
+	return s.q.CreateModerationFlag(ctx, sqlitedb.CreateModerationFlagParams(arg))
+}
+
+func (s sqliteQueries) ListPendingModerationFlags(ctx context.Context, arg database.ListPendingModerationFlagsParams) ([]database.ListPendingModerationFlagsRow, error) {
+	rows, err := s.q.ListPendingModerationFlags(ctx, sqlitedb.ListPendingModerationFlagsParams{
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	flags := make([]database.ListPendingModerationFlagsRow, 0, len(rows))
+	for _, row := range rows {
+		flags = append(flags, database.ListPendingModerationFlagsRow{
+			ModerationFlag: database.ModerationFlag(row.ModerationFlag),
+			Chirp:          chirpFromSQLite(row.Chirp),
+		})
+	}
+	return flags, nil
+}
+
+func (s sqliteQueries) ReviewModerationFlag(ctx context.Context, id uuid.UUID) (int64, error) {
+	return s.q.ReviewModerationFlag(ctx, id)
+}
+
+func (s sqliteQueries) CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error) {
+	row, err := s.q.CreateReport(ctx, sqlitedb.CreateReportParams(arg))
+	return database.Report(row), err
+}
+
+func (s sqliteQueries) ReturnReport(ctx context.Context, id uuid.UUID) (database.Report, error) {
+	row, err := s.q.ReturnReport(ctx, id)
+	return database.Report(row), err
+}
+
+func (s sqliteQueries) ListReports(ctx context.Context, arg database.ListReportsParams) ([]database.ListReportsRow, error) {
+	rows, err := s.q.ListReports(ctx, sqlitedb.ListReportsParams{
+		Status:          arg.Status,
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]database.ListReportsRow, 0, len(rows))
+	for _, row := range rows {
+		reports = append(reports, database.ListReportsRow{
+			Report: database.Report(row.Report),
+			Chirp:  chirpFromSQLite(row.Chirp),
+		})
+	}
+	return reports, nil
+}
+
+func (s sqliteQueries) ResolveReport(ctx context.Context, arg database.ResolveReportParams) (database.Report, error) {
+	row, err := s.q.ResolveReport(ctx, sqlitedb.ResolveReportParams{Status: arg.Status, ID: arg.ID})
+	return database.Report(row), err
+}
+
+func (s sqliteQueries) ResolveOpenChirpReports(ctx context.Context, arg database.ResolveOpenChirpReportsParams) error {
+	return s.q.ResolveOpenChirpReports(ctx, sqlitedb.ResolveOpenChirpReportsParams{Status: arg.Status, ChirpID: arg.ChirpID})
+}
+
+// *** WebhookStore ***
+
+func (s sqliteQueries) RecordWebhookEvent(ctx context.Context, arg database.RecordWebhookEventParams) (int64, error) {
+	return s.q.RecordWebhookEvent(ctx, sqlitedb.RecordWebhookEventParams(arg))
+}
+
+// *** OutboundWebhookStore ***
+
+func (s sqliteQueries) CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	row, err := s.q.CreateWebhookSubscription(ctx, sqlitedb.CreateWebhookSubscriptionParams(arg))
+	return database.WebhookSubscription(row), err
+}
+
+func (s sqliteQueries) AddWebhookSubscriptionEvents(ctx context.Context, arg database.AddWebhookSubscriptionEventsParams) error {
+	for _, event := range arg.Events {
+		err := s.q.AddWebhookSubscriptionEvent(ctx, sqlitedb.AddWebhookSubscriptionEventParams{
+			SubscriptionID: arg.SubscriptionID,
+			Event:          event,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s sqliteQueries) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (database.WebhookSubscription, error) {
+	row, err := s.q.GetWebhookSubscription(ctx, id)
+	return database.WebhookSubscription(row), err
+}
+
+func (s sqliteQueries) ListWebhookSubscriptions(ctx context.Context, userID uuid.NullUUID) ([]database.WebhookSubscription, error) {
+	rows, err := s.q.ListWebhookSubscriptions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := make([]database.WebhookSubscription, 0, len(rows))
+	for _, row := range rows {
+		subscriptions = append(subscriptions, database.WebhookSubscription(row))
+	}
+	return subscriptions, nil
+}
+
+func (s sqliteQueries) ListWebhookSubscriptionEvents(ctx context.Context, subscriptionID uuid.UUID) ([]string, error) {
+	return s.q.ListWebhookSubscriptionEvents(ctx, subscriptionID)
+}
+
+func (s sqliteQueries) DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) (int64, error) {
+	return s.q.DeleteWebhookSubscription(ctx, sqlitedb.DeleteWebhookSubscriptionParams(arg))
+}
+
+func (s sqliteQueries) EnqueueWebhookDeliveries(ctx context.Context, arg database.EnqueueWebhookDeliveriesParams) (int64, error) {
+	return s.q.EnqueueWebhookDeliveries(ctx, sqlitedb.EnqueueWebhookDeliveriesParams{
+		Payload: arg.Payload,
+		Event:   arg.Event,
+		UserID:  uuid.NullUUID{UUID: arg.UserID, Valid: true},
+	})
+}
+
+func (s sqliteQueries) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	rows, err := s.q.ClaimWebhookDeliveries(ctx, sqlitedb.ClaimWebhookDeliveriesParams{
+		LeaseUntil: arg.LeaseUntil,
+		DueBy:      arg.DueBy,
+		PageLimit:  int64(arg.PageLimit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]database.WebhookDelivery, 0, len(rows))
+	for _, row := range rows {
+		deliveries = append(deliveries, database.WebhookDelivery{
+			ID:             row.ID,
+			CreatedAt:      row.CreatedAt,
+			UpdatedAt:      row.UpdatedAt,
+			SubscriptionID: row.SubscriptionID,
+			Event:          row.Event,
+			Payload:        row.Payload,
+			Status:         row.Status,
+			Attempts:       int32(row.Attempts),
+			NextAttemptAt:  row.NextAttemptAt,
+		})
+	}
+	return deliveries, nil
+}
+
+func (s sqliteQueries) RecordWebhookDeliveryAttempt(ctx context.Context, arg database.RecordWebhookDeliveryAttemptParams) error {
+	return s.q.RecordWebhookDeliveryAttempt(ctx, sqlitedb.RecordWebhookDeliveryAttemptParams{
+		DeliveryID:     arg.DeliveryID,
+		ResponseStatus: sql.NullInt64{Int64: int64(arg.ResponseStatus.Int32), Valid: arg.ResponseStatus.Valid},
+		ResponseBody:   arg.ResponseBody,
+		Error:          arg.Error,
+	})
+}
+
+func (s sqliteQueries) UpdateWebhookDelivery(ctx context.Context, arg database.UpdateWebhookDeliveryParams) error {
+	return s.q.UpdateWebhookDelivery(ctx, sqlitedb.UpdateWebhookDeliveryParams{
+		Status:        arg.Status,
+		NextAttemptAt: arg.NextAttemptAt,
+		ID:            arg.ID,
+	})
+}
+
+func (s sqliteQueries) ListWebhookDeliveryAttempts(ctx context.Context, arg database.ListWebhookDeliveryAttemptsParams) ([]database.ListWebhookDeliveryAttemptsRow, error) {
+	rows, err := s.q.ListWebhookDeliveryAttempts(ctx, sqlitedb.ListWebhookDeliveryAttemptsParams{
+		SubscriptionID:  arg.SubscriptionID,
+		CursorCreatedAt: nullTimeArg(arg.CursorCreatedAt),
+		CursorID:        arg.CursorID,
+		PageLimit:       int64(arg.PageLimit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	attempts := make([]database.ListWebhookDeliveryAttemptsRow, 0, len(rows))
+	for _, row := range rows {
+		attempts = append(attempts, database.ListWebhookDeliveryAttemptsRow{
+			ID:             row.ID,
+			CreatedAt:      row.CreatedAt,
+			DeliveryID:     row.DeliveryID,
+			Event:          row.Event,
+			Status:         row.Status,
+			ResponseStatus: sql.NullInt32{Int32: int32(row.ResponseStatus.Int64), Valid: row.ResponseStatus.Valid},
+			ResponseBody:   row.ResponseBody,
+			Error:          row.Error,
+		})
+	}
+	return attempts, nil
+}
+
+func (s sqliteQueries) DeleteOldWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	return s.q.DeleteOldWebhookDeliveries(ctx, before)
+}
+
+// *** SubscriptionStore ***
+
+func (s sqliteQueries) UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (int64, error) {
+	return s.q.UpsertSubscription(ctx, sqlitedb.UpsertSubscriptionParams{
+		Plan:             arg.Plan,
+		Status:           arg.Status,
+		CurrentPeriodEnd: arg.CurrentPeriodEnd,
+		PolkaCustomerID:  arg.PolkaCustomerID,
+		UserID:           arg.UserID,
+	})
+}
+
+func (s sqliteQueries) UpdateSubscriptionStatus(ctx context.Context, arg database.UpdateSubscriptionStatusParams) (int64, error) {
+	return s.q.UpdateSubscriptionStatus(ctx, sqlitedb.UpdateSubscriptionStatusParams{
+		Status:           arg.Status,
+		CurrentPeriodEnd: nullTimeArg(arg.CurrentPeriodEnd),
+		UserID:           arg.UserID,
+	})
+}
+
+func (s sqliteQueries) ExpireLapsedSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	return s.q.ExpireLapsedSubscriptions(ctx)
+}
+
+func (s sqliteQueries) SyncUserChirpyRed(ctx context.Context, id uuid.UUID) error {
+	return s.q.SyncUserChirpyRed(ctx, id)
+}
@@ -0,0 +1,44 @@
+package store
+
+import "testing"
+
+func TestFTSQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "hello", want: `("hello")`},
+		{query: "hello world", want: `("hello") AND ("world")`},
+		{query: "cats or dogs", want: `("cats" OR "dogs")`},
+		{query: `"hello world" -spam`, want: `("hello world") NOT "spam"`},
+		{query: `say "hi`, want: `("say") AND ("hi")`},
+		{query: `quo"te`, want: `("quo""te")`},
+		{query: "or hello", want: `("hello")`},
+		{query: "-spam", want: ""},
+		{query: "  ", want: ""},
+	}
+
+	for _, tc := range tests {
+		if got := ftsQuery(tc.query); got != tc.want {
+			t.Errorf("ftsQuery(%q): expected %s, got %s", tc.query, tc.want, got)
+		}
+	}
+}
+
+func TestHighlightedSnippet(t *testing.T) {
+	tests := []struct {
+		snippet string
+		body    string
+		want    string
+	}{
+		{snippet: "hello \x02world\x03", body: "hello world", want: "hello <mark>world</mark>"},
+		{snippet: "<b>\x02bold\x03</b> & co", body: "<b>bold</b> & co", want: "&lt;b&gt;<mark>bold</mark>&lt;/b&gt; &amp; co"},
+		{snippet: "\x02fake\x03 \x02word\x03", body: "\x02fake\x03 word", want: "\x02fake\x03 word"},
+	}
+
+	for _, tc := range tests {
+		if got := highlightedSnippet(tc.snippet, tc.body); got != tc.want {
+			t.Errorf("highlightedSnippet(%q): expected %q, got %q", tc.snippet, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Package store is the storage layer the handlers depend on.
+// The method sets mirror the sqlc queries in internal/database, so *database.Queries
+// provides the Postgres implementation, and Memory provides one for tests and local dev.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// ChirpStore holds chirps and what hangs off them: revisions, likes, tags and mentions
+type ChirpStore interface {
+	CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+	ReturnChirp(ctx context.Context, arg database.ReturnChirpParams) (database.Chirp, error)
+	ReturnChirpIncludingDeleted(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	ReturnChirpForUpdate(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	ListChirpsAsc(ctx context.Context, arg database.ListChirpsAscParams) ([]database.Chirp, error)
+	ListChirpsDesc(ctx context.Context, arg database.ListChirpsDescParams) ([]database.Chirp, error)
+	ListChirpsByLikes(ctx context.Context, arg database.ListChirpsByLikesParams) ([]database.Chirp, error)
+	ListTimeline(ctx context.Context, arg database.ListTimelineParams) ([]database.Chirp, error)
+	SearchChirps(ctx context.Context, arg database.SearchChirpsParams) ([]database.SearchChirpsRow, error)
+	GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]database.Chirp, error)
+	GetChirpDescendants(ctx context.Context, id uuid.UUID) ([]database.Chirp, error)
+	UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error)
+	DeleteChirp(ctx context.Context, arg database.DeleteChirpParams) error
+	HideChirp(ctx context.Context, id uuid.UUID) error
+	UnhideChirp(ctx context.Context, id uuid.UUID) (int64, error)
+
+	CreateChirpRevision(ctx context.Context, arg database.CreateChirpRevisionParams) (database.ChirpRevision, error)
+	ListChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]database.ChirpRevision, error)
+
+	CreateScheduledChirp(ctx context.Context, arg database.CreateScheduledChirpParams) (database.ScheduledChirp, error)
+	ListUserScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.ScheduledChirp, error)
+	DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error)
+	ListDueScheduledChirps(ctx context.Context, arg database.ListDueScheduledChirpsParams) ([]database.ScheduledChirp, error)
+
+	LikeChirp(ctx context.Context, arg database.LikeChirpParams) (int64, error)
+	UnlikeChirp(ctx context.Context, arg database.UnlikeChirpParams) (int64, error)
+	AdjustChirpLikeCount(ctx context.Context, arg database.AdjustChirpLikeCountParams) error
+	ListLikedChirpIDs(ctx context.Context, arg database.ListLikedChirpIDsParams) ([]uuid.UUID, error)
+
+	UpsertTags(ctx context.Context, names []string) ([]uuid.UUID, error)
+	AddChirpTags(ctx context.Context, arg database.AddChirpTagsParams) error
+	DeleteStaleChirpTags(ctx context.Context, arg database.DeleteStaleChirpTagsParams) error
+	ListTagChirps(ctx context.Context, arg database.ListTagChirpsParams) ([]database.Chirp, error)
+	ListTrendingTags(ctx context.Context, arg database.ListTrendingTagsParams) ([]database.ListTrendingTagsRow, error)
+
+	AddChirpMentions(ctx context.Context, arg database.AddChirpMentionsParams) error
+	DeleteChirpMentions(ctx context.Context, chirpID uuid.UUID) error
+	ListMentions(ctx context.Context, arg database.ListMentionsParams) ([]database.Chirp, error)
+}
+
+// UserStore holds users, their roles and who follows whom
+type UserStore interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.CreateUserRow, error)
+	BootstrapAdmin(ctx context.Context, arg database.BootstrapAdminParams) (uuid.UUID, error)
+	UserLogin(ctx context.Context, email string) (database.User, error)
+	UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.UpdateUserRow, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (database.GetUserByIDRow, error)
+	GetUserByHandle(ctx context.Context, handle string) (database.GetUserByHandleRow, error)
+	GetUserAccess(ctx context.Context, id uuid.UUID) (database.GetUserAccessRow, error)
+	FindMentionCandidates(ctx context.Context, names []string) ([]database.FindMentionCandidatesRow, error)
+	IsUserSuspended(ctx context.Context, id uuid.UUID) (bool, error)
+	SuspendUser(ctx context.Context, id uuid.UUID) error
+	UnsuspendUser(ctx context.Context, id uuid.UUID) (int64, error)
+	SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (int64, error)
+	ResetUsers(ctx context.Context) error
+
+	FollowUser(ctx context.Context, arg database.FollowUserParams) error
+	UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error
+	ListFollowers(ctx context.Context, arg database.ListFollowersParams) ([]database.ListFollowersRow, error)
+	ListFollowing(ctx context.Context, arg database.ListFollowingParams) ([]database.ListFollowingRow, error)
+}
+
+// TokenStore holds refresh tokens, grouped into a family per login,
+// and the denylist of revoked access tokens and sessions
+type TokenStore interface {
+	CreateRToken(ctx context.Context, arg database.CreateRTokenParams) (database.RefreshToken, error)
+	GetRTokenForUpdate(ctx context.Context, token string) (database.RefreshToken, error)
+	RevokeRToken(ctx context.Context, token string) ([]uuid.UUID, error)
+	RevokeRTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUserRTokens(ctx context.Context, userID uuid.NullUUID) ([]uuid.UUID, error)
+	RevokeOtherUserRTokens(ctx context.Context, arg database.RevokeOtherUserRTokensParams) ([]uuid.UUID, error)
+	RevokeUserSession(ctx context.Context, arg database.RevokeUserSessionParams) (int64, error)
+	ListUserSessions(ctx context.Context, userID uuid.NullUUID) ([]database.ListUserSessionsRow, error)
+
+	DenyToken(ctx context.Context, arg database.DenyTokenParams) error
+	ListDeniedTokens(ctx context.Context) ([]database.ListDeniedTokensRow, error)
+	DeleteExpiredDeniedTokens(ctx context.Context) (int64, error)
+}
+
+// ModerationStore holds the banned word list, flagged chirps and user reports
+type ModerationStore interface {
+	ListModerationWords(ctx context.Context) ([]database.ModerationWord, error)
+	UpsertModerationWord(ctx context.Context, arg database.UpsertModerationWordParams) (database.ModerationWord, error)
+	DeleteModerationWord(ctx context.Context, word string) (int64, error)
+	CreateModerationFlag(ctx context.Context, arg database.CreateModerationFlagParams) error
+	ListPendingModerationFlags(ctx context.Context, arg database.ListPendingModerationFlagsParams) ([]database.ListPendingModerationFlagsRow, error)
+	ReviewModerationFlag(ctx context.Context, id uuid.UUID) (int64, error)
+
+	CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error)
+	ReturnReport(ctx context.Context, id uuid.UUID) (database.Report, error)
+	ListReports(ctx context.Context, arg database.ListReportsParams) ([]database.ListReportsRow, error)
+	ResolveReport(ctx context.Context, arg database.ResolveReportParams) (database.Report, error)
+	ResolveOpenChirpReports(ctx context.Context, arg database.ResolveOpenChirpReportsParams) error
+}
+
+// WebhookStore records the webhook events already processed
+type WebhookStore interface {
+	RecordWebhookEvent(ctx context.Context, arg database.RecordWebhookEventParams) (int64, error)
+}
+
+// OutboundWebhookStore holds the webhooks third parties subscribe to, the queue of deliveries
+// to send them, and a log of each attempt
+type OutboundWebhookStore interface {
+	CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error)
+	AddWebhookSubscriptionEvents(ctx context.Context, arg database.AddWebhookSubscriptionEventsParams) error
+	GetWebhookSubscription(ctx context.Context, id uuid.UUID) (database.WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context, userID uuid.NullUUID) ([]database.WebhookSubscription, error)
+	ListWebhookSubscriptionEvents(ctx context.Context, subscriptionID uuid.UUID) ([]string, error)
+	DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) (int64, error)
+
+	EnqueueWebhookDeliveries(ctx context.Context, arg database.EnqueueWebhookDeliveriesParams) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.WebhookDelivery, error)
+	RecordWebhookDeliveryAttempt(ctx context.Context, arg database.RecordWebhookDeliveryAttemptParams) error
+	UpdateWebhookDelivery(ctx context.Context, arg database.UpdateWebhookDeliveryParams) error
+	ListWebhookDeliveryAttempts(ctx context.Context, arg database.ListWebhookDeliveryAttemptsParams) ([]database.ListWebhookDeliveryAttemptsRow, error)
+	DeleteOldWebhookDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// SubscriptionStore holds Chirpy Red subscriptions
+// SyncUserChirpyRed must be called after changing one, to keep users.is_chirpy_red in step
+type SubscriptionStore interface {
+	UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (int64, error)
+	UpdateSubscriptionStatus(ctx context.Context, arg database.UpdateSubscriptionStatusParams) (int64, error)
+	ExpireLapsedSubscriptions(ctx context.Context) ([]uuid.UUID, error)
+	SyncUserChirpyRed(ctx context.Context, id uuid.UUID) error
+}
+
+// Queries is every query, as run directly or inside a transaction
+type Queries interface {
+	ChirpStore
+	UserStore
+	TokenStore
+	ModerationStore
+	WebhookStore
+	OutboundWebhookStore
+	SubscriptionStore
+}
+
+// Store is a storage backend
+type Store interface {
+	Queries
+
+	// Begin starts a transaction - call Rollback when done, which is a no-op after Commit
+	Begin(ctx context.Context) (Tx, error)
+
+	// Ping checks the backend is reachable
+	Ping(ctx context.Context) error
+}
+
+// Tx is a transaction, whose writes are applied together on Commit
+type Tx interface {
+	Queries
+	Commit() error
+	Rollback() error
+}
+
+// Postgres is the sqlc-generated Postgres backend
+type Postgres struct {
+	*database.Queries
+	db *sql.DB
+}
+
+var _ Store = (*Postgres)(nil)
+
+// NewPostgres creates a store backed by a Postgres connection pool
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{Queries: database.New(db), db: db}
+}
+
+func (p *Postgres) Begin(ctx context.Context) (Tx, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresTx{Queries: p.Queries.WithTx(tx), tx: tx}, nil
+}
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
+// postgresTx runs the sqlc queries inside a database transaction
+type postgresTx struct {
+	*database.Queries
+	tx *sql.Tx
+}
+
+func (t *postgresTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *postgresTx) Rollback() error {
+	err := t.tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
@@ -1,26 +1,60 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
 	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/logging"
+	"github.com/frogonabike/chirpy/internal/metrics"
+	"github.com/frogonabike/chirpy/internal/moderation"
+	"github.com/frogonabike/chirpy/internal/ratelimit"
+	"github.com/frogonabike/chirpy/internal/store"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 // Configuration struct for stateful data
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	dbQueries      *database.Queries
-	platform       string
-	jwtSecret      string
-	polkaKey       string
+	server        serverConfig
+	schemaVersion int64
+	shuttingDown  atomic.Bool
+	metrics       *metrics.Metrics
+	db            *sql.DB // nil with the in-memory store
+	store         store.Store
+	platform      string
+	jwtSecret     string
+	polkaKeys     []string // any of them can sign webhooks, for rotating POLKA_KEY
+
+	// Banned word rules - from MODERATION_WORDS_FILE and the moderation_words table
+	moderation          *moderation.Engine
+	moderationFileRules []moderation.Rule
+
+	// Revoked access tokens and sessions - from the denied_tokens table
+	denylist *auth.Denylist
+
+	// Sends outbound webhooks, refusing internal addresses unless webhookAllowPrivate is set for tests
+	webhookClient       *http.Client
+	webhookAllowPrivate bool
+
+	// Proxies whose X-Forwarded-For headers are trusted to give the client's address
+	trustedProxies []netip.Prefix
+
+	// Token buckets for the routes in rateLimits - no limits are applied if rateLimiter is nil
+	rateLimiter ratelimit.Backend
+	rateLimits  ratelimit.Rules
 }
 
 // *** API models - with JSON tags for serialization ***
@@ -34,15 +68,128 @@ type User struct {
 	Token        string    `json:"token"`
 	RefreshToken string    `json:"refresh_token"`
 	ChirpyRed    bool      `json:"is_chirpy_red"`
+	Handle       string    `json:"handle,omitempty"`
+	Role         string    `json:"role,omitempty"`
 }
 
 // Chirp model with JSON tags
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	Deleted   bool       `json:"deleted,omitempty"`
+	Hidden    bool       `json:"hidden,omitempty"`
+	LikeCount int32      `json:"like_count"`
+	LikedByMe *bool      `json:"liked_by_me,omitempty"`
+	Snippet   string     `json:"snippet,omitempty"`
+}
+
+// Thread node model - a chirp along with its replies
+type ThreadNode struct {
+	Chirp
+	Replies []*ThreadNode `json:"replies"`
+}
+
+// Thread model - the chain of parents above a chirp and the replies below it
+type ChirpThread struct {
+	Ancestors []Chirp     `json:"ancestors"`
+	Chirp     *ThreadNode `json:"chirp"`
+}
+
+// Follow model with JSON tags - one side of a follow relationship
+type Follow struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// Trending tag model with JSON tags
+type TrendingTag struct {
+	Tag        string `json:"tag"`
+	ChirpCount int64  `json:"chirp_count"`
+}
+
+// Moderation word model with JSON tags
+type ModerationWord struct {
+	Word      string    `json:"word"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Action    string    `json:"action"`
+}
+
+// Moderation flag model with JSON tags - a chirp waiting for review
+type ModerationFlag struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Word      string    `json:"word"`
+	Chirp     Chirp     `json:"chirp"`
+}
+
+// Chirp revision model with JSON tags - a previous body of an edited chirp
+type ChirpRevision struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
 	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+}
+
+// Scheduled chirp model with JSON tags - a chirp waiting to be posted
+type ScheduledChirp struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	PublishAt time.Time  `json:"publish_at"`
+}
+
+// Webhook subscription model with JSON tags - where to send which events
+// The secret is only returned when the subscription is created
+type WebhookSubscription struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    *uuid.UUID `json:"user_id"`
+	URL       string     `json:"url"`
+	Events    []string   `json:"events"`
+	Secret    string     `json:"secret,omitempty"`
+}
+
+// Webhook delivery attempt model with JSON tags - an entry in a subscription's delivery log
+// response_status is null when the receiver couldn't be reached, and error says why
+type WebhookDeliveryAttempt struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	DeliveryID     uuid.UUID `json:"delivery_id"`
+	Event          string    `json:"event"`
+	DeliveryStatus string    `json:"delivery_status"`
+	ResponseStatus *int32    `json:"response_status"`
+	ResponseBody   string    `json:"response_body"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Report model with JSON tags - a user's report of an abusive chirp
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Status     string     `json:"status"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Chirp      *Chirp     `json:"chirp,omitempty"`
+}
+
+// Session model with JSON tags - one login, kept going by refreshing its token
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	SignedInAt time.Time `json:"signed_in_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
 }
 
 // **** Start of the main function ****
@@ -50,87 +197,236 @@ func main() {
 	// Load environment variables
 	godotenv.Load()
 
-	// Connect to the database
-	dbURL := os.Getenv("DB_URL")
-	db, err := sql.Open("postgres", dbURL)
-	if err != nil {
-		log.Printf("Error connecting to database: %s", err)
+	// Log structured JSON, tagged with the request ID where there is one
+	logger := slog.New(logging.NewHandler(slog.NewJSONHandler(os.Stdout, nil)))
+	slog.SetDefault(logger)
+
+	// chirpy migrate ... manages the schema, anything else runs the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(os.Args[2:]); err != nil {
+			slog.Error("Error running migrations", "error", err)
+			os.Exit(1)
+		}
+		return
 	}
-	defer db.Close()
 
-	// Initialize API configuration
-	apiCfg := &apiConfig{
-		dbQueries: database.New(db),
-		platform:  os.Getenv("PLATFORM"),
-		jwtSecret: os.Getenv("JWT_SECRET"),
-		polkaKey:  os.Getenv("POLKA_KEY"),
+	if err := run(); err != nil {
+		slog.Error("Error running server", "error", err)
+		os.Exit(1)
 	}
-	apiCfg.fileserverHits.Store(0)
+}
 
-	// Create a new HTTP server mux
-	mux := http.NewServeMux()
+// Open the database from DB_URL and check it's reachable
+func openDB(ctx context.Context, dbCfg databaseConfig) (*sql.DB, error) {
+	db, err := sql.Open(dbCfg.Driver, dbCfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(pingCtx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	return db, nil
+}
 
-	// *** General handlers ***
+// Run a chirpy migrate subcommand against DB_URL
+func migrate(args []string) error {
+	if os.Getenv("DB_URL") == "" {
+		return errors.New("missing required environment variable DB_URL")
+	}
+	dbCfg, err := loadDatabaseConfig(os.Getenv("DB_URL"))
+	if err != nil {
+		return err
+	}
 
-	// Handler to serve static files - Just to tidy up the next section :)
-	fileHandler := http.StripPrefix("/app/", http.FileServer(http.Dir(".")))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Serve static files from the current directory at /app/
-	mux.Handle("/app/", apiCfg.middlewareMetricsInc(fileHandler))
+	db, err := openDB(ctx, dbCfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-	// Readiness probe endpoint
-	mux.HandleFunc("GET /api/healthz", readyHandler)
+	provider, err := newMigrationProvider(db, dbCfg)
+	if err != nil {
+		return err
+	}
+	return runMigrateCommand(ctx, provider, args, os.Stdout)
+}
 
-	// Metrics endpoint
-	mux.HandleFunc("GET /admin/metrics", apiCfg.metricsHandler)
+// Connect to the database, check it's reachable before taking traffic,
+// and apply any pending migrations if MIGRATE_ON_START is set
+func openDatabase(ctx context.Context, dbCfg databaseConfig) (*sql.DB, error) {
+	db, err := openDB(ctx, dbCfg)
+	if err != nil {
+		return nil, err
+	}
 
-	// *** User related handlers ***
+	// Replicas starting together queue on the migration lock rather than racing
+	if os.Getenv("MIGRATE_ON_START") == "true" {
+		provider, err := newMigrationProvider(db, dbCfg)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		results, err := provider.Up(ctx)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("applying migrations: %w", err)
+		}
+		for _, result := range results {
+			slog.Info("Applied migration", "migration", result.Source.Path, "duration", result.Duration.String())
+		}
+	}
+	return db, nil
+}
 
-	// Reset users database
-	mux.HandleFunc("POST /admin/reset", apiCfg.resetUsersHandler)
+// Start the server and block until it has shut down
+func run() error {
+	// Refuse to start without the settings every request depends on
+	if err := checkRequiredEnv(os.Getenv); err != nil {
+		return err
+	}
+	serverCfg, err := loadServerConfig(os.Getenv)
+	if err != nil {
+		return err
+	}
+	polkaKeys, err := loadPolkaKeys(os.Getenv)
+	if err != nil {
+		return err
+	}
+	rateLimits, rateLimitBackend, err := loadRateLimitConfig(os.Getenv)
+	if err != nil {
+		return err
+	}
+	trustedProxies, err := loadTrustedProxies(os.Getenv)
+	if err != nil {
+		return err
+	}
 
-	// User creation endpoint
-	mux.HandleFunc("POST /api/users", apiCfg.createUserHandler)
+	// Stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// User update endpoint
-	mux.HandleFunc("PUT /api/users", apiCfg.updateUserHandler)
+	// Set up storage - the in-memory backend starts empty and is lost on exit
+	backend, err := loadStorageBackend(os.Getenv)
+	if err != nil {
+		return err
+	}
+	var db *sql.DB
+	var dataStore store.Store
+	var schemaVersion int64
+	if backend == storageMemory {
+		slog.Warn("Using in-memory storage, data will not be persisted")
+		dataStore = store.NewMemory()
+	} else {
+		dbCfg, err := loadDatabaseConfig(os.Getenv("DB_URL"))
+		if err != nil {
+			return err
+		}
+		db, err = openDatabase(ctx, dbCfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		dataStore = dbCfg.newStore(db)
+		schemaVersion, err = latestSchemaVersion(dbCfg.Migrations)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Login endpoint
-	mux.HandleFunc("POST /api/login", apiCfg.userLoginHandler)
+	// Initialize API configuration
+	apiCfg := &apiConfig{
+		server:         serverCfg,
+		schemaVersion:  schemaVersion,
+		metrics:        metrics.New(db),
+		db:             db,
+		store:          dataStore,
+		platform:       os.Getenv("PLATFORM"),
+		jwtSecret:      os.Getenv("JWT_SECRET"),
+		polkaKeys:      polkaKeys,
+		webhookClient:  newWebhookClient(false),
+		trustedProxies: trustedProxies,
+	}
 
-	// *** Chirp related handlers ***
+	// Create or promote the first admin user
+	if adminEmail := os.Getenv("ADMIN_EMAIL"); adminEmail != "" {
+		if err := apiCfg.bootstrapAdmin(ctx, adminEmail, os.Getenv("ADMIN_PASSWORD")); err != nil {
+			return fmt.Errorf("bootstrapping admin user: %w", err)
+		}
+	}
 
-	// Chirp creation endpoint
-	mux.HandleFunc("POST /api/chirps", apiCfg.chirpHandler)
+	// Load the moderation word list
+	if wordsFile := os.Getenv("MODERATION_WORDS_FILE"); wordsFile != "" {
+		apiCfg.moderationFileRules, err = moderation.LoadRulesFile(wordsFile)
+		if err != nil {
+			return fmt.Errorf("loading moderation words: %w", err)
+		}
+	}
+	apiCfg.moderation = moderation.NewEngine(apiCfg.moderationFileRules)
+	if err := apiCfg.reloadModerationRules(ctx); err != nil {
+		slog.Error("Error loading moderation words from database", "error", err)
+	}
+	go apiCfg.watchModerationRules(ctx, moderationReloadInterval)
 
-	// Return all chirps endpoint
-	mux.HandleFunc("GET /api/chirps", apiCfg.getAllChirpsHandler)
+	// Expire subscriptions that lapsed while the server was down, then keep checking
+	if err := apiCfg.sweepSubscriptions(ctx); err != nil {
+		slog.Error("Error expiring lapsed subscriptions", "error", err)
+	}
+	go apiCfg.watchSubscriptions(ctx, subscriptionSweepInterval)
 
-	// Return specfic chirp endpoint
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.getChirpByIDHandler)
+	// Post scheduled chirps as they fall due
+	go apiCfg.watchScheduledChirps(ctx, scheduledChirpsInterval)
 
-	// Delete chirp endpoint
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirpByIDHandler)
+	// Send outbound webhooks as they're queued, retrying failed ones
+	go apiCfg.watchWebhookDeliveries(ctx, webhookDeliveryInterval)
 
-	// *** Token related handlers ***
+	// Load the revoked access tokens
+	apiCfg.denylist = auth.NewDenylist()
+	if err := apiCfg.reloadDenylist(ctx); err != nil {
+		slog.Error("Error loading denylist from database", "error", err)
+	}
+	go apiCfg.watchDenylist(ctx, denylistReloadInterval)
 
-	// Token refresh endpoint
-	mux.HandleFunc("POST /api/refresh", apiCfg.tokenRefreshHandler)
+	// Limit request rates, sharing the buckets through Postgres when there are several replicas
+	apiCfg.rateLimits = rateLimits
+	if rateLimitBackend == rateLimitPostgres {
+		apiCfg.rateLimiter = ratelimit.NewPostgres(db)
+	} else {
+		apiCfg.rateLimiter = ratelimit.NewMemory()
+	}
+	go apiCfg.watchRateLimits(ctx, rateLimitPruneInterval)
 
-	// Revoke refresh token endpoint
-	mux.HandleFunc("POST /api/revoke", apiCfg.revokeRefreshTokenHandler)
+	// *** Start the server ***
+	chirpyServer := newServer(apiCfg)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server listening", "addr", chirpyServer.Addr)
+		serverErr <- chirpyServer.ListenAndServe()
+	}()
 
-	// *** Webhook related handlers ***
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
 
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.webhookHandler)
+	// Report unready first, and give load balancers time to notice before closing the listener
+	apiCfg.shuttingDown.Store(true)
+	slog.Info("Draining before shutdown", "delay", serverCfg.DrainDelay.String())
+	time.Sleep(serverCfg.DrainDelay)
 
-	// *** Start the server ***
-	chirpyServer := http.Server{
-		Addr:    ":8080",
-		Handler: mux,
-	}
-	err = chirpyServer.ListenAndServe()
-	if err != nil {
-		panic(err)
+	// Stop accepting connections and let in-flight requests finish
+	// The deferred db.Close runs once they have
+	slog.Info("Shutting down", "timeout", serverCfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverCfg.ShutdownTimeout)
+	defer cancel()
+	if err := chirpyServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down server: %w", err)
 	}
+	return nil
 }
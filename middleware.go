@@ -1,11 +1,115 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/entitlements"
+	"github.com/frogonabike/chirpy/internal/ratelimit"
+	"github.com/google/uuid"
+)
 
 // Middleware to increment file server hit counter
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
+		cfg.metrics.FileserverHits.Inc()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware to restrict endpoints to users with at least the given role
+// The role comes from the JWT - a promotion applies once the user gets a new token,
+// and a demotion straight away, since it revokes their sessions
+func (cfg *apiConfig) middlewareRequireRole(min auth.Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, role, err := cfg.authenticateWithRole(r)
+		if err != nil {
+			respondWithAuthError(w, err)
+			return
+		}
+		if !role.AtLeast(min) {
+			respondWithError(w, 403, "Insufficient role")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware to limit request rates on the routes that have a rate limit rule
+// Signed-in users get a bucket per user, scaled up by their plan, and everyone else one per client IP
+// The route is matched against mux before the handler runs, so rules are keyed by the registered pattern
+func (cfg *apiConfig) middlewareRateLimit(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		limit, ok := cfg.rateLimits[pattern]
+		if cfg.rateLimiter == nil || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Only the JWT is checked here - the handler still rejects suspended users
+		key := pattern + " ip:" + cfg.clientIP(r)
+		if token, err := auth.GetBearerToken(r.Header); err == nil {
+			if claims, err := auth.ValidateJWTClaims(token, cfg.jwtSecret, cfg.denylist); err == nil {
+				if userID, err := uuid.Parse(claims.Subject); err == nil {
+					key = pattern + " user:" + userID.String()
+					plan, err := cfg.userPlan(r.Context(), userID)
+					if err != nil {
+						plan = entitlements.Free
+					}
+					limit = limit.Scale(plan.RateLimitMultiplier)
+				}
+			}
+		}
+
+		// Let requests through if the limiter is down rather than failing every request
+		res, err := cfg.rateLimiter.Take(r.Context(), key, limit)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking rate limit", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		ratelimit.SetHeaders(w.Header(), res)
+		if !res.Allowed {
+			// Label the rejected request with its route for the metrics and access log
+			r.Pattern = pattern
+			respondWithError(w, 429, "Too many requests")
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// How often idle rate limit buckets are forgotten
+const rateLimitPruneInterval = 10 * time.Minute
+
+// Forget rate limit buckets that have refilled, so they don't pile up
+func (cfg *apiConfig) pruneRateLimits(ctx context.Context) error {
+	pruned, err := cfg.rateLimiter.Prune(ctx, cfg.rateLimits.Longest())
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		slog.InfoContext(ctx, "Pruned idle rate limit buckets", "count", pruned)
+	}
+	return nil
+}
+
+// Prune idle rate limit buckets every interval until ctx is cancelled
+func (cfg *apiConfig) watchRateLimits(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.pruneRateLimits(ctx); err != nil {
+				slog.ErrorContext(ctx, "Error pruning rate limit buckets", "error", err)
+			}
+		}
+	}
+}
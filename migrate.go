@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Usage for the migrate subcommand
+const migrateUsage = "usage: chirpy migrate up|down|status|redo"
+
+// Create a goose provider for the database's embedded migrations
+// Postgres migrations run under an advisory lock, so replicas starting together take turns
+// SQLite is single-node, and its migrations take the write lock anyway
+func newMigrationProvider(db *sql.DB, dbCfg databaseConfig) (*goose.Provider, error) {
+	if dbCfg.Dialect != goose.DialectPostgres {
+		return goose.NewProvider(dbCfg.Dialect, db, dbCfg.Migrations)
+	}
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(dbCfg.Dialect, db, dbCfg.Migrations, goose.WithSessionLocker(locker))
+}
+
+// Latest migration version embedded in the binary
+func latestSchemaVersion(migrations fs.FS) (int64, error) {
+	files, err := fs.Glob(migrations, "*.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest int64
+	for _, file := range files {
+		version, err := goose.NumericComponent(file)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s: %w", file, err)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+// Run a migrate subcommand and print what it did
+func runMigrateCommand(ctx context.Context, provider *goose.Provider, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	var results []*goose.MigrationResult
+	switch args[0] {
+	case "up":
+		applied, err := provider.Up(ctx)
+		if err != nil {
+			return err
+		}
+		results = applied
+	case "down":
+		result, err := provider.Down(ctx)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	case "redo":
+		result, err := provider.Down(ctx)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+		result, err = provider.UpByOne(ctx)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "-"
+			if status.State == goose.StateApplied {
+				appliedAt = status.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%-8s %-20s %s\n", status.State, appliedAt, status.Source.Path)
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(out, "no migrations to run")
+	}
+	for _, result := range results {
+		fmt.Fprintf(out, "%-4s %s (%s)\n", result.Direction, result.Source.Path, result.Duration)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/frogonabike/chirpy/sql/schema"
+	sqliteschema "github.com/frogonabike/chirpy/sql/sqlite/schema"
+)
+
+func TestLatestSchemaVersion(t *testing.T) {
+	for name, migrations := range map[string]fs.FS{"postgres": schema.FS, "sqlite": sqliteschema.FS} {
+		files, err := fs.Glob(migrations, "*.sql")
+		if err != nil {
+			t.Fatalf("Error listing %s migrations: %s", name, err)
+		}
+
+		// Migrations are numbered from 1 without gaps
+		latest, err := latestSchemaVersion(migrations)
+		if err != nil {
+			t.Fatalf("Error reading latest %s schema version: %s", name, err)
+		}
+		if latest != int64(len(files)) {
+			t.Errorf("Expected latest %s version %d, got %d", name, len(files), latest)
+		}
+	}
+}
+
+func TestRunMigrateCommandUsage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"sideways"},
+		{"up", "extra"},
+	}
+
+	for _, args := range tests {
+		err := runMigrateCommand(t.Context(), nil, args, io.Discard)
+		if err == nil || err.Error() != migrateUsage {
+			t.Errorf("Expected usage error for %v, got %v", args, err)
+		}
+	}
+}
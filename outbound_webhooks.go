@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/frogonabike/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// Events third parties can subscribe to
+const (
+	webhookEventChirpCreated = "chirp.created"
+	webhookEventChirpDeleted = "chirp.deleted"
+	webhookEventUserUpgraded = "user.upgraded"
+)
+
+var webhookEvents = []string{webhookEventChirpCreated, webhookEventChirpDeleted, webhookEventUserUpgraded}
+
+// Delivery statuses, matching the CHECK constraint on webhook_deliveries.status
+const (
+	webhookDeliveryPending   = "pending"
+	webhookDeliveryDelivered = "delivered"
+	webhookDeliveryFailed    = "failed"
+)
+
+// How often queued webhooks are sent, and how many at a time
+const (
+	webhookDeliveryInterval  = 10 * time.Second
+	webhookDeliveryBatchSize = 50
+)
+
+// How long a receiver has to respond
+const webhookDeliveryTimeout = 10 * time.Second
+
+// How long a claimed delivery is left alone, so other instances don't send it too
+// It must be longer than webhookDeliveryTimeout
+const webhookDeliveryLease = time.Minute
+
+// Failed deliveries are retried after 30s, 1m, 2m and so on, up to 6h apart, and given up on after 10 attempts
+const (
+	webhookRetryBase   = 30 * time.Second
+	webhookRetryMax    = 6 * time.Hour
+	webhookMaxAttempts = 10
+)
+
+// How long finished deliveries are kept for the delivery log, and how often older ones are pruned
+const (
+	webhookDeliveryRetention = 7 * 24 * time.Hour
+	webhookPruneInterval     = time.Hour
+)
+
+// How much of a receiver's response is kept in the delivery log
+const maxWebhookResponseBytes = 1 << 10
+
+// Headers outbound webhooks are sent with - signed like Polka's, see auth.SignWebhook
+const (
+	chirpyEventHeader     = "X-Chirpy-Event"
+	chirpyDeliveryHeader  = "X-Chirpy-Delivery"
+	chirpyTimestampHeader = "X-Chirpy-Timestamp"
+	chirpySignatureHeader = "X-Chirpy-Signature"
+)
+
+// Body of an outbound webhook
+// The ID is the same for every subscription sent the event, so receivers can ignore redeliveries
+type webhookPayload struct {
+	ID        uuid.UUID `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Data sent with chirp.deleted webhooks
+type chirpDeletedData struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// Data sent with user.upgraded webhooks
+type userUpgradedData struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// Queue an event about a user for the subscriptions that want it
+// Meant to run in the transaction that makes the change, so the event is sent if and only if it commits
+func queueWebhookEvent(ctx context.Context, q store.Queries, event string, userID uuid.UUID, data any) error {
+	payload, err := json.Marshal(webhookPayload{
+		ID:        uuid.New(),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		Payload: string(payload),
+		Event:   event,
+		UserID:  userID,
+	})
+	return err
+}
+
+// Delay before retrying a delivery that has failed a number of times
+func webhookRetryDelay(attempts int32) time.Duration {
+	delay := webhookRetryBase
+	for range attempts - 1 {
+		delay *= 2
+		if delay >= webhookRetryMax {
+			return webhookRetryMax
+		}
+	}
+	return delay
+}
+
+// Send the queued webhooks due by a time, returning how many were attempted
+// Each one is sent concurrently, and failures are retried later with exponential backoff
+func (cfg *apiConfig) deliverWebhooks(ctx context.Context, dueBy time.Time) (int, error) {
+	deliveries, err := cfg.store.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil: dueBy.Add(webhookDeliveryLease),
+		DueBy:      dueBy,
+		PageLimit:  webhookDeliveryBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, delivery := range deliveries {
+		wg.Go(func() {
+			if err := cfg.deliverWebhook(ctx, delivery); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return len(deliveries), errors.Join(errs...)
+}
+
+// Send one delivery and record the attempt
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, delivery database.WebhookDelivery) error {
+	sub, err := cfg.store.GetWebhookSubscription(ctx, delivery.SubscriptionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted since the delivery was claimed, which deleted the delivery too
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	status, body, sendErr := cfg.sendWebhook(ctx, sub, delivery)
+
+	attempt := database.RecordWebhookDeliveryAttemptParams{
+		DeliveryID:   delivery.ID,
+		ResponseBody: webhookLogText(body),
+	}
+	if status != 0 {
+		attempt.ResponseStatus = sql.NullInt32{Int32: int32(status), Valid: true}
+	}
+	if sendErr != nil {
+		attempt.Error = sql.NullString{String: webhookLogText([]byte(sendErr.Error())), Valid: true}
+	}
+
+	update := database.UpdateWebhookDeliveryParams{
+		ID:            delivery.ID,
+		Status:        webhookDeliveryDelivered,
+		NextAttemptAt: time.Now(),
+	}
+	switch {
+	case sendErr == nil:
+	case delivery.Attempts+1 >= webhookMaxAttempts:
+		update.Status = webhookDeliveryFailed
+	default:
+		update.Status = webhookDeliveryPending
+		update.NextAttemptAt = time.Now().Add(webhookRetryDelay(delivery.Attempts + 1))
+	}
+
+	// Count the attempt before logging it, so a log entry that can't be saved doesn't get the delivery resent forever
+	if err := cfg.store.UpdateWebhookDelivery(ctx, update); err != nil {
+		return err
+	}
+	result := update.Status
+	if result == webhookDeliveryPending {
+		result = "retrying"
+	}
+	cfg.metrics.WebhookDeliveries.WithLabelValues(delivery.Event, result).Inc()
+
+	return cfg.store.RecordWebhookDeliveryAttempt(ctx, attempt)
+}
+
+// Make a receiver's response, or an error, safe to store as text in the delivery log
+// Invalid UTF-8 and NUL bytes are replaced, and the text is cut to maxWebhookResponseBytes on a character boundary
+func webhookLogText(b []byte) string {
+	text := strings.ToValidUTF8(string(b), "\uFFFD")
+	text = strings.ReplaceAll(text, "\x00", "\uFFFD")
+	if len(text) <= maxWebhookResponseBytes {
+		return text
+	}
+	cut := maxWebhookResponseBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
+
+// POST a delivery's payload to its subscription, signed with the subscription's secret
+// Returns the response status, or 0 if there was no response, the start of the response body,
+// and an error unless the receiver responded with a 2xx status
+func (cfg *apiConfig) sendWebhook(ctx context.Context, sub database.WebhookSubscription, delivery database.WebhookDelivery) (int, []byte, error) {
+	payload := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.Url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(chirpyEventHeader, delivery.Event)
+	req.Header.Set(chirpyDeliveryHeader, delivery.ID.String())
+	req.Header.Set(chirpyTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(chirpySignatureHeader, auth.SignWebhook(sub.Secret, timestamp, payload))
+
+	res, err := cfg.webhookClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxWebhookResponseBytes))
+	if err != nil {
+		return res.StatusCode, body, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, body, fmt.Errorf("receiver responded with status %d", res.StatusCode)
+	}
+	return res.StatusCode, body, nil
+}
+
+// Error for webhook URLs that point into our own network
+var errWebhookAddress = errors.New("url must not point at a loopback, link-local, private, shared or reserved address")
+
+// Special-purpose ranges netip has no method for, which can still lead into a provider's network
+var reservedWebhookPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT, where some clouds put their metadata service
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, which embeds any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+}
+
+// Whether webhooks may be sent to an address
+// Internal addresses are refused, so subscribers can't use webhooks to reach the cloud metadata service or our own network
+func publicWebhookAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedWebhookPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Create the client webhooks are sent with
+// The address is checked after DNS resolution, when connecting, so a host can't be rebound to an internal address
+// after it was registered, and redirects aren't followed. allowPrivate turns the check off, for tests with local receivers
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookDeliveryTimeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowPrivate && !publicWebhookAddr(addrPort.Addr()) {
+				return errWebhookAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   webhookDeliveryTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Periodically send queued webhooks, and prune old deliveries, until the context is cancelled
+func (cfg *apiConfig) watchWebhookDeliveries(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(webhookPruneInterval)
+	defer pruneTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := cfg.deliverWebhooks(ctx, time.Now()); err != nil {
+				slog.ErrorContext(ctx, "Error sending webhooks", "error", err)
+			}
+		case <-pruneTicker.C:
+			pruned, err := cfg.store.DeleteOldWebhookDeliveries(ctx, time.Now().Add(-webhookDeliveryRetention))
+			if err != nil {
+				slog.ErrorContext(ctx, "Error pruning webhook deliveries", "error", err)
+			} else if pruned > 0 {
+				slog.InfoContext(ctx, "Pruned old webhook deliveries", "count", pruned)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPublicWebhookAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"100.100.100.200", false},
+		{"100.64.0.1", false},
+		{"100.128.0.1", true},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"198.20.0.1", true},
+		{"0.1.2.3", false},
+		{"255.255.255.255", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"::ffff:100.100.100.200", false},
+	}
+	for _, tc := range tests {
+		if got := publicWebhookAddr(netip.MustParseAddr(tc.addr)); got != tc.want {
+			t.Errorf("Expected %s public to be %v, got %v", tc.addr, tc.want, got)
+		}
+	}
+}
+
+func TestWebhookClient(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/internal", http.StatusFound)
+			return
+		}
+		w.WriteHeader(204)
+	}))
+	defer receiver.Close()
+
+	// The receiver listens on loopback, so it's refused unless private addresses are allowed
+	_, err := newWebhookClient(false).Post(receiver.URL, "application/json", nil)
+	if !errors.Is(err, errWebhookAddress) {
+		t.Errorf("Expected a loopback receiver to be refused, got %v", err)
+	}
+	res, err := newWebhookClient(true).Post(receiver.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Error sending to an allowed receiver: %s", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 204 {
+		t.Errorf("Expected status 204, got %d", res.StatusCode)
+	}
+
+	// Redirects aren't followed
+	res, err = newWebhookClient(true).Post(receiver.URL+"/redirect", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Error sending to a redirecting receiver: %s", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Errorf("Expected the redirect to be returned, got status %d", res.StatusCode)
+	}
+}
+
+func TestWebhookLogText(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{"text", []byte("try later"), "try later"},
+		{"nul", []byte("a\x00b"), "a�b"},
+		{"binary", []byte{0xff, 0xfe, 'o', 'k'}, "�ok"},
+		{"cut mid-character", []byte("é")[:1], "�"},
+	}
+	for _, tc := range tests {
+		if got := webhookLogText(tc.body); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+
+	// Replacements can make the text longer than it was read, so it's cut again without splitting a character
+	long := webhookLogText(bytes.Repeat([]byte{0}, maxWebhookResponseBytes))
+	if len(long) > maxWebhookResponseBytes || !utf8.ValidString(long) {
+		t.Errorf("Expected valid text of at most %d bytes, got %d bytes", maxWebhookResponseBytes, len(long))
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Page size limits for paginated list endpoints
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// Limit for list endpoints that return everything unless a page is asked for
+// One less than the largest int32, so fetching an extra row to look for a next page can't overflow
+const unpaginatedLimit = math.MaxInt32 - 1
+
+// pageCursor marks the last row of a page. It is handed to clients as an
+// opaque base64 string, so fields can be added without breaking them.
+type pageCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	LikeCount int32     `json:"l,omitempty"`
+	Rank      float64   `json:"r,omitempty"`
+}
+
+// pageParams holds the parsed "limit" and "cursor" query parameters
+type pageParams struct {
+	Limit  int32
+	Cursor *pageCursor
+}
+
+// Encode the cursor into the opaque string returned to clients
+func (c pageCursor) encode() string {
+	dat, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(dat)
+}
+
+// Decode a cursor previously produced by encode
+func decodePageCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(dat, &c); err != nil || c.ID == uuid.Nil {
+		return c, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// Parse the "limit" and "cursor" query parameters of a list request
+func parsePageParams(r *http.Request) (pageParams, error) {
+	params := pageParams{Limit: defaultPageLimit}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return params, errors.New("limit must be a positive integer")
+		}
+		params.Limit = int32(min(n, maxPageLimit))
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		c, err := decodePageCursor(cursor)
+		if err != nil {
+			return params, err
+		}
+		params.Cursor = &c
+	}
+	return params, nil
+}
+
+// Whether the request asked for a page with "limit" or "cursor"
+func paginated(r *http.Request) bool {
+	query := r.URL.Query()
+	return query.Has("limit") || query.Has("cursor")
+}
+
+// Cursor position as nullable query arguments, NULL meaning the first page
+func (p pageParams) cursorArgs() (sql.NullTime, uuid.NullUUID) {
+	if p.Cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: p.Cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: p.Cursor.ID, Valid: true}
+}
+
+// Set a Link header pointing at the next page, keeping the other query parameters
+func setNextPageLink(w http.ResponseWriter, r *http.Request, next pageCursor) {
+	query := r.URL.Query()
+	query.Set("cursor", next.encode())
+	w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.Path, query.Encode()))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	cursor := pageCursor{
+		CreatedAt: time.Date(2025, 3, 14, 15, 9, 26, 535897000, time.UTC),
+		ID:        uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"),
+	}
+
+	decoded, err := decodePageCursor(cursor.encode())
+	if err != nil {
+		t.Fatalf("Error decoding cursor: %s", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Errorf("Expected cursor %+v, got %+v", cursor, decoded)
+	}
+}
+
+func TestParsePageParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int32
+		wantErr   bool
+	}{
+		{"defaults", "", defaultPageLimit, false},
+		{"explicit limit", "?limit=20", 20, false},
+		{"limit capped", "?limit=100000", maxPageLimit, false},
+		{"zero limit", "?limit=0", 0, true},
+		{"non numeric limit", "?limit=ten", 0, true},
+		{"garbage cursor", "?cursor=not-a-cursor", 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/chirps"+tc.query, nil)
+			params, err := parsePageParams(r)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parsePageParams error mismatch: got err=%v wantErr=%v", err, tc.wantErr)
+			}
+			if !tc.wantErr && params.Limit != tc.wantLimit {
+				t.Errorf("Expected limit %d, got %d", tc.wantLimit, params.Limit)
+			}
+		})
+	}
+}
+
+func TestPaginated(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", false},
+		{"?sort=desc", false},
+		{"?limit=20", true},
+		{"?cursor=abc", true},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/api/chirps"+tc.query, nil)
+		if got := paginated(r); got != tc.want {
+			t.Errorf("Expected paginated %v for %q, got %v", tc.want, tc.query, got)
+		}
+	}
+}
@@ -0,0 +1,854 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/frogonabike/chirpy/internal/metrics"
+	"github.com/frogonabike/chirpy/internal/moderation"
+	"github.com/frogonabike/chirpy/internal/ratelimit"
+	"github.com/frogonabike/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// A storage backend the handler tests run against
+type testBackend struct {
+	name string
+	open func(t *testing.T) (store.Store, *sql.DB, int64) // the store, its database if any, and schema version
+}
+
+// The in-memory and SQLite backends always run, and Postgres runs when TEST_DB_URL points at a scratch database
+func testBackends() []testBackend {
+	backends := []testBackend{
+		{name: "memory", open: func(t *testing.T) (store.Store, *sql.DB, int64) {
+			return store.NewMemory(), nil, 0
+		}},
+		{name: "sqlite", open: func(t *testing.T) (store.Store, *sql.DB, int64) {
+			return openTestDatabase(t, "sqlite:"+filepath.Join(t.TempDir(), "chirpy.db"))
+		}},
+	}
+	if dbURL := os.Getenv("TEST_DB_URL"); dbURL != "" {
+		backends = append(backends, testBackend{name: "postgres", open: func(t *testing.T) (store.Store, *sql.DB, int64) {
+			return openTestDatabase(t, dbURL)
+		}})
+	}
+	return backends
+}
+
+// Open and migrate a database, and empty it of anything a previous run left behind
+func openTestDatabase(t *testing.T, dbURL string) (store.Store, *sql.DB, int64) {
+	t.Helper()
+	dbCfg, err := loadDatabaseConfig(dbURL)
+	if err != nil {
+		t.Fatalf("Error parsing database URL: %s", err)
+	}
+	db, err := openDB(t.Context(), dbCfg)
+	if err != nil {
+		t.Fatalf("Error opening database: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	provider, err := newMigrationProvider(db, dbCfg)
+	if err != nil {
+		t.Fatalf("Error creating migration provider: %s", err)
+	}
+	if _, err := provider.Up(t.Context()); err != nil {
+		t.Fatalf("Error applying migrations: %s", err)
+	}
+	schemaVersion, err := latestSchemaVersion(dbCfg.Migrations)
+	if err != nil {
+		t.Fatalf("Error reading schema version: %s", err)
+	}
+
+	dataStore := dbCfg.newStore(db)
+	if err := dataStore.ResetUsers(t.Context()); err != nil {
+		t.Fatalf("Error resetting users: %s", err)
+	}
+	return dataStore, db, schemaVersion
+}
+
+// A test server backed by one of the test backends
+type testServer struct {
+	t       *testing.T
+	cfg     *apiConfig
+	handler http.Handler
+}
+
+func newTestServer(t *testing.T, backend testBackend) *testServer {
+	t.Helper()
+	serverCfg, err := loadServerConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatalf("Error loading server config: %s", err)
+	}
+	dataStore, db, schemaVersion := backend.open(t)
+	cfg := &apiConfig{
+		server:        serverCfg,
+		schemaVersion: schemaVersion,
+		metrics:       metrics.New(db),
+		db:            db,
+		store:         dataStore,
+		platform:      "dev",
+		jwtSecret:     "test-secret",
+		polkaKeys:     []string{"test-polka-key", "old-polka-key"},
+		moderation:    moderation.NewEngine(nil),
+		denylist:      auth.NewDenylist(),
+		webhookClient: newWebhookClient(false),
+	}
+	if err := cfg.reloadModerationRules(context.Background()); err != nil {
+		t.Fatalf("Error loading moderation rules: %s", err)
+	}
+	if err := cfg.bootstrapAdmin(context.Background(), "admin@example.com", "admin-password"); err != nil {
+		t.Fatalf("Error bootstrapping admin: %s", err)
+	}
+	return &testServer{t: t, cfg: cfg, handler: newServer(cfg).Handler}
+}
+
+// Send a request, failing the test unless it gets the expected status
+// A JSON response is decoded into out when it's not nil
+func (s *testServer) do(method, path, authorization string, body any, wantStatus int, out any) {
+	s.t.Helper()
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			s.t.Fatalf("Error encoding request body: %s", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &reqBody)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+
+	if rec.Code != wantStatus {
+		s.t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, wantStatus, rec.Code, rec.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("%s %s: error decoding response: %s", method, path, err)
+		}
+	}
+}
+
+// Create a user and log in, returning the logged in user
+func (s *testServer) signUp(email, handle string) User {
+	s.t.Helper()
+	s.do("POST", "/api/users", "", map[string]string{"email": email, "password": "password", "handle": handle}, 201, nil)
+	return s.login(email, "password")
+}
+
+func (s *testServer) login(email, password string) User {
+	s.t.Helper()
+	user := User{}
+	s.do("POST", "/api/login", "", map[string]string{"email": email, "password": password}, 200, &user)
+	return user
+}
+
+// Send a Polka webhook signed with key, failing the test unless it gets the expected status
+func (s *testServer) polkaWebhook(key string, body any, wantStatus int) {
+	s.t.Helper()
+	payload, err := json.Marshal(body)
+	if err != nil {
+		s.t.Fatalf("Error encoding webhook body: %s", err)
+	}
+	timestamp := time.Now().Unix()
+	req := httptest.NewRequest("POST", "/api/polka/webhooks", bytes.NewReader(payload))
+	req.Header.Set(polkaTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(polkaSignatureHeader, auth.SignWebhook(key, timestamp, payload))
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+
+	if rec.Code != wantStatus {
+		s.t.Fatalf("POST /api/polka/webhooks: expected status %d, got %d: %s", wantStatus, rec.Code, rec.Body.String())
+	}
+}
+
+func bearer(token string) string {
+	return "Bearer " + token
+}
+
+func TestRoutes(t *testing.T) {
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testRoutes(t, newTestServer(t, backend))
+		})
+	}
+}
+
+// Exercise every route, checking each backend gives the same responses
+func testRoutes(t *testing.T, s *testServer) {
+
+	// Probes and metrics
+	s.do("GET", "/api/healthz", "", nil, 200, nil)
+	s.do("GET", "/api/livez", "", nil, 200, nil)
+	s.do("GET", "/api/readyz", "", nil, 200, nil)
+	s.do("GET", "/metrics", "", nil, 200, nil)
+	s.do("GET", "/app/", "", nil, 200, nil)
+
+	// Users
+	admin := s.login("admin@example.com", "admin-password")
+	alice := s.signUp("alice@example.com", "alice")
+	bob := s.signUp("bob@example.com", "bob")
+	mod := s.signUp("mod@example.com", "mod")
+	s.do("POST", "/api/users", "", map[string]string{"email": "alice@example.com", "password": "password"}, 400, nil)
+	s.do("POST", "/api/login", "", map[string]string{"email": "alice@example.com", "password": "wrong"}, 401, nil)
+
+	updated := User{}
+	s.do("PUT", "/api/users", bearer(bob.Token), map[string]string{"email": "bob@example.com", "new_password": "password2", "handle": "bobby"}, 200, &updated)
+	if updated.Handle != "bobby" {
+		t.Errorf("Expected handle bobby, got %q", updated.Handle)
+	}
+	bob = s.login("bob@example.com", "password2")
+
+	// Roles
+	s.do("PUT", "/admin/users/"+mod.ID.String()+"/role", bearer(alice.Token), map[string]string{"role": "moderator"}, 403, nil)
+	s.do("PUT", "/admin/users/"+mod.ID.String()+"/role", bearer(admin.Token), map[string]string{"role": "moderator"}, 204, nil)
+	mod = s.login("mod@example.com", "password")
+	s.do("GET", "/admin/metrics", bearer(mod.Token), nil, 403, nil)
+	s.do("GET", "/admin/metrics", bearer(admin.Token), nil, 200, nil)
+
+	// Demoting a user revokes their sessions, so tokens with the old role stop working straight away
+	s.do("PUT", "/admin/users/"+alice.ID.String()+"/role", bearer(admin.Token), map[string]string{"role": "moderator"}, 204, nil)
+	alice = s.login("alice@example.com", "password")
+	s.do("GET", "/admin/reports", bearer(alice.Token), nil, 200, nil)
+	s.do("PUT", "/admin/users/"+alice.ID.String()+"/role", bearer(admin.Token), map[string]string{"role": "user"}, 204, nil)
+	s.do("GET", "/admin/reports", bearer(alice.Token), nil, 401, nil)
+	s.do("POST", "/api/refresh", bearer(alice.RefreshToken), nil, 401, nil)
+	s.do("PUT", "/admin/users/"+uuid.NewString()+"/role", bearer(admin.Token), map[string]string{"role": "user"}, 404, nil)
+	alice = s.login("alice@example.com", "password")
+
+	// Refresh tokens rotate, and reusing a replaced one revokes the whole family
+	refreshed := struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}{}
+	s.do("POST", "/api/refresh", bearer(alice.RefreshToken), nil, 200, &refreshed)
+	if refreshed.Token == "" || refreshed.RefreshToken == "" || refreshed.RefreshToken == alice.RefreshToken {
+		t.Errorf("Expected a new access token and refresh token from /api/refresh, got %+v", refreshed)
+	}
+	s.do("POST", "/api/refresh", bearer(alice.RefreshToken), nil, 401, nil)
+	s.do("POST", "/api/refresh", bearer(refreshed.RefreshToken), nil, 401, nil)
+
+	alice = s.login("alice@example.com", "password")
+	s.do("POST", "/api/revoke", bearer(alice.RefreshToken), nil, 204, nil)
+	s.do("POST", "/api/refresh", bearer(alice.RefreshToken), nil, 401, nil)
+	s.do("GET", "/api/users/me/sessions", bearer(alice.Token), nil, 401, nil)
+	alice = s.login("alice@example.com", "password")
+
+	// Sessions
+	laptop := s.signUp("carol@example.com", "carol")
+	phone := s.login("carol@example.com", "password")
+	sessions := []Session{}
+	s.do("GET", "/api/users/me/sessions", bearer(laptop.Token), nil, 200, &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %+v", sessions)
+	}
+	var phoneSession Session
+	for _, session := range sessions {
+		if session.Current {
+			continue
+		}
+		phoneSession = session
+	}
+	if phoneSession.ID == uuid.Nil || phoneSession.IP != "192.0.2.1" {
+		t.Errorf("Expected one other session from 192.0.2.1, got %+v", sessions)
+	}
+	s.do("DELETE", "/api/users/me/sessions/"+phoneSession.ID.String(), bearer(laptop.Token), nil, 204, nil)
+	s.do("DELETE", "/api/users/me/sessions/"+phoneSession.ID.String(), bearer(laptop.Token), nil, 404, nil)
+	s.do("POST", "/api/refresh", bearer(phone.RefreshToken), nil, 401, nil)
+	s.do("GET", "/api/users/me/sessions", bearer(phone.Token), nil, 401, nil)
+
+	// Changing the password signs out every other session
+	phone = s.login("carol@example.com", "password")
+	s.do("PUT", "/api/users", bearer(laptop.Token), map[string]string{"email": "carol@example.com", "new_password": "password"}, 200, nil)
+	s.do("POST", "/api/refresh", bearer(phone.RefreshToken), nil, 401, nil)
+	s.do("GET", "/api/users/me/sessions", bearer(phone.Token), nil, 401, nil)
+	s.do("POST", "/api/refresh", bearer(laptop.RefreshToken), nil, 200, &refreshed)
+	s.do("POST", "/api/logout-all", bearer(laptop.Token), nil, 204, nil)
+	s.do("POST", "/api/refresh", bearer(refreshed.RefreshToken), nil, 401, nil)
+	s.do("GET", "/api/users/me/sessions", bearer(laptop.Token), nil, 401, nil)
+	s.do("GET", "/api/users/me/sessions", bearer(refreshed.Token), nil, 401, nil)
+	laptop = s.login("carol@example.com", "password")
+	s.do("GET", "/api/users/me/sessions", bearer(laptop.Token), nil, 200, &sessions)
+	if len(sessions) != 1 {
+		t.Errorf("Expected only the new session after logging out everywhere, got %+v", sessions)
+	}
+
+	// Other instances deny the logged out session's access tokens once they reload
+	s.do("POST", "/api/revoke", bearer(laptop.RefreshToken), nil, 204, nil)
+	s.cfg.denylist = auth.NewDenylist() // as on a newly started instance
+	s.do("GET", "/api/users/me/sessions", bearer(laptop.Token), nil, 200, nil)
+	if err := s.cfg.reloadDenylist(context.Background()); err != nil {
+		t.Fatalf("Error reloading denylist: %s", err)
+	}
+	s.do("GET", "/api/users/me/sessions", bearer(laptop.Token), nil, 401, nil)
+
+	// Follows
+	s.do("POST", "/api/users/"+alice.ID.String()+"/follow", bearer(bob.Token), nil, 204, nil)
+	s.do("POST", "/api/users/"+uuid.NewString()+"/follow", bearer(bob.Token), nil, 404, nil)
+	follows := []Follow{}
+	s.do("GET", "/api/users/"+alice.ID.String()+"/followers", "", nil, 200, &follows)
+	if len(follows) != 1 || follows[0].UserID != bob.ID {
+		t.Errorf("Expected bob to follow alice, got %+v", follows)
+	}
+	s.do("GET", "/api/users/"+bob.ID.String()+"/following", "", nil, 200, &follows)
+	if len(follows) != 1 || follows[0].UserID != alice.ID {
+		t.Errorf("Expected bob to be following alice, got %+v", follows)
+	}
+
+	// Chirps, replies, tags and mentions
+	chirp := Chirp{}
+	s.do("POST", "/api/chirps", bearer(alice.Token), map[string]string{"body": "Hello #golang world, says @bobby"}, 201, &chirp)
+	reply := Chirp{}
+	s.do("POST", "/api/chirps", bearer(bob.Token), map[string]any{"body": "Hi back", "in_reply_to": chirp.ID}, 201, &reply)
+	s.do("POST", "/api/chirps", "", map[string]string{"body": "No token"}, 401, nil)
+
+	chirps := []Chirp{}
+	s.do("GET", "/api/chirps", "", nil, 200, &chirps)
+	if len(chirps) != 2 {
+		t.Errorf("Expected 2 chirps, got %d", len(chirps))
+	}
+	s.do("GET", "/api/chirps?sort=desc&limit=1", "", nil, 200, &chirps)
+	if len(chirps) != 1 || chirps[0].ID != reply.ID {
+		t.Errorf("Expected the newest chirp first, got %+v", chirps)
+	}
+	s.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil, 200, nil)
+	s.do("GET", "/api/chirps/"+uuid.NewString(), "", nil, 404, nil)
+	s.do("GET", "/api/chirps/abc", "", nil, 400, nil)
+	s.do("DELETE", "/api/chirps/abc", bearer(alice.Token), nil, 400, nil)
+
+	thread := ChirpThread{}
+	s.do("GET", "/api/chirps/"+reply.ID.String()+"/thread", "", nil, 200, &thread)
+	if len(thread.Ancestors) != 1 || thread.Ancestors[0].ID != chirp.ID {
+		t.Errorf("Expected the parent chirp as the only ancestor, got %+v", thread.Ancestors)
+	}
+
+	s.do("GET", "/api/timeline", bearer(bob.Token), nil, 200, &chirps)
+	if len(chirps) != 2 {
+		t.Errorf("Expected 2 chirps on bob's timeline, got %d", len(chirps))
+	}
+	s.do("GET", "/api/tags/golang/chirps", "", nil, 200, &chirps)
+	if len(chirps) != 1 || chirps[0].ID != chirp.ID {
+		t.Errorf("Expected the tagged chirp, got %+v", chirps)
+	}
+	trending := []TrendingTag{}
+	s.do("GET", "/api/tags/trending", "", nil, 200, &trending)
+	if len(trending) != 1 || trending[0].Tag != "golang" {
+		t.Errorf("Expected golang to be trending, got %+v", trending)
+	}
+	s.do("GET", "/api/users/me/mentions", bearer(bob.Token), nil, 200, &chirps)
+	if len(chirps) != 1 || chirps[0].ID != chirp.ID {
+		t.Errorf("Expected bob to be mentioned once, got %+v", chirps)
+	}
+	s.do("GET", "/api/chirps/search?q=world", "", nil, 200, &chirps)
+	if len(chirps) != 1 || !strings.Contains(chirps[0].Snippet, "<mark>world</mark>") {
+		t.Errorf("Expected one highlighted search result, got %+v", chirps)
+	}
+	// Snippets are HTML, so the body is escaped and <mark> is the only markup
+	markup := Chirp{}
+	s.do("POST", "/api/chirps", bearer(alice.Token), map[string]string{"body": `<img src=x onerror="alert(1)"> markup`}, 201, &markup)
+	s.do("GET", "/api/chirps/search?q=markup", "", nil, 200, &chirps)
+	if len(chirps) != 1 || chirps[0].Snippet != `&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>markup</mark>` {
+		t.Errorf("Expected an escaped search snippet, got %+v", chirps)
+	}
+	s.do("DELETE", "/api/chirps/"+markup.ID.String(), bearer(alice.Token), nil, 204, nil)
+
+	// Edits and likes, with editing needing Chirpy Red
+	s.do("PUT", "/api/chirps/"+chirp.ID.String(), bearer(alice.Token), map[string]string{"body": "Hello again"}, 403, nil)
+	s.polkaWebhook("test-polka-key", map[string]any{"id": "evt_edit_up", "event": "user.upgraded", "data": map[string]string{"user_id": alice.ID.String()}}, 204)
+	s.do("PUT", "/api/chirps/"+chirp.ID.String(), bearer(bob.Token), map[string]string{"body": "Not mine"}, 403, nil)
+	// Tags the chirp keeps aren't counted as new, so editing doesn't make them trend again
+	editedAt := time.Now().UTC()
+	time.Sleep(10 * time.Millisecond)
+	s.do("PUT", "/api/chirps/"+chirp.ID.String(), bearer(alice.Token), map[string]string{"body": "Hello again #golang #edited"}, 200, nil)
+	recent, err := s.cfg.store.ListTrendingTags(context.Background(), database.ListTrendingTagsParams{WindowSeconds: time.Since(editedAt).Seconds(), TagLimit: 10})
+	if err != nil {
+		t.Fatalf("Error listing trending tags: %s", err)
+	}
+	if len(recent) != 1 || recent[0].Name != "edited" {
+		t.Errorf("Expected only the added tag to be new, got %+v", recent)
+	}
+	s.do("PUT", "/api/chirps/"+chirp.ID.String(), bearer(alice.Token), map[string]string{"body": "Hello again"}, 200, nil)
+	s.do("GET", "/api/tags/edited/chirps", "", nil, 200, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("Expected tags removed by an edit to be gone, got %+v", chirps)
+	}
+	s.polkaWebhook("test-polka-key", map[string]any{"id": "evt_edit_down", "event": "user.downgraded", "data": map[string]string{"user_id": alice.ID.String()}}, 204)
+	revisions := []ChirpRevision{}
+	s.do("GET", "/api/chirps/"+chirp.ID.String()+"/revisions", "", nil, 401, nil)
+	s.do("GET", "/api/chirps/"+chirp.ID.String()+"/revisions", bearer(bob.Token), nil, 403, nil)
+	s.do("GET", "/api/chirps/"+chirp.ID.String()+"/revisions", bearer(mod.Token), nil, 200, nil)
+	s.do("GET", "/api/chirps/"+chirp.ID.String()+"/revisions", bearer(alice.Token), nil, 200, &revisions)
+	if len(revisions) != 2 || !strings.HasPrefix(revisions[0].Body, "Hello #golang") {
+		t.Errorf("Expected the original body as a revision, got %+v", revisions)
+	}
+	s.do("POST", "/api/chirps/"+chirp.ID.String()+"/likes", bearer(bob.Token), nil, 204, nil)
+	s.do("POST", "/api/chirps/"+chirp.ID.String()+"/likes", bearer(bob.Token), nil, 204, nil)
+	liked := Chirp{}
+	s.do("GET", "/api/chirps/"+chirp.ID.String(), bearer(bob.Token), nil, 200, &liked)
+	if liked.LikeCount != 1 || liked.LikedByMe == nil || !*liked.LikedByMe {
+		t.Errorf("Expected one like by bob, got %+v", liked)
+	}
+	s.do("GET", "/api/chirps?sort=likes", "", nil, 200, &chirps)
+	if chirps[0].ID != chirp.ID {
+		t.Errorf("Expected the liked chirp first, got %+v", chirps)
+	}
+	s.do("DELETE", "/api/chirps/"+chirp.ID.String()+"/likes", bearer(bob.Token), nil, 204, nil)
+
+	// Moderation words and flags
+	s.do("POST", "/admin/moderation/words", bearer(admin.Token), map[string]string{"word": "frobnicate", "action": "flag"}, 200, nil)
+	words := []ModerationWord{}
+	s.do("GET", "/admin/moderation/words", bearer(admin.Token), nil, 200, &words)
+	if len(words) != 4 {
+		t.Errorf("Expected 4 moderation words, got %d", len(words))
+	}
+	s.do("POST", "/api/chirps", bearer(bob.Token), map[string]string{"body": "Let's frobnicate"}, 201, nil)
+	flags := []ModerationFlag{}
+	s.do("GET", "/admin/moderation/flags", bearer(mod.Token), nil, 200, &flags)
+	if len(flags) != 1 || flags[0].Word != "frobnicate" {
+		t.Fatalf("Expected one frobnicate flag, got %+v", flags)
+	}
+	s.do("POST", "/admin/moderation/flags/"+flags[0].ID.String()+"/review", bearer(mod.Token), nil, 204, nil)
+	s.do("DELETE", "/admin/moderation/words/frobnicate", bearer(admin.Token), nil, 204, nil)
+
+	// Reports
+	report := Report{}
+	s.do("POST", "/api/chirps/"+reply.ID.String()+"/reports", bearer(alice.Token), map[string]string{"reason": "rude"}, 201, &report)
+	s.do("POST", "/api/chirps/"+reply.ID.String()+"/reports", bearer(alice.Token), map[string]string{"reason": "rude"}, 409, nil)
+	reports := []Report{}
+	s.do("GET", "/admin/reports", bearer(mod.Token), nil, 200, &reports)
+	if len(reports) != 1 || reports[0].ID != report.ID {
+		t.Errorf("Expected the open report, got %+v", reports)
+	}
+	s.do("POST", "/admin/reports/"+report.ID.String()+"/resolve", bearer(mod.Token), map[string]string{"action": "hide_chirp"}, 200, nil)
+	s.do("GET", "/api/chirps/"+reply.ID.String(), "", nil, 404, nil)
+
+	// Moderators still see hidden chirps, wherever they're listed
+	s.do("GET", "/api/chirps/"+reply.ID.String(), bearer(mod.Token), nil, 200, nil)
+	s.do("GET", "/api/chirps/"+reply.ID.String()+"/revisions", bearer(alice.Token), nil, 404, nil)
+	s.do("GET", "/api/chirps/"+reply.ID.String()+"/revisions", bearer(mod.Token), nil, 200, nil)
+	s.do("GET", "/api/chirps/"+reply.ID.String()+"/thread", "", nil, 200, &thread)
+	if thread.Chirp.Body != "" {
+		t.Errorf("Expected the hidden chirp's body to be blank in the thread, got %q", thread.Chirp.Body)
+	}
+	s.do("GET", "/api/chirps/"+reply.ID.String()+"/thread", bearer(mod.Token), nil, 200, &thread)
+	if thread.Chirp.Body != "Hi back" {
+		t.Errorf("Expected moderators to see the hidden chirp's body, got %q", thread.Chirp.Body)
+	}
+	s.do("GET", "/api/chirps/search?q=back", "", nil, 200, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("Expected the hidden chirp to be left out of search results, got %+v", chirps)
+	}
+	s.do("GET", "/api/chirps/search?q=back", bearer(mod.Token), nil, 200, &chirps)
+	if len(chirps) != 1 || chirps[0].ID != reply.ID {
+		t.Errorf("Expected moderators to find the hidden chirp, got %+v", chirps)
+	}
+	s.do("POST", "/admin/chirps/"+reply.ID.String()+"/unhide", bearer(mod.Token), nil, 204, nil)
+	s.do("GET", "/api/chirps/"+reply.ID.String(), "", nil, 200, nil)
+
+	s.do("POST", "/api/chirps/"+reply.ID.String()+"/reports", bearer(mod.Token), map[string]string{"reason": "still rude"}, 201, &report)
+	s.do("POST", "/admin/reports/"+report.ID.String()+"/resolve", bearer(mod.Token), map[string]string{"action": "suspend_author"}, 200, nil)
+	s.do("POST", "/api/login", "", map[string]string{"email": "bob@example.com", "password": "password2"}, 403, nil)
+	s.do("POST", "/admin/users/"+bob.ID.String()+"/unsuspend", bearer(mod.Token), nil, 204, nil)
+	bob = s.login("bob@example.com", "password2")
+
+	// Moderators can't suspend staff at or above their own role
+	staffChirp := Chirp{}
+	s.do("POST", "/api/chirps", bearer(admin.Token), map[string]string{"body": "Admin notice"}, 201, &staffChirp)
+	s.do("POST", "/api/chirps/"+staffChirp.ID.String()+"/reports", bearer(bob.Token), map[string]string{"reason": "bossy"}, 201, &report)
+	s.do("POST", "/admin/reports/"+report.ID.String()+"/resolve", bearer(mod.Token), map[string]string{"action": "suspend_author"}, 403, nil)
+	s.do("GET", "/admin/reports", bearer(admin.Token), nil, 200, nil)
+	s.do("POST", "/admin/reports/"+report.ID.String()+"/resolve", bearer(mod.Token), map[string]string{"action": "dismiss"}, 200, nil)
+	s.do("DELETE", "/api/chirps/"+staffChirp.ID.String(), bearer(admin.Token), nil, 204, nil)
+
+	// Deletes and unfollows
+	s.do("DELETE", "/api/chirps/"+reply.ID.String(), bearer(alice.Token), nil, 403, nil)
+	s.do("DELETE", "/api/chirps/"+reply.ID.String(), bearer(bob.Token), nil, 204, nil)
+	s.do("GET", "/api/chirps/"+reply.ID.String(), "", nil, 404, nil)
+	s.do("DELETE", "/api/users/"+alice.ID.String()+"/follow", bearer(bob.Token), nil, 204, nil)
+
+	// Polka webhooks
+	upgrade := map[string]any{"id": "evt_1", "event": "user.upgraded", "data": map[string]string{"user_id": alice.ID.String()}}
+	s.do("POST", "/api/polka/webhooks", "ApiKey test-polka-key", upgrade, 401, nil)
+	s.polkaWebhook("wrong-key", upgrade, 401)
+	s.polkaWebhook("old-polka-key", upgrade, 204)
+	if user := s.login("alice@example.com", "password"); !user.ChirpyRed {
+		t.Errorf("Expected alice to be upgraded to Chirpy Red")
+	}
+	s.polkaWebhook("test-polka-key", upgrade, 204)
+	s.polkaWebhook("test-polka-key", map[string]any{"id": "evt_2", "event": "user.upgraded", "data": map[string]string{"user_id": uuid.NewString()}}, 404)
+	s.polkaWebhook("test-polka-key", map[string]any{"id": "evt_3", "event": "user.deleted"}, 204)
+	s.polkaWebhook("test-polka-key", map[string]any{"id": "evt_3", "event": "user.deleted"}, 204)
+	// An ignored event isn't a duplicate once it's one we handle, so this upgrade is processed and its user looked up
+	s.polkaWebhook("test-polka-key", map[string]any{"id": "evt_3", "event": "user.upgraded", "data": map[string]string{"user_id": uuid.NewString()}}, 404)
+	s.polkaWebhook("test-polka-key", "not an event", 400)
+	s.polkaWebhook("test-polka-key", map[string]any{"event": "user.upgraded"}, 400)
+
+	// Chirpy Red subscriptions
+	aliceData := map[string]any{"user_id": alice.ID.String()}
+	event := func(id, name string, data map[string]any) map[string]any {
+		return map[string]any{"id": id, "event": name, "data": data}
+	}
+	withPeriodEnd := func(periodEnd time.Time) map[string]any {
+		return map[string]any{"user_id": alice.ID.String(), "current_period_end": periodEnd}
+	}
+	isChirpyRed := func() bool {
+		return s.login("alice@example.com", "password").ChirpyRed
+	}
+	s.polkaWebhook("test-polka-key", event("evt_4", "payment.failed", aliceData), 204)
+	s.polkaWebhook("test-polka-key", event("evt_5", "subscription.cancelled", aliceData), 204)
+	if !isChirpyRed() {
+		t.Errorf("Expected alice to keep Chirpy Red until her period ends")
+	}
+	s.polkaWebhook("test-polka-key", event("evt_6", "subscription.renewed", aliceData), 400)
+	s.polkaWebhook("test-polka-key", event("evt_6", "subscription.renewed", withPeriodEnd(time.Now().Add(-time.Hour))), 204)
+	if isChirpyRed() {
+		t.Errorf("Expected alice to lose Chirpy Red once her period ended")
+	}
+	if err := s.cfg.sweepSubscriptions(context.Background()); err != nil {
+		t.Fatalf("Error expiring lapsed subscriptions: %s", err)
+	}
+	s.polkaWebhook("test-polka-key", event("evt_7", "subscription.renewed", withPeriodEnd(time.Now().Add(time.Hour))), 204)
+	if !isChirpyRed() {
+		t.Errorf("Expected alice to get Chirpy Red back after renewing")
+	}
+	s.polkaWebhook("test-polka-key", event("evt_8", "user.downgraded", aliceData), 204)
+	if isChirpyRed() {
+		t.Errorf("Expected alice to lose Chirpy Red when downgraded")
+	}
+	s.polkaWebhook("test-polka-key", event("evt_9", "subscription.cancelled", map[string]any{"user_id": bob.ID.String()}), 404)
+
+	// Chirpy Red features
+	longBody := strings.Repeat("a", 200)
+	s.do("POST", "/api/chirps", bearer(alice.Token), map[string]string{"body": longBody}, 400, nil)
+	s.do("POST", "/api/chirps", bearer(alice.Token), map[string]any{"body": "Later", "publish_at": time.Now().Add(time.Hour)}, 403, nil)
+	s.polkaWebhook("test-polka-key", event("evt_10", "user.upgraded", aliceData), 204)
+	s.do("POST", "/api/chirps", bearer(alice.Token), map[string]string{"body": longBody}, 201, nil)
+	s.do("POST", "/api/chirps", bearer(alice.Token), map[string]string{"body": longBody + longBody}, 400, nil)
+	s.do("POST", "/api/chirps", bearer(alice.Token), map[string]any{"body": "Too late", "publish_at": time.Now().Add(-time.Hour)}, 400, nil)
+
+	scheduled := ScheduledChirp{}
+	s.do("POST", "/api/chirps", bearer(alice.Token), map[string]any{"body": "Later #golang", "publish_at": time.Now().Add(time.Hour)}, 202, &scheduled)
+	cancelled := ScheduledChirp{}
+	s.do("POST", "/api/chirps", bearer(alice.Token), map[string]any{"body": "Never", "publish_at": time.Now().Add(time.Hour)}, 202, &cancelled)
+	s.do("DELETE", "/api/users/me/scheduled-chirps/"+cancelled.ID.String(), bearer(bob.Token), nil, 404, nil)
+	s.do("DELETE", "/api/users/me/scheduled-chirps/"+cancelled.ID.String(), bearer(alice.Token), nil, 204, nil)
+	pending := []ScheduledChirp{}
+	s.do("GET", "/api/users/me/scheduled-chirps", bearer(alice.Token), nil, 200, &pending)
+	if len(pending) != 1 || pending[0].ID != scheduled.ID {
+		t.Errorf("Expected one scheduled chirp, got %+v", pending)
+	}
+	s.do("GET", "/api/chirps/search?q=later", "", nil, 200, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("Expected the scheduled chirp not to be posted yet, got %+v", chirps)
+	}
+	posted, err := s.cfg.publishScheduledChirps(context.Background(), time.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Error posting scheduled chirps: %s", err)
+	}
+	if posted != 1 {
+		t.Errorf("Expected 1 scheduled chirp to be posted, got %d", posted)
+	}
+	s.do("GET", "/api/users/me/scheduled-chirps", bearer(alice.Token), nil, 200, &pending)
+	if len(pending) != 0 {
+		t.Errorf("Expected no scheduled chirps left, got %+v", pending)
+	}
+	s.do("GET", "/api/chirps/search?q=later", "", nil, 200, &chirps)
+	if len(chirps) != 1 || chirps[0].UserID != alice.ID {
+		t.Errorf("Expected alice's scheduled chirp to be posted, got %+v", chirps)
+	}
+
+	// Outbound webhooks, sent to a receiver that checks their signatures and fails the first attempt
+	var mu sync.Mutex
+	received := []webhookPayload{}
+	secrets := []string{}
+	failures := 1
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		if err := auth.VerifyWebhookSignature(body, r.Header.Get(chirpyTimestampHeader), r.Header.Get(chirpySignatureHeader), secrets, time.Minute); err != nil {
+			w.WriteHeader(401)
+			return
+		}
+		if failures > 0 {
+			failures--
+			w.WriteHeader(503)
+			w.Write([]byte("try later"))
+			return
+		}
+		payload := webhookPayload{}
+		json.Unmarshal(body, &payload)
+		received = append(received, payload)
+	}))
+	defer receiver.Close()
+	deliver := func(dueBy time.Time) int {
+		t.Helper()
+		n, err := s.cfg.deliverWebhooks(context.Background(), dueBy)
+		if err != nil {
+			t.Fatalf("Error sending webhooks: %s", err)
+		}
+		return n
+	}
+
+	s.do("POST", "/api/users/me/webhooks", "", map[string]any{"url": receiver.URL, "events": []string{"chirp.created"}}, 401, nil)
+
+	// Internal addresses are refused, so the local receiver is only allowed once the test opts in
+	for _, internal := range []string{receiver.URL, "http://169.254.169.254/latest/meta-data", "http://10.0.0.1/", "http://[::1]:8080/admin/reset", "http://0.0.0.0/"} {
+		s.do("POST", "/api/users/me/webhooks", bearer(alice.Token), map[string]any{"url": internal, "events": []string{"chirp.created"}}, 400, nil)
+	}
+	s.cfg.webhookAllowPrivate = true
+	s.cfg.webhookClient = newWebhookClient(true)
+	s.do("POST", "/api/users/me/webhooks", bearer(alice.Token), map[string]any{"url": "ftp://example.com", "events": []string{"chirp.created"}}, 400, nil)
+	s.do("POST", "/api/users/me/webhooks", bearer(alice.Token), map[string]any{"url": receiver.URL, "events": []string{"user.deleted"}}, 400, nil)
+	s.do("POST", "/api/users/me/webhooks", bearer(alice.Token), map[string]any{"url": receiver.URL, "events": []string{"chirp.created"}, "secret": "short"}, 400, nil)
+	hook := WebhookSubscription{}
+	s.do("POST", "/api/users/me/webhooks", bearer(alice.Token), map[string]any{"url": receiver.URL, "events": []string{"chirp.deleted", "chirp.created"}}, 201, &hook)
+	adminHook := WebhookSubscription{}
+	s.do("POST", "/admin/webhooks", bearer(mod.Token), map[string]any{"url": receiver.URL, "events": []string{"user.upgraded"}}, 403, nil)
+	s.do("POST", "/admin/webhooks", bearer(admin.Token), map[string]any{"url": receiver.URL, "events": []string{"user.upgraded"}, "secret": "admin-webhook-secret"}, 201, &adminHook)
+	mu.Lock()
+	secrets = append(secrets, hook.Secret, adminHook.Secret)
+	mu.Unlock()
+
+	hooks := []WebhookSubscription{}
+	s.do("GET", "/api/users/me/webhooks", bearer(alice.Token), nil, 200, &hooks)
+	if len(hooks) != 1 || hooks[0].Secret != "" || len(hooks[0].Events) != 2 {
+		t.Errorf("Expected alice's webhook with both events and no secret, got %+v", hooks)
+	}
+	s.do("GET", "/admin/webhooks", bearer(admin.Token), nil, 200, &hooks)
+	if len(hooks) != 1 || hooks[0].ID != adminHook.ID || hooks[0].UserID != nil {
+		t.Errorf("Expected the admin webhook, got %+v", hooks)
+	}
+
+	// Only alice's chirp goes to her webhook, and the first attempt is retried after a backoff
+	hooked := Chirp{}
+	s.do("POST", "/api/chirps", bearer(alice.Token), map[string]string{"body": "Hooked"}, 201, &hooked)
+	s.do("POST", "/api/chirps", bearer(bob.Token), map[string]string{"body": "Not hooked"}, 201, nil)
+	if n := deliver(time.Now()); n != 1 {
+		t.Errorf("Expected 1 webhook to be sent, got %d", n)
+	}
+	if n := deliver(time.Now()); n != 0 {
+		t.Errorf("Expected the failed webhook to wait before it's retried, got %d sent", n)
+	}
+	if n := deliver(time.Now().Add(time.Hour)); n != 1 {
+		t.Errorf("Expected the failed webhook to be retried, got %d sent", n)
+	}
+	attempts := []WebhookDeliveryAttempt{}
+	s.do("GET", "/api/users/me/webhooks/"+hook.ID.String()+"/deliveries", bearer(alice.Token), nil, 200, &attempts)
+	if len(attempts) != 2 || *attempts[0].ResponseStatus != 200 || *attempts[1].ResponseStatus != 503 || attempts[1].ResponseBody != "try later" || attempts[0].DeliveryStatus != "delivered" {
+		t.Errorf("Expected a failed then a successful attempt, got %+v", attempts)
+	}
+	s.do("GET", "/api/users/me/webhooks/"+hook.ID.String()+"/deliveries?limit=1", bearer(alice.Token), nil, 200, &attempts)
+	if len(attempts) != 1 {
+		t.Errorf("Expected 1 attempt, got %d", len(attempts))
+	}
+	s.do("GET", "/api/users/me/webhooks/"+hook.ID.String()+"/deliveries", bearer(bob.Token), nil, 404, nil)
+	s.do("GET", "/admin/webhooks/"+hook.ID.String()+"/deliveries", bearer(admin.Token), nil, 404, nil)
+
+	s.do("DELETE", "/api/chirps/"+hooked.ID.String(), bearer(alice.Token), nil, 204, nil)
+	s.polkaWebhook("test-polka-key", event("evt_11", "user.upgraded", map[string]any{"user_id": bob.ID.String()}), 204)
+	if n := deliver(time.Now()); n != 2 {
+		t.Errorf("Expected 2 webhooks to be sent, got %d", n)
+	}
+	mu.Lock()
+	if len(received) != 3 || received[0].Event != "chirp.created" {
+		t.Errorf("Expected chirp.created then chirp.deleted and user.upgraded, got %+v", received)
+	} else {
+		got := []string{received[1].Event, received[2].Event}
+		slices.Sort(got)
+		if got[0] != "chirp.deleted" || got[1] != "user.upgraded" {
+			t.Errorf("Expected chirp.deleted and user.upgraded, got %v", got)
+		}
+	}
+	mu.Unlock()
+
+	s.do("DELETE", "/api/users/me/webhooks/"+hook.ID.String(), bearer(bob.Token), nil, 404, nil)
+	s.do("DELETE", "/api/users/me/webhooks/"+hook.ID.String(), bearer(alice.Token), nil, 204, nil)
+	s.do("DELETE", "/api/users/me/webhooks/"+hook.ID.String(), bearer(alice.Token), nil, 404, nil)
+	s.do("DELETE", "/admin/webhooks/"+adminHook.ID.String(), bearer(admin.Token), nil, 204, nil)
+
+	// Reset
+	s.do("POST", "/admin/reset", bearer(mod.Token), nil, 403, nil)
+	s.do("POST", "/admin/reset", bearer(admin.Token), nil, 200, nil)
+	s.do("GET", "/api/chirps", "", nil, 200, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("Expected no chirps after reset, got %d", len(chirps))
+	}
+}
+
+func TestPagination(t *testing.T) {
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testPagination(t, newTestServer(t, backend))
+		})
+	}
+}
+
+// Walk the chirps two at a time, following the next page links
+func testPagination(t *testing.T, s *testServer) {
+	alice := s.signUp("alice@example.com", "alice")
+	for i := range 5 {
+		s.do("POST", "/api/chirps", bearer(alice.Token), map[string]string{"body": fmt.Sprintf("Chirp %d", i)}, 201, nil)
+	}
+
+	seen := map[uuid.UUID]bool{}
+	path := "/api/chirps?limit=2"
+	for pages := 0; path != ""; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected 3 pages, still going at %s", path)
+		}
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		s.handler.ServeHTTP(rec, req)
+		if rec.Code != 200 {
+			t.Fatalf("Expected status 200 from %s, got %d", path, rec.Code)
+		}
+		chirps := []Chirp{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &chirps); err != nil {
+			t.Fatalf("Error decoding chirps: %s", err)
+		}
+		for _, chirp := range chirps {
+			if seen[chirp.ID] {
+				t.Errorf("Chirp %s returned twice", chirp.ID)
+			}
+			seen[chirp.ID] = true
+		}
+		path = nextPagePath(rec.Header().Get("Link"))
+	}
+	if len(seen) != 5 {
+		t.Errorf("Expected 5 chirps across the pages, got %d", len(seen))
+	}
+
+	// Without limit or cursor every chirp comes back at once, as it did before pagination
+	req := httptest.NewRequest("GET", "/api/chirps", nil)
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	chirps := []Chirp{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &chirps); err != nil {
+		t.Fatalf("Error decoding chirps: %s", err)
+	}
+	if len(chirps) != 5 || rec.Header().Get("Link") != "" {
+		t.Errorf("Expected all 5 chirps and no Link header, got %d chirps and Link %q", len(chirps), rec.Header().Get("Link"))
+	}
+}
+
+// Path of the rel="next" link in a Link header, or "" if there isn't one
+func nextPagePath(link string) string {
+	target, ok := strings.CutSuffix(link, `>; rel="next"`)
+	if !ok {
+		return ""
+	}
+	target = strings.TrimPrefix(target, "<")
+	if i := strings.Index(target, "/api/"); i >= 0 {
+		return target[i:]
+	}
+	return target
+}
+
+func TestRateLimit(t *testing.T) {
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			testRateLimit(t, newTestServer(t, backend))
+		})
+	}
+}
+
+// Limit logins by client IP, and chirps by user with Chirpy Red raising the limit
+func testRateLimit(t *testing.T, s *testServer) {
+	alice := s.signUp("alice@example.com", "alice")
+	bob := s.signUp("bob@example.com", "bob")
+	carol := s.signUp("carol@example.com", "carol")
+	s.polkaWebhook("test-polka-key", map[string]any{"id": "evt_rate_limit", "event": "user.upgraded", "data": map[string]string{"user_id": carol.ID.String()}}, 204)
+	s.cfg.rateLimiter = ratelimit.NewMemory()
+	s.cfg.rateLimits = ratelimit.Rules{
+		"POST /api/login":  {Burst: 2, Per: time.Minute},
+		"POST /api/chirps": {Burst: 1, Per: time.Hour},
+	}
+
+	// Logins share a bucket per client IP, whoever they're for
+	login := map[string]string{"email": "alice@example.com", "password": "wrong"}
+	s.do("POST", "/api/login", "", login, 401, nil)
+	s.do("POST", "/api/login", "", login, 401, nil)
+	req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"bob@example.com","password":"password"}`))
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	if rec.Code != 429 {
+		t.Fatalf("Expected status 429 once the login limit is used up, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Expected Retry-After 30, got %q", got)
+	}
+	if got := rec.Header().Get("X-RateLimit-Limit"); got != "2" {
+		t.Errorf("Expected X-RateLimit-Limit 2, got %q", got)
+	}
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("Expected X-RateLimit-Remaining 0, got %q", got)
+	}
+
+	// Another client IP has its own bucket
+	req = httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"bob@example.com","password":"password"}`))
+	req.RemoteAddr = "192.0.2.2:1234"
+	rec = httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("Expected status 200 from another client IP, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Behind a trusted proxy, clients are told apart by the right-most X-Forwarded-For address that isn't a proxy
+	s.cfg.trustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	proxied := func(forwardedFor string) int {
+		req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"bob@example.com","password":"wrong"}`))
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		s.handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for _, forwardedFor := range []string{"198.51.100.1", "203.0.113.9, 198.51.100.1, 10.0.0.2"} {
+		if got := proxied(forwardedFor); got != 401 {
+			t.Fatalf("Expected status 401 from behind the proxy, got %d", got)
+		}
+	}
+	if got := proxied("198.51.100.1"); got != 429 {
+		t.Errorf("Expected the client behind the proxy to be limited, got %d", got)
+	}
+	if got := proxied("198.51.100.2"); got != 401 {
+		t.Errorf("Expected another client behind the proxy to have its own bucket, got %d", got)
+	}
+	if got := proxied("198.51.100.2, 198.51.100.1"); got != 429 {
+		t.Errorf("Expected addresses added by the client to be ignored, got %d", got)
+	}
+
+	// Chirps are limited per user, and routes without a rule aren't limited at all
+	chirp := map[string]string{"body": "Hello"}
+	s.do("POST", "/api/chirps", bearer(alice.Token), chirp, 201, nil)
+	s.do("POST", "/api/chirps", bearer(alice.Token), chirp, 429, nil)
+	s.do("POST", "/api/chirps", bearer(bob.Token), chirp, 201, nil)
+	for range 3 {
+		s.do("GET", "/api/chirps", "", nil, 200, nil)
+	}
+
+	// Chirpy Red users get five times the limit
+	for range 5 {
+		s.do("POST", "/api/chirps", bearer(carol.Token), chirp, 201, nil)
+	}
+	s.do("POST", "/api/chirps", bearer(carol.Token), chirp, 429, nil)
+}
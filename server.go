@@ -0,0 +1,447 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/auth"
+	"github.com/frogonabike/chirpy/internal/logging"
+	"github.com/frogonabike/chirpy/internal/ratelimit"
+	"github.com/frogonabike/chirpy/internal/store"
+	"github.com/frogonabike/chirpy/sql/schema"
+	sqliteschema "github.com/frogonabike/chirpy/sql/sqlite/schema"
+	"github.com/pressly/goose/v3"
+)
+
+// HTTP server settings, read from the environment
+type serverConfig struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	DrainDelay        time.Duration
+	MaxHeaderBytes    int
+}
+
+// Environment variables the server can't run without
+// DB_URL is only needed with the database backend
+var requiredEnv = []string{"JWT_SECRET", "POLKA_KEY"}
+
+// Storage backends that can be picked with STORAGE
+const (
+	storageDatabase = "database"
+	storageMemory   = "memory"
+)
+
+// Read the storage backend from STORAGE, defaulting to the database in DB_URL
+// postgres is still accepted from before SQLite was supported
+func loadStorageBackend(getenv func(string) string) (string, error) {
+	switch backend := getenv("STORAGE"); backend {
+	case "", storageDatabase, "postgres":
+		return storageDatabase, nil
+	case storageMemory:
+		return storageMemory, nil
+	default:
+		return "", fmt.Errorf("unknown STORAGE %q, expected %s or %s", backend, storageDatabase, storageMemory)
+	}
+}
+
+// Pragmas every SQLite connection is opened with
+// Immediate transactions take the write lock when they begin, rather than failing when they first write
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate&_time_format=sqlite"
+
+// Database settings, picked by the scheme of DB_URL
+type databaseConfig struct {
+	Driver     string // database/sql driver name
+	DSN        string
+	Dialect    goose.Dialect
+	Migrations fs.FS
+}
+
+// Parse DB_URL - postgres:// or postgresql:// for Postgres, sqlite:path or sqlite://path for a SQLite file
+func loadDatabaseConfig(dbURL string) (databaseConfig, error) {
+	switch {
+	case strings.HasPrefix(dbURL, "postgres://"), strings.HasPrefix(dbURL, "postgresql://"):
+		return databaseConfig{Driver: "postgres", DSN: dbURL, Dialect: goose.DialectPostgres, Migrations: schema.FS}, nil
+	case strings.HasPrefix(dbURL, "sqlite:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(dbURL, "sqlite:"), "//")
+		if path == "" {
+			return databaseConfig{}, errors.New("DB_URL is missing the SQLite file path")
+		}
+		return databaseConfig{
+			Driver:     "sqlite",
+			DSN:        "file:" + path + "?" + sqlitePragmas,
+			Dialect:    goose.DialectSQLite3,
+			Migrations: sqliteschema.FS,
+		}, nil
+	default:
+		return databaseConfig{}, errors.New("unsupported DB_URL, expected a postgres:// or sqlite: URL")
+	}
+}
+
+// Create the store for an open database
+func (c databaseConfig) newStore(db *sql.DB) store.Store {
+	if c.Dialect == goose.DialectSQLite3 {
+		return store.NewSQLite(db)
+	}
+	return store.NewPostgres(db)
+}
+
+// Check the required environment variables are all set
+func checkRequiredEnv(getenv func(string) string) error {
+	backend, err := loadStorageBackend(getenv)
+	if err != nil {
+		return err
+	}
+	required := requiredEnv
+	if backend == storageDatabase {
+		required = append([]string{"DB_URL"}, required...)
+	}
+
+	missing := []string{}
+	for _, name := range required {
+		if getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required environment variables: %v", missing)
+	}
+	return nil
+}
+
+// Read the keys Polka webhooks can be signed with from POLKA_KEY, a comma-separated list
+// Polka is given the first key, and the others are still accepted while a rotation finishes
+func loadPolkaKeys(getenv func(string) string) ([]string, error) {
+	keys := []string{}
+	for _, key := range strings.Split(getenv("POLKA_KEY"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("POLKA_KEY must contain at least one key")
+	}
+	return keys, nil
+}
+
+// Read the proxies whose X-Forwarded-For headers are trusted from TRUSTED_PROXIES,
+// a comma-separated list of CIDR ranges or addresses
+func loadTrustedProxies(getenv func(string) string) ([]netip.Prefix, error) {
+	proxies := []netip.Prefix{}
+	for entry := range strings.SplitSeq(getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES entry %q must be a CIDR range such as 10.0.0.0/8, or an address", entry)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// Read the server settings from the environment, using defaults for anything unset
+func loadServerConfig(getenv func(string) string) (serverConfig, error) {
+	serverCfg := serverConfig{
+		Addr:              ":8080",
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   15 * time.Second,
+		DrainDelay:        5 * time.Second,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+	}
+
+	if addr := getenv("LISTEN_ADDR"); addr != "" {
+		serverCfg.Addr = addr
+	}
+
+	durations := []struct {
+		name      string
+		value     *time.Duration
+		allowZero bool
+	}{
+		{"READ_TIMEOUT", &serverCfg.ReadTimeout, false},
+		{"READ_HEADER_TIMEOUT", &serverCfg.ReadHeaderTimeout, false},
+		{"WRITE_TIMEOUT", &serverCfg.WriteTimeout, false},
+		{"IDLE_TIMEOUT", &serverCfg.IdleTimeout, false},
+		{"SHUTDOWN_TIMEOUT", &serverCfg.ShutdownTimeout, false},
+		{"SHUTDOWN_DRAIN_DELAY", &serverCfg.DrainDelay, true},
+	}
+	for _, d := range durations {
+		param := getenv(d.name)
+		if param == "" {
+			continue
+		}
+		value, err := time.ParseDuration(param)
+		if err != nil || value < 0 || (value == 0 && !d.allowZero) {
+			return serverConfig{}, fmt.Errorf("%s must be a positive duration such as 30s", d.name)
+		}
+		*d.value = value
+	}
+
+	if param := getenv("MAX_HEADER_BYTES"); param != "" {
+		value, err := strconv.Atoi(param)
+		if err != nil || value <= 0 {
+			return serverConfig{}, errors.New("MAX_HEADER_BYTES must be a positive integer")
+		}
+		serverCfg.MaxHeaderBytes = value
+	}
+
+	return serverCfg, nil
+}
+
+// Rate limits applied unless RATE_LIMITS overrides them - the routes open to password guessing and spam
+var defaultRateLimits = ratelimit.Rules{
+	"POST /api/login":                    {Burst: 5, Per: time.Minute},
+	"POST /api/users":                    {Burst: 10, Per: time.Hour},
+	"POST /api/refresh":                  {Burst: 30, Per: time.Minute},
+	"POST /api/chirps":                   {Burst: 30, Per: time.Minute},
+	"POST /api/chirps/{chirpID}/reports": {Burst: 10, Per: time.Hour},
+}
+
+// Rate limit backends that can be picked with RATE_LIMIT_BACKEND
+const (
+	rateLimitMemory   = "memory"
+	rateLimitPostgres = "postgres"
+)
+
+// Read the rate limit rules from RATE_LIMITS on top of the defaults, and the backend from RATE_LIMIT_BACKEND
+// The Postgres backend shares buckets between replicas, so it needs DB_URL to be a Postgres database
+func loadRateLimitConfig(getenv func(string) string) (ratelimit.Rules, string, error) {
+	rules, err := ratelimit.ParseRules(getenv("RATE_LIMITS"), defaultRateLimits)
+	if err != nil {
+		return nil, "", fmt.Errorf("RATE_LIMITS: %w", err)
+	}
+	switch backend := getenv("RATE_LIMIT_BACKEND"); backend {
+	case "", rateLimitMemory:
+		return rules, rateLimitMemory, nil
+	case rateLimitPostgres:
+		storage, _ := loadStorageBackend(getenv)
+		dbCfg, err := loadDatabaseConfig(getenv("DB_URL"))
+		if storage != storageDatabase || err != nil || dbCfg.Dialect != goose.DialectPostgres {
+			return nil, "", errors.New("RATE_LIMIT_BACKEND postgres needs a postgres:// DB_URL")
+		}
+		return rules, rateLimitPostgres, nil
+	default:
+		return nil, "", fmt.Errorf("unknown RATE_LIMIT_BACKEND %q, expected %s or %s", backend, rateLimitMemory, rateLimitPostgres)
+	}
+}
+
+// Build the HTTP server with every route and middleware registered
+func newServer(cfg *apiConfig) *http.Server {
+	// Create a new HTTP server mux
+	mux := http.NewServeMux()
+
+	// *** General handlers ***
+
+	// Handler to serve static files - Just to tidy up the next section :)
+	fileHandler := http.StripPrefix("/app/", http.FileServer(http.Dir(".")))
+
+	// Serve static files from the current directory at /app/
+	mux.Handle("/app/", cfg.middlewareMetricsInc(fileHandler))
+
+	// Liveness probe endpoints - healthz is kept for existing deployments
+	mux.HandleFunc("GET /api/healthz", livezHandler)
+	mux.HandleFunc("GET /api/livez", livezHandler)
+
+	// Readiness probe endpoint
+	mux.HandleFunc("GET /api/readyz", cfg.readyzHandler)
+
+	// Prometheus metrics endpoint
+	mux.Handle("GET /metrics", cfg.metrics.Handler())
+
+	// Metrics endpoint
+	mux.Handle("GET /admin/metrics", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.metricsHandler)))
+
+	// *** Moderation related handlers ***
+
+	// Return the banned word list endpoint
+	mux.Handle("GET /admin/moderation/words", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.listModerationWordsHandler)))
+
+	// Add or change a banned word endpoint
+	mux.Handle("POST /admin/moderation/words", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.upsertModerationWordHandler)))
+
+	// Remove a banned word endpoint
+	mux.Handle("DELETE /admin/moderation/words/{word}", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.deleteModerationWordHandler)))
+
+	// Return chirps flagged for review endpoint
+	mux.Handle("GET /admin/moderation/flags", cfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(cfg.listModerationFlagsHandler)))
+
+	// Mark a flag as reviewed endpoint
+	mux.Handle("POST /admin/moderation/flags/{flagID}/review", cfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(cfg.reviewModerationFlagHandler)))
+
+	// Return the report queue endpoint
+	mux.Handle("GET /admin/reports", cfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(cfg.listReportsHandler)))
+
+	// Resolve a report endpoint
+	mux.Handle("POST /admin/reports/{reportID}/resolve", cfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(cfg.resolveReportHandler)))
+
+	// Unhide a hidden chirp endpoint
+	mux.Handle("POST /admin/chirps/{chirpID}/unhide", cfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(cfg.unhideChirpHandler)))
+
+	// Lift a user's suspension endpoint
+	mux.Handle("POST /admin/users/{userID}/unsuspend", cfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(cfg.unsuspendUserHandler)))
+
+	// Change a user's role endpoint
+	mux.Handle("PUT /admin/users/{userID}/role", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.setUserRoleHandler)))
+
+	// *** Outbound webhook related handlers ***
+
+	// Subscribe to webhooks about every user endpoint
+	mux.Handle("POST /admin/webhooks", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.createAdminWebhookSubscriptionHandler)))
+
+	// Return the admin webhook subscriptions endpoint
+	mux.Handle("GET /admin/webhooks", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.listAdminWebhookSubscriptionsHandler)))
+
+	// Delete an admin webhook subscription endpoint
+	mux.Handle("DELETE /admin/webhooks/{webhookID}", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.deleteAdminWebhookSubscriptionHandler)))
+
+	// Return an admin webhook subscription's delivery log endpoint
+	mux.Handle("GET /admin/webhooks/{webhookID}/deliveries", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.listAdminWebhookDeliveriesHandler)))
+
+	// Subscribe to webhooks about the user endpoint
+	mux.HandleFunc("POST /api/users/me/webhooks", cfg.createWebhookSubscriptionHandler)
+
+	// Return the user's webhook subscriptions endpoint
+	mux.HandleFunc("GET /api/users/me/webhooks", cfg.listWebhookSubscriptionsHandler)
+
+	// Unsubscribe from webhooks endpoint
+	mux.HandleFunc("DELETE /api/users/me/webhooks/{webhookID}", cfg.deleteWebhookSubscriptionHandler)
+
+	// Return a webhook subscription's delivery log endpoint
+	mux.HandleFunc("GET /api/users/me/webhooks/{webhookID}/deliveries", cfg.listWebhookDeliveriesHandler)
+
+	// *** User related handlers ***
+
+	// Reset users database
+	mux.Handle("POST /admin/reset", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.resetUsersHandler)))
+
+	// User creation endpoint
+	mux.HandleFunc("POST /api/users", cfg.createUserHandler)
+
+	// User update endpoint
+	mux.HandleFunc("PUT /api/users", cfg.updateUserHandler)
+
+	// Login endpoint
+	mux.HandleFunc("POST /api/login", cfg.userLoginHandler)
+
+	// *** Follow related handlers ***
+
+	// Follow user endpoint
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.followUserHandler)
+
+	// Unfollow user endpoint
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.unfollowUserHandler)
+
+	// Return a user's followers endpoint
+	mux.HandleFunc("GET /api/users/{userID}/followers", cfg.getFollowersHandler)
+
+	// Return the users a user follows endpoint
+	mux.HandleFunc("GET /api/users/{userID}/following", cfg.getFollowingHandler)
+
+	// Home timeline endpoint
+	mux.HandleFunc("GET /api/timeline", cfg.timelineHandler)
+
+	// Return chirps mentioning the user endpoint
+	mux.HandleFunc("GET /api/users/me/mentions", cfg.mentionsHandler)
+
+	// *** Tag related handlers ***
+
+	// Return chirps with a hashtag endpoint
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", cfg.getTagChirpsHandler)
+
+	// Return the most used hashtags endpoint
+	mux.HandleFunc("GET /api/tags/trending", cfg.trendingTagsHandler)
+
+	// *** Chirp related handlers ***
+
+	// Chirp creation endpoint
+	mux.HandleFunc("POST /api/chirps", cfg.chirpHandler)
+
+	// Return all chirps endpoint
+	mux.HandleFunc("GET /api/chirps", cfg.getAllChirpsHandler)
+
+	// Search chirps endpoint
+	mux.HandleFunc("GET /api/chirps/search", cfg.searchChirpsHandler)
+
+	// Return specfic chirp endpoint
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.getChirpByIDHandler)
+
+	// Edit chirp endpoint
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", cfg.updateChirpHandler)
+
+	// Delete chirp endpoint
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.deleteChirpByIDHandler)
+
+	// Like chirp endpoint
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", cfg.likeChirpHandler)
+
+	// Unlike chirp endpoint
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", cfg.unlikeChirpHandler)
+
+	// Return the conversation around a chirp endpoint
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.getChirpThreadHandler)
+
+	// Return previous versions of an edited chirp endpoint
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.getChirpRevisionsHandler)
+
+	// Report an abusive chirp endpoint
+	mux.HandleFunc("POST /api/chirps/{chirpID}/reports", cfg.reportChirpHandler)
+
+	// *** Token related handlers ***
+
+	// Token refresh endpoint
+	mux.HandleFunc("POST /api/refresh", cfg.tokenRefreshHandler)
+
+	// Revoke refresh token endpoint
+	mux.HandleFunc("POST /api/revoke", cfg.revokeRefreshTokenHandler)
+
+	// Return the user's active sessions endpoint
+	mux.HandleFunc("GET /api/users/me/sessions", cfg.listSessionsHandler)
+
+	// Sign out one session endpoint
+	mux.HandleFunc("DELETE /api/users/me/sessions/{sessionID}", cfg.revokeSessionHandler)
+
+	// Sign out every session endpoint
+	mux.HandleFunc("POST /api/logout-all", cfg.logoutAllHandler)
+
+	// Return the user's scheduled chirps endpoint
+	mux.HandleFunc("GET /api/users/me/scheduled-chirps", cfg.listScheduledChirpsHandler)
+
+	// Cancel a scheduled chirp endpoint
+	mux.HandleFunc("DELETE /api/users/me/scheduled-chirps/{scheduledChirpID}", cfg.deleteScheduledChirpHandler)
+
+	// *** Webhook related handlers ***
+
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.webhookHandler)
+
+	return &http.Server{
+		Addr:              cfg.server.Addr,
+		Handler:           logging.Middleware(slog.Default(), cfg.metrics.Middleware(cfg.middlewareRateLimit(mux, mux))),
+		ReadTimeout:       cfg.server.ReadTimeout,
+		ReadHeaderTimeout: cfg.server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.server.WriteTimeout,
+		IdleTimeout:       cfg.server.IdleTimeout,
+		MaxHeaderBytes:    cfg.server.MaxHeaderBytes,
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/metrics"
+)
+
+// Build a getenv function from a map
+func envFrom(env map[string]string) func(string) string {
+	return func(name string) string {
+		return env[name]
+	}
+}
+
+func TestLoadServerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    serverConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{},
+			want: serverConfig{
+				Addr:              ":8080",
+				ReadTimeout:       10 * time.Second,
+				ReadHeaderTimeout: 5 * time.Second,
+				WriteTimeout:      30 * time.Second,
+				IdleTimeout:       120 * time.Second,
+				ShutdownTimeout:   15 * time.Second,
+				DrainDelay:        5 * time.Second,
+				MaxHeaderBytes:    1 << 20,
+			},
+		},
+		{
+			name: "overrides",
+			env: map[string]string{
+				"LISTEN_ADDR":          "127.0.0.1:9000",
+				"READ_TIMEOUT":         "1s",
+				"READ_HEADER_TIMEOUT":  "2s",
+				"WRITE_TIMEOUT":        "3s",
+				"IDLE_TIMEOUT":         "4s",
+				"SHUTDOWN_TIMEOUT":     "5s",
+				"SHUTDOWN_DRAIN_DELAY": "0s",
+				"MAX_HEADER_BYTES":     "4096",
+			},
+			want: serverConfig{
+				Addr:              "127.0.0.1:9000",
+				ReadTimeout:       time.Second,
+				ReadHeaderTimeout: 2 * time.Second,
+				WriteTimeout:      3 * time.Second,
+				IdleTimeout:       4 * time.Second,
+				ShutdownTimeout:   5 * time.Second,
+				DrainDelay:        0,
+				MaxHeaderBytes:    4096,
+			},
+		},
+		{name: "bad duration", env: map[string]string{"WRITE_TIMEOUT": "soon"}, wantErr: true},
+		{name: "negative duration", env: map[string]string{"IDLE_TIMEOUT": "-1s"}, wantErr: true},
+		{name: "zero timeout", env: map[string]string{"READ_TIMEOUT": "0s"}, wantErr: true},
+		{name: "bad header size", env: map[string]string{"MAX_HEADER_BYTES": "0"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := loadServerConfig(envFrom(tc.env))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error loading server config: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("Expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckRequiredEnv(t *testing.T) {
+	env := map[string]string{"DB_URL": "postgres://", "JWT_SECRET": "secret"}
+	if err := checkRequiredEnv(envFrom(env)); err == nil {
+		t.Errorf("Expected an error when POLKA_KEY is missing")
+	}
+
+	env["POLKA_KEY"] = "key"
+	if err := checkRequiredEnv(envFrom(env)); err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	}
+
+	// The in-memory store doesn't need a database
+	env = map[string]string{"STORAGE": "memory", "JWT_SECRET": "secret", "POLKA_KEY": "key"}
+	if err := checkRequiredEnv(envFrom(env)); err != nil {
+		t.Errorf("Expected no error with STORAGE=memory, got %s", err)
+	}
+	env["STORAGE"] = "mysql"
+	if err := checkRequiredEnv(envFrom(env)); err == nil {
+		t.Errorf("Expected an error for an unknown STORAGE")
+	}
+}
+
+func TestLoadPolkaKeys(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"key", []string{"key"}, false},
+		{"new-key, old-key", []string{"new-key", "old-key"}, false},
+		{"new-key,,", []string{"new-key"}, false},
+		{" , ", nil, true},
+		{"", nil, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := loadPolkaKeys(envFrom(map[string]string{"POLKA_KEY": tc.value}))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error loading keys: %s", err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadRateLimitConfig(t *testing.T) {
+	rules, backend, err := loadRateLimitConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatalf("Error loading rate limits: %s", err)
+	}
+	if backend != rateLimitMemory || len(rules) != len(defaultRateLimits) {
+		t.Errorf("Expected the default rules in memory, got %v in %s", rules, backend)
+	}
+
+	rules, _, err = loadRateLimitConfig(envFrom(map[string]string{"RATE_LIMITS": "POST /api/login=off"}))
+	if err != nil {
+		t.Fatalf("Error loading rate limits: %s", err)
+	}
+	if _, ok := rules["POST /api/login"]; ok {
+		t.Errorf("Expected the login limit to be turned off")
+	}
+
+	// Only a Postgres database can share buckets between replicas
+	env := map[string]string{"RATE_LIMIT_BACKEND": "postgres", "DB_URL": "postgres://localhost/chirpy"}
+	if _, backend, err := loadRateLimitConfig(envFrom(env)); err != nil || backend != rateLimitPostgres {
+		t.Errorf("Expected the postgres backend, got %q and %v", backend, err)
+	}
+	env["DB_URL"] = "sqlite:chirpy.db"
+	if _, _, err := loadRateLimitConfig(envFrom(env)); err == nil {
+		t.Errorf("Expected an error for the postgres backend with SQLite")
+	}
+	env["RATE_LIMIT_BACKEND"] = "redis"
+	if _, _, err := loadRateLimitConfig(envFrom(env)); err == nil {
+		t.Errorf("Expected an error for an unknown RATE_LIMIT_BACKEND")
+	}
+	if _, _, err := loadRateLimitConfig(envFrom(map[string]string{"RATE_LIMITS": "POST /api/login=lots"})); err == nil {
+		t.Errorf("Expected an error for an invalid RATE_LIMITS")
+	}
+}
+
+func TestLoadTrustedProxies(t *testing.T) {
+	proxies, err := loadTrustedProxies(envFrom(map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8, 192.168.1.5, fd00::/8,"}))
+	if err != nil {
+		t.Fatalf("Error loading trusted proxies: %s", err)
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.5/32"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+	if !slices.Equal(proxies, want) {
+		t.Errorf("Expected %v, got %v", want, proxies)
+	}
+
+	if proxies, err := loadTrustedProxies(envFrom(map[string]string{})); err != nil || len(proxies) != 0 {
+		t.Errorf("Expected no trusted proxies by default, got %v and %v", proxies, err)
+	}
+	if _, err := loadTrustedProxies(envFrom(map[string]string{"TRUSTED_PROXIES": "load-balancer"})); err == nil {
+		t.Errorf("Expected an error for an invalid TRUSTED_PROXIES")
+	}
+}
+
+func TestLoadDatabaseConfig(t *testing.T) {
+	tests := []struct {
+		dbURL      string
+		wantDriver string
+		wantDSN    string
+		wantErr    bool
+	}{
+		{dbURL: "postgres://chirpy@localhost/chirpy", wantDriver: "postgres", wantDSN: "postgres://chirpy@localhost/chirpy"},
+		{dbURL: "postgresql://localhost/chirpy", wantDriver: "postgres", wantDSN: "postgresql://localhost/chirpy"},
+		{dbURL: "sqlite:chirpy.db", wantDriver: "sqlite", wantDSN: "file:chirpy.db?" + sqlitePragmas},
+		{dbURL: "sqlite:///var/lib/chirpy.db", wantDriver: "sqlite", wantDSN: "file:/var/lib/chirpy.db?" + sqlitePragmas},
+		{dbURL: "sqlite:", wantErr: true},
+		{dbURL: "mysql://localhost/chirpy", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		dbCfg, err := loadDatabaseConfig(tc.dbURL)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Expected an error for %q", tc.dbURL)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Error parsing %q: %s", tc.dbURL, err)
+			continue
+		}
+		if dbCfg.Driver != tc.wantDriver || dbCfg.DSN != tc.wantDSN {
+			t.Errorf("Expected %s %q for %q, got %s %q", tc.wantDriver, tc.wantDSN, tc.dbURL, dbCfg.Driver, dbCfg.DSN)
+		}
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	serverCfg, err := loadServerConfig(envFrom(map[string]string{"WRITE_TIMEOUT": "7s"}))
+	if err != nil {
+		t.Fatalf("Error loading server config: %s", err)
+	}
+	srv := newServer(&apiConfig{server: serverCfg, metrics: metrics.New(nil)})
+
+	if srv.Addr != ":8080" || srv.WriteTimeout != 7*time.Second || srv.MaxHeaderBytes != serverCfg.MaxHeaderBytes {
+		t.Errorf("Expected server settings from config, got addr %q, write timeout %s", srv.Addr, srv.WriteTimeout)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/healthz", nil))
+	if rec.Code != 200 {
+		t.Errorf("Expected status 200 from /api/healthz, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-Request-ID") == "" {
+		t.Errorf("Expected an X-Request-ID response header")
+	}
+}
+
+func TestReadyzWhileShuttingDown(t *testing.T) {
+	cfg := &apiConfig{metrics: metrics.New(nil)}
+	cfg.shuttingDown.Store(true)
+
+	rec := httptest.NewRecorder()
+	cfg.readyzHandler(rec, httptest.NewRequest("GET", "/api/readyz", nil))
+	if rec.Code != 503 {
+		t.Errorf("Expected status 503 while shutting down, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"status":"shutting_down"`) {
+		t.Errorf("Expected shutting_down status, got %s", rec.Body.String())
+	}
+}
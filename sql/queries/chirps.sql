@@ -1,29 +1,178 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to)
 VALUES (
    gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2 
+    $2,
+    $3
 )
 RETURNING *;
 
--- name: GetAllChirps :many
+-- name: ReturnChirp :one
+-- Hidden chirps are only returned to their author, or with include_hidden for moderators
 SELECT * FROM chirps
-ORDER BY created_at ASC;
+WHERE
+    id = sqlc.arg('id')
+    AND deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('include_hidden')::boolean);
 
--- name: ReturnChirp :one
+-- name: ReturnChirpIncludingDeleted :one
 SELECT * FROM chirps
 WHERE id = $1;
 
--- name: ReturnUserChirps :many
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('include_hidden')::boolean)
+    AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+    AND (
+        sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+    )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('include_hidden')::boolean)
+    AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+    AND (
+        sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpsByLikes :many
+-- Most liked chirps first, for the popular view
 SELECT * FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('include_hidden')::boolean)
+    AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+    AND (
+        sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (like_count, created_at, id) < (sqlc.narg('cursor_like_count')::integer, sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+    )
+ORDER BY like_count DESC, created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: SearchChirps :many
+-- Full-text search, best matches first, with the matching words highlighted
+-- ts_headline doesn't escape the body, so it's HTML-escaped first and <mark> is the only markup in the snippet
+SELECT
+    sqlc.embed(chirps),
+    ts_rank(to_tsvector('english', chirps.body), query)::real AS rank,
+    ts_headline(
+        'english',
+        replace(replace(replace(replace(replace(chirps.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;'),
+        query,
+        'StartSel=<mark>, StopSel=</mark>'
+    )::text AS snippet
+FROM chirps, websearch_to_tsquery('english', sqlc.arg('query')) AS query
+WHERE
+    chirps.deleted_at IS NULL
+    AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('include_hidden')::boolean)
+    AND to_tsvector('english', chirps.body) @@ query
+    AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+    AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since')::timestamp)
+    AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until')::timestamp)
+    AND (
+        sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (ts_rank(to_tsvector('english', chirps.body), query), chirps.created_at, chirps.id) < (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+    )
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
 
 -- name: DeleteChirp :exec
-DELETE FROM chirps
+-- Soft delete, so replies can still point at the chirp as a tombstone
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    deleted_at = NOW()
 WHERE 
     id = $1
-    AND user_id = $2;
\ No newline at end of file
+    AND user_id = $2
+    AND deleted_at IS NULL;
+
+-- name: ReturnChirpForUpdate :one
+SELECT * FROM chirps
+WHERE id = $1 AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    body = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: GetChirpAncestors :many
+-- Walks up the in_reply_to chain, returning the root of the thread first
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT parent.id, parent.in_reply_to, 1
+    FROM chirps AS parent
+    WHERE parent.id = (SELECT start.in_reply_to FROM chirps AS start WHERE start.id = $1)
+    UNION ALL
+    SELECT c.id, c.in_reply_to, a.depth + 1
+    FROM chirps AS c
+    JOIN ancestors AS a ON c.id = a.in_reply_to
+)
+SELECT chirps.* FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+ORDER BY ancestors.depth DESC;
+
+-- name: GetChirpDescendants :many
+-- Walks down every reply chain below a chirp, oldest replies first
+WITH RECURSIVE descendants (id) AS (
+    SELECT reply.id
+    FROM chirps AS reply
+    WHERE reply.in_reply_to = sqlc.arg('id')::uuid
+    UNION ALL
+    SELECT c.id
+    FROM chirps AS c
+    JOIN descendants AS d ON c.in_reply_to = d.id
+)
+SELECT chirps.* FROM chirps
+JOIN descendants ON chirps.id = descendants.id
+ORDER BY chirps.created_at ASC, chirps.id ASC;
+
+-- name: ListTimeline :many
+-- Chirps by the user and everyone they follow, newest first
+-- Hidden chirps are only included if they're the user's, or with include_hidden for moderators
+SELECT * FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = sqlc.arg('user_id')::uuid OR sqlc.arg('include_hidden')::boolean)
+    AND (
+        user_id = sqlc.arg('user_id')::uuid
+        OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('user_id')::uuid)
+    )
+    AND (
+        sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+
+-- name: AdjustChirpLikeCount :exec
+UPDATE chirps
+SET like_count = like_count + sqlc.arg('delta')::integer
+WHERE id = $1;
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL;
+
+-- name: UnhideChirp :execrows
+UPDATE chirps
+SET hidden_at = NULL
+WHERE id = $1 AND hidden_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- name: DenyToken :exec
+-- Denying an ID again keeps whichever expiry is later
+INSERT INTO denied_tokens (id, created_at, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2
+)
+ON CONFLICT (id) DO UPDATE
+SET expires_at = GREATEST(denied_tokens.expires_at, EXCLUDED.expires_at);
+
+-- name: ListDeniedTokens :many
+SELECT id, expires_at FROM denied_tokens
+WHERE expires_at > NOW();
+
+-- name: DeleteExpiredDeniedTokens :execrows
+DELETE FROM denied_tokens
+WHERE expires_at <= NOW();
@@ -0,0 +1,30 @@
+-- name: FindMentionCandidates :many
+-- Users whose handle or email local part matches one of the names
+SELECT id, email, handle FROM users
+WHERE
+    LOWER(handle) = ANY(sqlc.arg('names')::text[])
+    OR LOWER(split_part(email, '@', 1)) = ANY(sqlc.arg('names')::text[]);
+
+-- name: AddChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, created_at)
+SELECT sqlc.arg('chirp_id'), user_id, NOW()
+FROM unnest(sqlc.arg('user_ids')::uuid[]) AS user_id
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: ListMentions :many
+SELECT chirps.* FROM chirps
+JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE
+    chirp_mentions.user_id = sqlc.arg('user_id')
+    AND chirps.deleted_at IS NULL
+    AND chirps.hidden_at IS NULL
+    AND (
+        sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
@@ -0,0 +1,50 @@
+-- name: ListModerationWords :many
+SELECT * FROM moderation_words
+ORDER BY word ASC;
+
+-- name: UpsertModerationWord :one
+INSERT INTO moderation_words (word, created_at, updated_at, action)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2
+)
+ON CONFLICT (word) DO UPDATE
+SET
+    updated_at = NOW(),
+    action = EXCLUDED.action
+RETURNING *;
+
+-- name: DeleteModerationWord :execrows
+DELETE FROM moderation_words
+WHERE word = $1;
+
+-- name: CreateModerationFlag :exec
+INSERT INTO moderation_flags (id, created_at, chirp_id, word, reviewed_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    NULL
+);
+
+-- name: ListPendingModerationFlags :many
+-- Flags waiting for review, oldest first, with the flagged chirp
+SELECT sqlc.embed(moderation_flags), sqlc.embed(chirps)
+FROM moderation_flags
+JOIN chirps ON chirps.id = moderation_flags.chirp_id
+WHERE
+    moderation_flags.reviewed_at IS NULL
+    AND (
+        sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (moderation_flags.created_at, moderation_flags.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+    )
+ORDER BY moderation_flags.created_at ASC, moderation_flags.id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ReviewModerationFlag :execrows
+UPDATE moderation_flags
+SET reviewed_at = NOW()
+WHERE id = $1 AND reviewed_at IS NULL;
@@ -1,23 +1,86 @@
 -- name: CreateRToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, user_agent, ip, last_used_at)
 VALUES (
    $1,
     NOW(),
     NOW(),
     $2,
     NOW() + INTERVAL '60 days',
-    NULL 
+    NULL,
+    $3,
+    $4,
+    $5,
+    NOW()
 )
 RETURNING *;
 
--- name: GetUserFromRToken :one
-SELECT refresh_tokens.user_id
-FROM refresh_tokens
-WHERE refresh_tokens.token = $1 AND refresh_tokens.expires_at > NOW() AND refresh_tokens.revoked_at IS NULL;
+-- name: GetRTokenForUpdate :one
+-- Locks the token, so concurrent refreshes with it take turns
+-- Revoked and expired tokens are returned too, for the caller to check
+SELECT * FROM refresh_tokens
+WHERE token = $1
+FOR UPDATE;
 
--- name: RevokeRToken :exec
+-- name: RevokeRToken :many
+-- Returns the token's family, so its access tokens can be denied too
 UPDATE refresh_tokens
 SET 
     revoked_at = NOW(),
     updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1
+RETURNING family_id;
+
+-- name: RevokeUserRTokens :many
+-- Returns the families of the revoked sessions
+UPDATE refresh_tokens
+SET 
+    revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+RETURNING family_id;
+
+-- name: RevokeRTokenFamily :exec
+UPDATE refresh_tokens
+SET
+    revoked_at = NOW(),
+    updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserRTokens :many
+-- Revokes every session but one, as after a password change, returning the revoked families
+UPDATE refresh_tokens
+SET
+    revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = sqlc.arg('user_id') AND family_id <> sqlc.arg('keep_family_id') AND revoked_at IS NULL
+RETURNING family_id;
+
+-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+SET
+    revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = sqlc.arg('user_id') AND family_id = sqlc.arg('family_id') AND revoked_at IS NULL;
+
+-- name: ListUserSessions :many
+-- A session is a token family, described by its active token and signed in when its first token was issued
+SELECT
+    active.family_id,
+    first_token.created_at AS signed_in_at,
+    active.last_used_at,
+    active.expires_at,
+    active.user_agent,
+    active.ip
+FROM refresh_tokens AS active
+JOIN refresh_tokens AS first_token ON first_token.family_id = active.family_id
+WHERE
+    active.user_id = $1
+    AND active.revoked_at IS NULL
+    AND active.expires_at > NOW()
+    AND NOT EXISTS (
+        SELECT 1 FROM refresh_tokens AS older
+        WHERE
+            older.family_id = first_token.family_id
+            AND (older.created_at, older.token) < (first_token.created_at, first_token.token)
+    )
+ORDER BY active.last_used_at DESC, active.family_id ASC;
@@ -0,0 +1,51 @@
+-- name: CreateReport :one
+-- Returns no rows if the user has already reported the chirp
+INSERT INTO reports (id, created_at, updated_at, chirp_id, reporter_id, reason, status, resolved_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    'open',
+    NULL
+)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: ReturnReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: ListReports :many
+-- Reports with a given status, oldest first, with the reported chirp
+SELECT sqlc.embed(reports), sqlc.embed(chirps)
+FROM reports
+JOIN chirps ON chirps.id = reports.chirp_id
+WHERE
+    reports.status = sqlc.arg('status')
+    AND (
+        sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (reports.created_at, reports.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+    )
+ORDER BY reports.created_at ASC, reports.id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ResolveReport :one
+UPDATE reports
+SET
+    updated_at = NOW(),
+    status = $2,
+    resolved_at = NOW()
+WHERE id = $1 AND status = 'open'
+RETURNING *;
+
+-- name: ResolveOpenChirpReports :exec
+-- Close any other open reports about the same chirp once it has been dealt with
+UPDATE reports
+SET
+    updated_at = NOW(),
+    status = $2,
+    resolved_at = NOW()
+WHERE chirp_id = $1 AND status = 'open';
@@ -0,0 +1,32 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, user_id, body, in_reply_to, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    sqlc.arg('user_id'),
+    sqlc.arg('body'),
+    sqlc.narg('in_reply_to'),
+    sqlc.arg('publish_at')
+)
+RETURNING *;
+
+-- name: ListUserScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at ASC, id ASC;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = sqlc.arg('id') AND user_id = sqlc.arg('user_id');
+
+-- name: ListDueScheduledChirps :many
+-- Suspended users' chirps wait until they're unsuspended
+-- Rows are locked, and ones locked by another instance skipped, so each chirp is posted once
+SELECT scheduled_chirps.* FROM scheduled_chirps
+JOIN users ON users.id = scheduled_chirps.user_id
+WHERE
+    scheduled_chirps.publish_at <= sqlc.arg('publish_before')
+    AND users.suspended_at IS NULL
+ORDER BY scheduled_chirps.publish_at ASC, scheduled_chirps.id ASC
+LIMIT sqlc.arg('page_limit')
+FOR UPDATE OF scheduled_chirps SKIP LOCKED;
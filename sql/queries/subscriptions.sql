@@ -0,0 +1,52 @@
+-- name: UpsertSubscription :execrows
+-- Starts or replaces a user's subscription, affecting no rows if the user doesn't exist
+-- An empty customer ID keeps the one already stored
+INSERT INTO subscriptions (user_id, created_at, updated_at, plan, status, current_period_end, polka_customer_id)
+SELECT
+    users.id,
+    NOW(),
+    NOW(),
+    sqlc.arg('plan'),
+    sqlc.arg('status'),
+    sqlc.arg('current_period_end'),
+    sqlc.arg('polka_customer_id')
+FROM users
+WHERE users.id = sqlc.arg('user_id')
+ON CONFLICT (user_id) DO UPDATE
+SET
+    updated_at = NOW(),
+    plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    polka_customer_id = COALESCE(NULLIF(EXCLUDED.polka_customer_id, ''), subscriptions.polka_customer_id);
+
+-- name: UpdateSubscriptionStatus :execrows
+-- A NULL period end keeps the current one
+UPDATE subscriptions
+SET
+    updated_at = NOW(),
+    status = sqlc.arg('status'),
+    current_period_end = COALESCE(sqlc.narg('current_period_end'), current_period_end)
+WHERE user_id = sqlc.arg('user_id');
+
+-- name: ExpireLapsedSubscriptions :many
+-- Returns the users whose subscriptions expired
+UPDATE subscriptions
+SET
+    updated_at = NOW(),
+    status = 'expired'
+WHERE status <> 'expired' AND current_period_end <= NOW()
+RETURNING user_id;
+
+-- name: SyncUserChirpyRed :exec
+-- Derives is_chirpy_red from the user's subscription
+UPDATE users
+SET
+    updated_at = NOW(),
+    is_chirpy_red = NOT is_chirpy_red
+WHERE
+    id = $1
+    AND is_chirpy_red <> EXISTS (
+        SELECT 1 FROM subscriptions
+        WHERE user_id = users.id AND status <> 'expired' AND current_period_end > NOW()
+    );
@@ -0,0 +1,49 @@
+-- name: UpsertTags :many
+-- Returns the IDs of the named tags, creating any that don't exist yet
+INSERT INTO tags (id, created_at, name)
+SELECT gen_random_uuid(), NOW(), name
+FROM unnest(sqlc.arg('names')::text[]) AS name
+ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+RETURNING id;
+
+-- name: AddChirpTags :exec
+INSERT INTO chirp_tags (chirp_id, tag_id, created_at)
+SELECT sqlc.arg('chirp_id'), tag_id, NOW()
+FROM unnest(sqlc.arg('tag_ids')::uuid[]) AS tag_id
+ON CONFLICT (chirp_id, tag_id) DO NOTHING;
+
+-- name: DeleteStaleChirpTags :exec
+-- Removes the chirp's tags other than the ones kept, so kept tags keep their created_at
+DELETE FROM chirp_tags
+WHERE
+    chirp_id = sqlc.arg('chirp_id')
+    AND NOT (tag_id = ANY(sqlc.arg('keep_tag_ids')::uuid[]));
+
+-- name: ListTagChirps :many
+SELECT chirps.* FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+JOIN tags ON tags.id = chirp_tags.tag_id
+WHERE
+    tags.name = sqlc.arg('tag')
+    AND chirps.deleted_at IS NULL
+    AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.narg('viewer_id')::uuid OR sqlc.arg('include_hidden')::boolean)
+    AND (
+        sqlc.narg('cursor_created_at')::timestamp IS NULL
+        OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListTrendingTags :many
+-- Tags used by the most chirps in the last window_seconds, timed by the database clock
+SELECT tags.name, COUNT(*) AS chirp_count
+FROM chirp_tags
+JOIN tags ON tags.id = chirp_tags.tag_id
+JOIN chirps ON chirps.id = chirp_tags.chirp_id
+WHERE
+    chirp_tags.created_at >= NOW() - make_interval(secs => sqlc.arg('window_seconds')::float8)
+    AND chirps.deleted_at IS NULL
+    AND chirps.hidden_at IS NULL
+GROUP BY tags.name
+ORDER BY chirp_count DESC, tags.name ASC
+LIMIT sqlc.arg('tag_limit');
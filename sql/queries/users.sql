@@ -1,33 +1,84 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
    gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, email,is_chirpy_red;
+RETURNING id, created_at, updated_at, email,is_chirpy_red, handle;
 
 -- name: ResetUsers :exec
 DELETE FROM users *;
 
 -- name: UserLogin :one
-SELECT id, created_at, updated_at, email, hashed_password,is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password,is_chirpy_red, handle, suspended_at, role FROM users
 WHERE email = $1;
 
 -- name: UpdateUser :one
+-- The handle is only changed when a new one is given
 UPDATE users
 SET
     updated_at = NOW(),
-    email = $2,
-    hashed_password = $3
-WHERE id = $1
-RETURNING id, created_at, updated_at, email,is_chirpy_red;
+    email = sqlc.arg('email'),
+    hashed_password = sqlc.arg('hashed_password'),
+    handle = COALESCE(sqlc.narg('handle'), handle)
+WHERE id = sqlc.arg('id')
+RETURNING id, created_at, updated_at, email,is_chirpy_red, handle;
 
--- name: UpgradeUserToChirpyRed :exec
+-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, is_chirpy_red, handle FROM users
+WHERE id = $1;
+
+-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, is_chirpy_red, handle FROM users
+WHERE LOWER(handle) = LOWER(sqlc.arg('handle'));
+
+
+-- name: IsUserSuspended :one
+SELECT (suspended_at IS NOT NULL)::boolean AS suspended FROM users
+WHERE id = $1;
+
+-- name: SuspendUser :exec
 UPDATE users
 SET
     updated_at = NOW(),
-    is_chirpy_red = TRUE
-WHERE id = $1;
\ No newline at end of file
+    suspended_at = NOW()
+WHERE id = $1 AND suspended_at IS NULL;
+
+-- name: UnsuspendUser :execrows
+UPDATE users
+SET
+    updated_at = NOW(),
+    suspended_at = NULL
+WHERE id = $1 AND suspended_at IS NOT NULL;
+
+-- name: GetUserAccess :one
+SELECT role, suspended_at FROM users
+WHERE id = $1;
+
+-- name: SetUserRole :execrows
+UPDATE users
+SET
+    updated_at = NOW(),
+    role = $2
+WHERE id = $1;
+
+-- name: BootstrapAdmin :one
+-- Creates the admin user, or promotes an existing user with the same email
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, role)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    'admin'
+)
+ON CONFLICT (email) DO UPDATE
+SET
+    updated_at = NOW(),
+    role = 'admin'
+RETURNING id;
\ No newline at end of file
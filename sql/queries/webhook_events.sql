@@ -0,0 +1,16 @@
+-- name: RecordWebhookEvent :execrows
+-- Affects no rows if the event was already recorded
+-- An ignored event can be recorded again as processed, so it's handled if support is added and Polka resends it
+INSERT INTO webhook_events (id, event, received_at, result)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3
+)
+ON CONFLICT (id) DO UPDATE
+SET
+    event = EXCLUDED.event,
+    received_at = EXCLUDED.received_at,
+    result = EXCLUDED.result
+WHERE webhook_events.result = 'ignored' AND EXCLUDED.result = 'processed';
@@ -0,0 +1,7 @@
+-- +goose Up
+-- An expression index rather than a stored column, so reading chirps doesn't fetch their tsvector
+-- Queries must use the same to_tsvector('english', body) expression to use it
+CREATE INDEX chirps_search_idx ON chirps USING GIN (to_tsvector('english', body));
+
+-- +goose Down
+DROP INDEX chirps_search_idx;
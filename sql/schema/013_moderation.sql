@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE moderation_words (
+    word TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'flag', 'reject'))
+);
+
+-- The words that used to be hardcoded in profanityFilter
+INSERT INTO moderation_words (word, created_at, updated_at, action)
+VALUES
+    ('kerfuffle', NOW(), NOW(), 'mask'),
+    ('sharbert', NOW(), NOW(), 'mask'),
+    ('fornax', NOW(), NOW(), 'mask');
+
+CREATE TABLE moderation_flags (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    word TEXT NOT NULL,
+    reviewed_at TIMESTAMP
+);
+
+CREATE INDEX moderation_flags_pending_idx ON moderation_flags (created_at, id) WHERE reviewed_at IS NULL;
+
+-- +goose Down
+DROP TABLE moderation_flags;
+DROP TABLE moderation_words;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Access token IDs (jti) and session IDs (sid) revoked before their tokens expire
+-- Rows can be deleted once expires_at has passed, as every token they cover has expired too
+CREATE TABLE denied_tokens (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX denied_tokens_expires_at_idx ON denied_tokens (expires_at);
+
+-- +goose Down
+DROP TABLE denied_tokens;
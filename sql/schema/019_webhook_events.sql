@@ -0,0 +1,12 @@
+-- +goose Up
+-- IDs of the webhook events already received, so redeliveries are ignored
+-- Events we don't handle are kept as ignored, so there's a record of what Polka sent
+CREATE TABLE webhook_events (
+    id TEXT PRIMARY KEY,
+    event TEXT NOT NULL,
+    received_at TIMESTAMP NOT NULL,
+    result TEXT NOT NULL CHECK (result IN ('processed', 'ignored'))
+);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,9 @@
+// Package schema embeds the goose migrations so the binary can apply them itself.
+package schema
+
+import "embed"
+
+// FS holds the migration files, named NNN_description.sql
+//
+//go:embed *.sql
+var FS embed.FS
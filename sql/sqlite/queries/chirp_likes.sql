@@ -0,0 +1,17 @@
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+VALUES (
+    sqlc.arg('user_id'),
+    sqlc.arg('chirp_id'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now')
+)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE user_id = sqlc.arg('user_id') AND chirp_id = sqlc.arg('chirp_id');
+
+-- name: ListLikedChirpIDs :many
+-- Which of the given chirps the user has liked
+SELECT chirp_id FROM chirp_likes
+WHERE user_id = sqlc.arg('user_id') AND chirp_id IN (sqlc.slice('chirp_ids'));
@@ -0,0 +1,13 @@
+-- name: CreateChirpRevision :one
+INSERT INTO chirp_revisions (created_at, chirp_id, body)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.arg('chirp_id'),
+    sqlc.arg('body')
+)
+RETURNING *;
+
+-- name: ListChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = sqlc.arg('chirp_id')
+ORDER BY created_at ASC;
@@ -0,0 +1,184 @@
+-- name: CreateChirp :one
+INSERT INTO chirps (created_at, updated_at, body, user_id, in_reply_to)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.arg('body'),
+    sqlc.narg('user_id'),
+    sqlc.narg('in_reply_to')
+)
+RETURNING *;
+
+-- name: ReturnChirp :one
+-- Hidden chirps are only returned to their author, or with include_hidden for moderators
+SELECT * FROM chirps
+WHERE
+    id = sqlc.arg('id')
+    AND deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id') OR CAST(sqlc.arg('include_hidden') AS BOOLEAN));
+
+-- name: ReturnChirpIncludingDeleted :one
+SELECT * FROM chirps
+WHERE id = sqlc.arg('id');
+
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id') OR CAST(sqlc.arg('include_hidden') AS BOOLEAN))
+    AND (user_id = sqlc.narg('author_id') OR sqlc.narg('author_id') IS NULL)
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR created_at > strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+        OR (created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND id > sqlc.narg('cursor_id'))
+    )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id') OR CAST(sqlc.arg('include_hidden') AS BOOLEAN))
+    AND (user_id = sqlc.narg('author_id') OR sqlc.narg('author_id') IS NULL)
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+        OR (created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND id < sqlc.narg('cursor_id'))
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpsByLikes :many
+-- Most liked chirps first, for the popular view
+SELECT * FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = sqlc.narg('viewer_id') OR CAST(sqlc.arg('include_hidden') AS BOOLEAN))
+    AND (user_id = sqlc.narg('author_id') OR sqlc.narg('author_id') IS NULL)
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR like_count < sqlc.narg('cursor_like_count')
+        OR (like_count = sqlc.narg('cursor_like_count') AND (
+            created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+            OR (created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND id < sqlc.narg('cursor_id'))
+        ))
+    )
+ORDER BY like_count DESC, created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: SearchChirps :many
+-- Full-text search, best matches first, with the matching words highlighted
+-- The query is in FTS5 syntax, and ranks are rounded so a cursor's rank compares equal to its row's
+-- Matches are marked with control characters, so the adapter can escape the body before adding <mark> tags
+SELECT
+    sqlc.embed(chirps),
+    CAST(round(-bm25(chirps_fts), 4) AS REAL) AS search_rank,
+    highlight(chirps_fts, 0, char(2), char(3)) AS snippet
+FROM chirps_fts
+JOIN chirps ON chirps.rowid = chirps_fts.rowid
+WHERE
+    chirps_fts.body MATCH sqlc.arg('query')
+    AND chirps.deleted_at IS NULL
+    AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.narg('viewer_id') OR CAST(sqlc.arg('include_hidden') AS BOOLEAN))
+    AND (chirps.user_id = sqlc.narg('author_id') OR sqlc.narg('author_id') IS NULL)
+    AND (sqlc.narg('since') IS NULL OR chirps.created_at >= strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('since')))
+    AND (sqlc.narg('until') IS NULL OR chirps.created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('until')))
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR round(-bm25(chirps_fts), 4) < round(sqlc.narg('cursor_rank'), 4)
+        OR (round(-bm25(chirps_fts), 4) = round(sqlc.narg('cursor_rank'), 4) AND (
+            chirps.created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+            OR (chirps.created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND chirps.id < sqlc.narg('cursor_id'))
+        ))
+    )
+ORDER BY search_rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: DeleteChirp :exec
+-- Soft delete, so replies can still point at the chirp as a tombstone
+UPDATE chirps
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    deleted_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE
+    id = sqlc.arg('id')
+    AND user_id = sqlc.arg('user_id')
+    AND deleted_at IS NULL;
+
+-- name: ReturnChirpForUpdate :one
+-- Transactions take the write lock when they begin, so there's no FOR UPDATE
+SELECT * FROM chirps
+WHERE id = sqlc.arg('id') AND deleted_at IS NULL;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    body = sqlc.arg('body')
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: GetChirpAncestors :many
+-- Walks up the in_reply_to chain, returning the root of the thread first
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT parent.id, parent.in_reply_to, 1
+    FROM chirps AS parent
+    WHERE parent.id = (SELECT start.in_reply_to FROM chirps AS start WHERE start.id = sqlc.arg('id'))
+    UNION ALL
+    SELECT c.id, c.in_reply_to, a.depth + 1
+    FROM chirps AS c
+    JOIN ancestors AS a ON c.id = a.in_reply_to
+)
+SELECT chirps.* FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+ORDER BY ancestors.depth DESC;
+
+-- name: GetChirpDescendants :many
+-- Walks down every reply chain below a chirp, oldest replies first
+WITH RECURSIVE descendants (id) AS (
+    SELECT reply.id
+    FROM chirps AS reply
+    WHERE reply.in_reply_to = sqlc.arg('id')
+    UNION ALL
+    SELECT c.id
+    FROM chirps AS c
+    JOIN descendants AS d ON c.in_reply_to = d.id
+)
+SELECT chirps.* FROM chirps
+JOIN descendants ON chirps.id = descendants.id
+ORDER BY chirps.created_at ASC, chirps.id ASC;
+
+-- name: ListTimeline :many
+-- Chirps by the user and everyone they follow, newest first
+-- Hidden chirps are only included if they're the user's, or with include_hidden for moderators
+SELECT * FROM chirps
+WHERE
+    deleted_at IS NULL
+    AND (hidden_at IS NULL OR user_id = sqlc.arg('user_id') OR CAST(sqlc.arg('include_hidden') AS BOOLEAN))
+    AND (
+        user_id = sqlc.arg('user_id')
+        OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('user_id'))
+    )
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+        OR (created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND id < sqlc.narg('cursor_id'))
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: AdjustChirpLikeCount :exec
+UPDATE chirps
+SET like_count = like_count + sqlc.arg('delta')
+WHERE id = sqlc.arg('id');
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = sqlc.arg('id') AND hidden_at IS NULL;
+
+-- name: UnhideChirp :execrows
+UPDATE chirps
+SET hidden_at = NULL
+WHERE id = sqlc.arg('id') AND hidden_at IS NOT NULL;
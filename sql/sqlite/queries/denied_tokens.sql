@@ -0,0 +1,18 @@
+-- name: DenyToken :exec
+-- Denying an ID again keeps whichever expiry is later
+INSERT INTO denied_tokens (id, created_at, expires_at)
+VALUES (
+    sqlc.arg('id'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('expires_at'))
+)
+ON CONFLICT (id) DO UPDATE
+SET expires_at = max(denied_tokens.expires_at, excluded.expires_at);
+
+-- name: ListDeniedTokens :many
+SELECT id, expires_at FROM denied_tokens
+WHERE expires_at > strftime('%Y-%m-%d %H:%M:%f', 'now');
+
+-- name: DeleteExpiredDeniedTokens :execrows
+DELETE FROM denied_tokens
+WHERE expires_at <= strftime('%Y-%m-%d %H:%M:%f', 'now');
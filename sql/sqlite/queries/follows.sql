@@ -0,0 +1,36 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    sqlc.arg('follower_id'),
+    sqlc.arg('followee_id'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now')
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = sqlc.arg('follower_id') AND followee_id = sqlc.arg('followee_id');
+
+-- name: ListFollowers :many
+SELECT follower_id AS user_id, created_at FROM follows
+WHERE
+    followee_id = sqlc.arg('user_id')
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+        OR (created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND follower_id < sqlc.narg('cursor_id'))
+    )
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListFollowing :many
+SELECT followee_id AS user_id, created_at FROM follows
+WHERE
+    follower_id = sqlc.arg('user_id')
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+        OR (created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND followee_id < sqlc.narg('cursor_id'))
+    )
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg('page_limit');
@@ -0,0 +1,33 @@
+-- name: FindMentionCandidates :many
+-- Users whose handle or email local part matches one of the names
+-- Pass the same names twice, as each slice can only be used once
+SELECT id, email, handle FROM users
+WHERE
+    handle_key IN (sqlc.slice('handles'))
+    OR email_local_part IN (sqlc.slice('local_parts'));
+
+-- name: AddChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, created_at)
+SELECT sqlc.arg('chirp_id'), id, strftime('%Y-%m-%d %H:%M:%f', 'now')
+FROM users
+WHERE id IN (sqlc.slice('user_ids'))
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = sqlc.arg('chirp_id');
+
+-- name: ListMentions :many
+SELECT chirps.* FROM chirps
+JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE
+    chirp_mentions.user_id = sqlc.arg('user_id')
+    AND chirps.deleted_at IS NULL
+    AND chirps.hidden_at IS NULL
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR chirps.created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+        OR (chirps.created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND chirps.id < sqlc.narg('cursor_id'))
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
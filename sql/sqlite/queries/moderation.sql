@@ -0,0 +1,50 @@
+-- name: ListModerationWords :many
+SELECT * FROM moderation_words
+ORDER BY word ASC;
+
+-- name: UpsertModerationWord :one
+INSERT INTO moderation_words (word, created_at, updated_at, action)
+VALUES (
+    sqlc.arg('word'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.arg('action')
+)
+ON CONFLICT (word) DO UPDATE
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    action = excluded.action
+RETURNING *;
+
+-- name: DeleteModerationWord :execrows
+DELETE FROM moderation_words
+WHERE word = sqlc.arg('word');
+
+-- name: CreateModerationFlag :exec
+INSERT INTO moderation_flags (created_at, chirp_id, word, reviewed_at)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.arg('chirp_id'),
+    sqlc.arg('word'),
+    NULL
+);
+
+-- name: ListPendingModerationFlags :many
+-- Flags waiting for review, oldest first, with the flagged chirp
+SELECT sqlc.embed(moderation_flags), sqlc.embed(chirps)
+FROM moderation_flags
+JOIN chirps ON chirps.id = moderation_flags.chirp_id
+WHERE
+    moderation_flags.reviewed_at IS NULL
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR moderation_flags.created_at > strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+        OR (moderation_flags.created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND moderation_flags.id > sqlc.narg('cursor_id'))
+    )
+ORDER BY moderation_flags.created_at ASC, moderation_flags.id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ReviewModerationFlag :execrows
+UPDATE moderation_flags
+SET reviewed_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = sqlc.arg('id') AND reviewed_at IS NULL;
@@ -0,0 +1,115 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (created_at, user_id, url, secret)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.narg('user_id'),
+    sqlc.arg('url'),
+    sqlc.arg('secret')
+)
+RETURNING *;
+
+-- name: AddWebhookSubscriptionEvent :exec
+-- SQLite has no arrays, so the store adds a subscription's events one at a time
+INSERT INTO webhook_subscription_events (subscription_id, event)
+VALUES (sqlc.arg('subscription_id'), sqlc.arg('event'))
+ON CONFLICT (subscription_id, event) DO NOTHING;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions
+WHERE id = sqlc.arg('id');
+
+-- name: ListWebhookSubscriptions :many
+-- A user's subscriptions, or the admin ones when user_id is NULL
+SELECT * FROM webhook_subscriptions
+WHERE user_id IS sqlc.narg('user_id')
+ORDER BY created_at ASC, id ASC;
+
+-- name: ListWebhookSubscriptionEvents :many
+SELECT event FROM webhook_subscription_events
+WHERE subscription_id = sqlc.arg('subscription_id')
+ORDER BY event ASC;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = sqlc.arg('id') AND user_id IS sqlc.narg('user_id');
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- Queue an event for every subscription that wants it: the admin ones, and the user's own
+INSERT INTO webhook_deliveries (created_at, updated_at, subscription_id, event, payload, status, attempts, next_attempt_at)
+SELECT
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    webhook_subscriptions.id,
+    webhook_subscription_events.event,
+    CAST(sqlc.arg('payload') AS TEXT),
+    'pending',
+    0,
+    strftime('%Y-%m-%d %H:%M:%f', 'now')
+FROM webhook_subscriptions
+JOIN webhook_subscription_events ON webhook_subscription_events.subscription_id = webhook_subscriptions.id
+WHERE
+    webhook_subscription_events.event = sqlc.arg('event')
+    AND (webhook_subscriptions.user_id IS NULL OR webhook_subscriptions.user_id = sqlc.arg('user_id'));
+
+-- name: ClaimWebhookDeliveries :many
+-- Pending deliveries that are due, leased until lease_until so nothing else sends them meanwhile
+-- Transactions take the write lock when they begin, so there's no FOR UPDATE
+UPDATE webhook_deliveries
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    next_attempt_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('lease_until'))
+WHERE id IN (
+    SELECT due.id FROM webhook_deliveries AS due
+    WHERE due.status = 'pending' AND due.next_attempt_at <= strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('due_by'))
+    ORDER BY due.next_attempt_at ASC, due.id ASC
+    LIMIT sqlc.arg('page_limit')
+)
+RETURNING *;
+
+-- name: RecordWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (created_at, delivery_id, response_status, response_body, error)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.arg('delivery_id'),
+    sqlc.narg('response_status'),
+    sqlc.arg('response_body'),
+    sqlc.narg('error')
+);
+
+-- name: UpdateWebhookDelivery :exec
+-- Record the outcome of an attempt: delivered, failed for good, or pending a retry at next_attempt_at
+UPDATE webhook_deliveries
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    status = sqlc.arg('status'),
+    attempts = attempts + 1,
+    next_attempt_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('next_attempt_at'))
+WHERE id = sqlc.arg('id');
+
+-- name: ListWebhookDeliveryAttempts :many
+-- A subscription's delivery log, newest attempt first
+SELECT
+    webhook_delivery_attempts.id,
+    webhook_delivery_attempts.created_at,
+    webhook_delivery_attempts.delivery_id,
+    webhook_deliveries.event,
+    webhook_deliveries.status,
+    webhook_delivery_attempts.response_status,
+    webhook_delivery_attempts.response_body,
+    webhook_delivery_attempts.error
+FROM webhook_delivery_attempts
+JOIN webhook_deliveries ON webhook_deliveries.id = webhook_delivery_attempts.delivery_id
+WHERE
+    webhook_deliveries.subscription_id = sqlc.arg('subscription_id')
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR webhook_delivery_attempts.created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+        OR (webhook_delivery_attempts.created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND webhook_delivery_attempts.id < sqlc.narg('cursor_id'))
+    )
+ORDER BY webhook_delivery_attempts.created_at DESC, webhook_delivery_attempts.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: DeleteOldWebhookDeliveries :execrows
+-- Finished deliveries, and their attempts, are kept for the delivery log until they're older than before
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending' AND updated_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('before'));
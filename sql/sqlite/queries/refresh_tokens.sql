@@ -0,0 +1,85 @@
+-- name: CreateRToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, user_agent, ip, last_used_at)
+VALUES (
+    sqlc.arg('token'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.narg('user_id'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now', '+60 days'),
+    NULL,
+    sqlc.arg('family_id'),
+    sqlc.arg('user_agent'),
+    sqlc.arg('ip'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now')
+)
+RETURNING *;
+
+-- name: GetRTokenForUpdate :one
+-- Transactions take the write lock when they begin, so there's no FOR UPDATE
+-- Revoked and expired tokens are returned too, for the caller to check
+SELECT * FROM refresh_tokens
+WHERE token = sqlc.arg('token');
+
+-- name: RevokeRToken :many
+-- Returns the token's family, so its access tokens can be denied too
+UPDATE refresh_tokens
+SET
+    revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE token = sqlc.arg('token')
+RETURNING family_id;
+
+-- name: RevokeUserRTokens :many
+-- Returns the families of the revoked sessions
+UPDATE refresh_tokens
+SET
+    revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = sqlc.narg('user_id') AND revoked_at IS NULL
+RETURNING family_id;
+
+-- name: RevokeRTokenFamily :exec
+UPDATE refresh_tokens
+SET
+    revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE family_id = sqlc.arg('family_id') AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserRTokens :many
+-- Revokes every session but one, as after a password change, returning the revoked families
+UPDATE refresh_tokens
+SET
+    revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = sqlc.narg('user_id') AND family_id <> sqlc.arg('keep_family_id') AND revoked_at IS NULL
+RETURNING family_id;
+
+-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+SET
+    revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = sqlc.narg('user_id') AND family_id = sqlc.arg('family_id') AND revoked_at IS NULL;
+
+-- name: ListUserSessions :many
+-- A session is a token family, described by its active token and signed in when its first token was issued
+SELECT
+    active.family_id,
+    first_token.created_at AS signed_in_at,
+    active.last_used_at,
+    active.expires_at,
+    active.user_agent,
+    active.ip
+FROM refresh_tokens AS active
+JOIN refresh_tokens AS first_token ON first_token.family_id = active.family_id
+WHERE
+    active.user_id = sqlc.narg('user_id')
+    AND active.revoked_at IS NULL
+    AND active.expires_at > strftime('%Y-%m-%d %H:%M:%f', 'now')
+    AND NOT EXISTS (
+        SELECT 1 FROM refresh_tokens AS older
+        WHERE
+            older.family_id = first_token.family_id
+            AND (older.created_at < first_token.created_at OR (older.created_at = first_token.created_at AND older.token < first_token.token))
+    )
+ORDER BY active.last_used_at DESC, active.family_id ASC;
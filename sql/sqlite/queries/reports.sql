@@ -0,0 +1,51 @@
+-- name: CreateReport :one
+-- Returns no rows if the user has already reported the chirp
+INSERT INTO reports (created_at, updated_at, chirp_id, reporter_id, reason, status, resolved_at)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.arg('chirp_id'),
+    sqlc.arg('reporter_id'),
+    sqlc.arg('reason'),
+    'open',
+    NULL
+)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: ReturnReport :one
+SELECT * FROM reports
+WHERE id = sqlc.arg('id');
+
+-- name: ListReports :many
+-- Reports with a given status, oldest first, with the reported chirp
+SELECT sqlc.embed(reports), sqlc.embed(chirps)
+FROM reports
+JOIN chirps ON chirps.id = reports.chirp_id
+WHERE
+    reports.status = sqlc.arg('status')
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR reports.created_at > strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+        OR (reports.created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND reports.id > sqlc.narg('cursor_id'))
+    )
+ORDER BY reports.created_at ASC, reports.id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ResolveReport :one
+UPDATE reports
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    status = sqlc.arg('status'),
+    resolved_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = sqlc.arg('id') AND status = 'open'
+RETURNING *;
+
+-- name: ResolveOpenChirpReports :exec
+-- Close any other open reports about the same chirp once it has been dealt with
+UPDATE reports
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    status = sqlc.arg('status'),
+    resolved_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE chirp_id = sqlc.arg('chirp_id') AND status = 'open';
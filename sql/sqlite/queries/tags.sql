@@ -0,0 +1,54 @@
+-- name: UpsertTag :one
+-- Returns the ID of the named tag, creating it if it doesn't exist yet
+INSERT INTO tags (created_at, name)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.arg('name')
+)
+ON CONFLICT (name) DO UPDATE SET name = excluded.name
+RETURNING id;
+
+-- name: AddChirpTags :exec
+INSERT INTO chirp_tags (chirp_id, tag_id, created_at)
+SELECT sqlc.arg('chirp_id'), id, strftime('%Y-%m-%d %H:%M:%f', 'now')
+FROM tags
+WHERE id IN (sqlc.slice('tag_ids'))
+ON CONFLICT (chirp_id, tag_id) DO NOTHING;
+
+-- name: DeleteStaleChirpTags :exec
+-- Removes the chirp's tags other than the ones kept, so kept tags keep their created_at
+-- The kept IDs are looked up in tags so an empty list keeps nothing, rather than comparing with NULL
+DELETE FROM chirp_tags
+WHERE
+    chirp_id = sqlc.arg('chirp_id')
+    AND tag_id NOT IN (SELECT id FROM tags WHERE id IN (sqlc.slice('keep_tag_ids')));
+
+-- name: ListTagChirps :many
+SELECT chirps.* FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+JOIN tags ON tags.id = chirp_tags.tag_id
+WHERE
+    tags.name = sqlc.arg('tag')
+    AND chirps.deleted_at IS NULL
+    AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.narg('viewer_id') OR CAST(sqlc.arg('include_hidden') AS BOOLEAN))
+    AND (
+        sqlc.narg('cursor_created_at') IS NULL
+        OR chirps.created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at'))
+        OR (chirps.created_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('cursor_created_at')) AND chirps.id < sqlc.narg('cursor_id'))
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListTrendingTags :many
+-- Tags used by the most chirps in the last window_seconds, timed by the database clock
+SELECT tags.name, COUNT(*) AS chirp_count
+FROM chirp_tags
+JOIN tags ON tags.id = chirp_tags.tag_id
+JOIN chirps ON chirps.id = chirp_tags.chirp_id
+WHERE
+    chirp_tags.created_at >= strftime('%Y-%m-%d %H:%M:%f', 'now', printf('-%f seconds', CAST(sqlc.arg('window_seconds') AS REAL)))
+    AND chirps.deleted_at IS NULL
+    AND chirps.hidden_at IS NULL
+GROUP BY tags.name
+ORDER BY chirp_count DESC, tags.name ASC
+LIMIT sqlc.arg('tag_limit');
@@ -0,0 +1,81 @@
+-- name: CreateUser :one
+INSERT INTO users (created_at, updated_at, email, hashed_password, handle)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.arg('email'),
+    sqlc.arg('hashed_password'),
+    sqlc.narg('handle')
+)
+RETURNING id, created_at, updated_at, email, is_chirpy_red, handle;
+
+-- name: ResetUsers :exec
+DELETE FROM users;
+
+-- name: UserLogin :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, suspended_at, role FROM users
+WHERE email = sqlc.arg('email');
+
+-- name: UpdateUser :one
+-- The handle is only changed when a new one is given
+UPDATE users
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    email = sqlc.arg('email'),
+    hashed_password = sqlc.arg('hashed_password'),
+    handle = COALESCE(sqlc.narg('handle'), handle)
+WHERE id = sqlc.arg('id')
+RETURNING id, created_at, updated_at, email, is_chirpy_red, handle;
+
+-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, is_chirpy_red, handle FROM users
+WHERE id = sqlc.arg('id');
+
+-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, is_chirpy_red, handle FROM users
+WHERE handle_key = lower(sqlc.arg('handle'));
+
+-- name: IsUserSuspended :one
+SELECT CAST(suspended_at IS NOT NULL AS BOOLEAN) AS suspended FROM users
+WHERE id = sqlc.arg('id');
+
+-- name: SuspendUser :exec
+UPDATE users
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    suspended_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = sqlc.arg('id') AND suspended_at IS NULL;
+
+-- name: UnsuspendUser :execrows
+UPDATE users
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    suspended_at = NULL
+WHERE id = sqlc.arg('id') AND suspended_at IS NOT NULL;
+
+-- name: GetUserAccess :one
+SELECT role, suspended_at FROM users
+WHERE id = sqlc.arg('id');
+
+-- name: SetUserRole :execrows
+UPDATE users
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    role = sqlc.arg('role')
+WHERE id = sqlc.arg('id');
+
+-- name: BootstrapAdmin :one
+-- Creates the admin user, or promotes an existing user with the same email
+INSERT INTO users (created_at, updated_at, email, hashed_password, role)
+VALUES (
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.arg('email'),
+    sqlc.arg('hashed_password'),
+    'admin'
+)
+ON CONFLICT (email) DO UPDATE
+SET
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    role = 'admin'
+RETURNING id;
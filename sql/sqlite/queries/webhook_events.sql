@@ -0,0 +1,16 @@
+-- name: RecordWebhookEvent :execrows
+-- Affects no rows if the event was already recorded
+-- An ignored event can be recorded again as processed, so it's handled if support is added and Polka resends it
+INSERT INTO webhook_events (id, event, received_at, result)
+VALUES (
+    sqlc.arg('id'),
+    sqlc.arg('event'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    sqlc.arg('result')
+)
+ON CONFLICT (id) DO UPDATE
+SET
+    event = excluded.event,
+    received_at = excluded.received_at,
+    result = excluded.result
+WHERE webhook_events.result = 'ignored' AND excluded.result = 'processed';
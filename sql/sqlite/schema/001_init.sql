@@ -0,0 +1,199 @@
+-- The SQLite schema starts from the current state of sql/schema rather than replaying its history.
+-- IDs are UUID text generated like gen_random_uuid(), and timestamps are UTC text in the format
+-- strftime('%Y-%m-%d %H:%M:%f', 'now') produces, so they sort and compare as strings.
+
+-- +goose Up
+CREATE TABLE users (
+    id UUID PRIMARY KEY DEFAULT (lower(
+        hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+    )),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    email TEXT UNIQUE NOT NULL,
+    hashed_password TEXT NOT NULL DEFAULT 'unset',
+    is_chirpy_red BOOLEAN NOT NULL DEFAULT FALSE,
+    handle TEXT,
+    suspended_at TIMESTAMP,
+    role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin')),
+    -- Lowercased lookup keys for handles and mentions
+    handle_key TEXT GENERATED ALWAYS AS (lower(handle)) VIRTUAL,
+    email_local_part TEXT GENERATED ALWAYS AS (lower(substr(email, 1, instr(email, '@') - 1))) VIRTUAL
+);
+
+CREATE UNIQUE INDEX users_handle_idx ON users (handle_key);
+CREATE INDEX users_email_local_part_idx ON users (email_local_part);
+
+CREATE TABLE chirps (
+    id UUID PRIMARY KEY DEFAULT (lower(
+        hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+    )),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    body TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    in_reply_to UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    deleted_at TIMESTAMP,
+    like_count INTEGER NOT NULL DEFAULT 0,
+    hidden_at TIMESTAMP
+);
+
+CREATE INDEX chirps_created_at_id_idx ON chirps (created_at, id);
+CREATE INDEX chirps_user_id_created_at_id_idx ON chirps (user_id, created_at, id);
+CREATE INDEX chirps_in_reply_to_idx ON chirps (in_reply_to);
+CREATE INDEX chirps_like_count_idx ON chirps (like_count, created_at, id);
+
+-- Full-text index over chirp bodies, kept in step by the triggers below
+CREATE VIRTUAL TABLE chirps_fts USING fts5 (body, content = 'chirps', content_rowid = 'rowid', tokenize = 'porter unicode61');
+
+-- +goose StatementBegin
+CREATE TRIGGER chirps_fts_insert AFTER INSERT ON chirps
+BEGIN
+    INSERT INTO chirps_fts (rowid, body) VALUES (new.rowid, new.body);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER chirps_fts_delete AFTER DELETE ON chirps
+BEGIN
+    INSERT INTO chirps_fts (chirps_fts, rowid, body) VALUES ('delete', old.rowid, old.body);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER chirps_fts_update AFTER UPDATE OF body ON chirps
+BEGIN
+    INSERT INTO chirps_fts (chirps_fts, rowid, body) VALUES ('delete', old.rowid, old.body);
+    INSERT INTO chirps_fts (rowid, body) VALUES (new.rowid, new.body);
+END;
+-- +goose StatementEnd
+
+CREATE TABLE refresh_tokens (
+    token TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE TABLE chirp_revisions (
+    id UUID PRIMARY KEY DEFAULT (lower(
+        hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+    )),
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    body TEXT NOT NULL
+);
+
+CREATE INDEX chirp_revisions_chirp_id_idx ON chirp_revisions (chirp_id, created_at);
+
+CREATE TABLE follows (
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_followee_id_idx ON follows (followee_id, created_at);
+
+CREATE TABLE chirp_likes (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, chirp_id)
+);
+
+CREATE INDEX chirp_likes_chirp_id_idx ON chirp_likes (chirp_id);
+
+CREATE TABLE tags (
+    id UUID PRIMARY KEY DEFAULT (lower(
+        hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+    )),
+    created_at TIMESTAMP NOT NULL,
+    name TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE chirp_tags (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, tag_id)
+);
+
+CREATE INDEX chirp_tags_tag_id_idx ON chirp_tags (tag_id, created_at);
+CREATE INDEX chirp_tags_created_at_idx ON chirp_tags (created_at);
+
+CREATE TABLE chirp_mentions (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions (user_id, created_at);
+
+CREATE TABLE moderation_words (
+    word TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'flag', 'reject'))
+);
+
+INSERT INTO moderation_words (word, created_at, updated_at, action)
+VALUES
+    ('kerfuffle', strftime('%Y-%m-%d %H:%M:%f', 'now'), strftime('%Y-%m-%d %H:%M:%f', 'now'), 'mask'),
+    ('sharbert', strftime('%Y-%m-%d %H:%M:%f', 'now'), strftime('%Y-%m-%d %H:%M:%f', 'now'), 'mask'),
+    ('fornax', strftime('%Y-%m-%d %H:%M:%f', 'now'), strftime('%Y-%m-%d %H:%M:%f', 'now'), 'mask');
+
+CREATE TABLE moderation_flags (
+    id UUID PRIMARY KEY DEFAULT (lower(
+        hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+    )),
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    word TEXT NOT NULL,
+    reviewed_at TIMESTAMP
+);
+
+CREATE INDEX moderation_flags_pending_idx ON moderation_flags (created_at, id) WHERE reviewed_at IS NULL;
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY DEFAULT (lower(
+        hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+    )),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'dismissed', 'chirp_hidden', 'author_suspended')),
+    resolved_at TIMESTAMP,
+    UNIQUE (chirp_id, reporter_id)
+);
+
+CREATE INDEX reports_status_idx ON reports (status, created_at, id);
+
+-- +goose Down
+DROP TABLE reports;
+DROP TABLE moderation_flags;
+DROP TABLE moderation_words;
+DROP TABLE chirp_mentions;
+DROP TABLE chirp_tags;
+DROP TABLE tags;
+DROP TABLE chirp_likes;
+DROP TABLE follows;
+DROP TABLE chirp_revisions;
+DROP TABLE refresh_tokens;
+DROP TRIGGER chirps_fts_update;
+DROP TRIGGER chirps_fts_delete;
+DROP TRIGGER chirps_fts_insert;
+DROP TABLE chirps_fts;
+DROP TABLE chirps;
+DROP TABLE users;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Each login starts a family of refresh tokens, and every refresh replaces the token with a new one in the same family
+-- SQLite can only add a NOT NULL column with a default, so existing tokens are given their own family afterwards
+ALTER TABLE refresh_tokens
+ADD COLUMN family_id UUID NOT NULL DEFAULT '';
+
+UPDATE refresh_tokens SET family_id = lower(
+    hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+    || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN family_id;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- Chirpy Red subscriptions, kept up to date by Polka webhooks
+-- users.is_chirpy_red is derived from these: true while a subscription isn't expired and its period hasn't ended
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'cancelled', 'expired')),
+    current_period_end TIMESTAMP NOT NULL,
+    polka_customer_id TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX subscriptions_current_period_end_idx ON subscriptions (current_period_end) WHERE status <> 'expired';
+
+-- Existing Chirpy Red users bought it for life, so their period never ends and the sweeper leaves them alone
+-- A Polka downgrade still expires them
+INSERT INTO subscriptions (user_id, created_at, updated_at, plan, status, current_period_end)
+SELECT
+    id,
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    'chirpy_red',
+    'active',
+    '9999-12-31 00:00:00.000'
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
@@ -0,0 +1,9 @@
+// Package schema embeds the goose migrations for the SQLite backend.
+package schema
+
+import "embed"
+
+// FS holds the migration files, named NNN_description.sql
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/frogonabike/chirpy/internal/database"
+	"github.com/frogonabike/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// Subscription statuses, matching the CHECK constraint on subscriptions.status
+// A subscription gives Chirpy Red until its period ends, unless it has expired
+const (
+	subscriptionStatusActive    = "active"
+	subscriptionStatusPastDue   = "past_due"
+	subscriptionStatusCancelled = "cancelled"
+	subscriptionStatusExpired   = "expired"
+)
+
+// Plan recorded when an upgrade doesn't name one
+const defaultSubscriptionPlan = "chirpy_red"
+
+// How long an upgrade lasts when Polka doesn't send the period end
+const defaultSubscriptionPeriod = 30 * 24 * time.Hour
+
+// How often lapsed subscriptions are expired
+const subscriptionSweepInterval = 10 * time.Minute
+
+// Polka events about subscriptions
+const (
+	polkaEventUserUpgraded          = "user.upgraded"
+	polkaEventUserDowngraded        = "user.downgraded"
+	polkaEventSubscriptionRenewed   = "subscription.renewed"
+	polkaEventSubscriptionCancelled = "subscription.cancelled"
+	polkaEventPaymentFailed         = "payment.failed"
+)
+
+// Errors from checkSubscriptionEvent, worded for the API response
+var (
+	errUnsupportedEvent = errors.New("Unsupported event")
+	errMissingPeriodEnd = errors.New("Missing current_period_end")
+)
+
+// Data sent with Polka subscription events
+type polkaEventData struct {
+	UserID           uuid.UUID  `json:"user_id"`
+	CustomerID       string     `json:"customer_id"`
+	Plan             string     `json:"plan"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end"`
+}
+
+// Check an event is one we handle and has the data it needs
+func checkSubscriptionEvent(event string, data polkaEventData) error {
+	switch event {
+	case polkaEventUserUpgraded, polkaEventUserDowngraded, polkaEventSubscriptionCancelled, polkaEventPaymentFailed:
+		return nil
+	case polkaEventSubscriptionRenewed:
+		if data.CurrentPeriodEnd == nil {
+			return errMissingPeriodEnd
+		}
+		return nil
+	default:
+		return errUnsupportedEvent
+	}
+}
+
+// Apply a checked event to the user's subscription, and update their Chirpy Red status to match
+// Returns false if the user, or for anything but an upgrade their subscription, doesn't exist
+//   - upgrades start or replace the subscription
+//   - renewals make it active until the new period end
+//   - cancellations and failed payments keep Chirpy Red until the period ends
+//   - downgrades take Chirpy Red away straight away
+func applySubscriptionEvent(ctx context.Context, q store.Queries, event string, data polkaEventData) (bool, error) {
+	var changed int64
+	var err error
+	switch event {
+	case polkaEventUserUpgraded:
+		periodEnd := time.Now().Add(defaultSubscriptionPeriod)
+		if data.CurrentPeriodEnd != nil {
+			periodEnd = *data.CurrentPeriodEnd
+		}
+		plan := data.Plan
+		if plan == "" {
+			plan = defaultSubscriptionPlan
+		}
+		changed, err = q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			Plan:             plan,
+			Status:           subscriptionStatusActive,
+			CurrentPeriodEnd: periodEnd,
+			PolkaCustomerID:  data.CustomerID,
+			UserID:           data.UserID,
+		})
+	case polkaEventSubscriptionRenewed:
+		changed, err = q.UpdateSubscriptionStatus(ctx, database.UpdateSubscriptionStatusParams{
+			Status:           subscriptionStatusActive,
+			CurrentPeriodEnd: sql.NullTime{Time: *data.CurrentPeriodEnd, Valid: true},
+			UserID:           data.UserID,
+		})
+	case polkaEventSubscriptionCancelled:
+		changed, err = q.UpdateSubscriptionStatus(ctx, database.UpdateSubscriptionStatusParams{
+			Status: subscriptionStatusCancelled,
+			UserID: data.UserID,
+		})
+	case polkaEventPaymentFailed:
+		changed, err = q.UpdateSubscriptionStatus(ctx, database.UpdateSubscriptionStatusParams{
+			Status: subscriptionStatusPastDue,
+			UserID: data.UserID,
+		})
+	case polkaEventUserDowngraded:
+		changed, err = q.UpdateSubscriptionStatus(ctx, database.UpdateSubscriptionStatusParams{
+			Status:           subscriptionStatusExpired,
+			CurrentPeriodEnd: sql.NullTime{Time: time.Now(), Valid: true},
+			UserID:           data.UserID,
+		})
+	default:
+		return false, errUnsupportedEvent
+	}
+	if err != nil || changed == 0 {
+		return false, err
+	}
+	return true, q.SyncUserChirpyRed(ctx, data.UserID)
+}
+
+// Expire the subscriptions whose period has ended, taking Chirpy Red away from their users
+func (cfg *apiConfig) sweepSubscriptions(ctx context.Context) error {
+	tx, err := cfg.store.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userIDs, err := tx.ExpireLapsedSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if err := tx.SyncUserChirpyRed(ctx, userID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if len(userIDs) > 0 {
+		slog.InfoContext(ctx, "Expired lapsed subscriptions", "count", len(userIDs))
+	}
+	return nil
+}
+
+// Periodically expire lapsed subscriptions until the context is cancelled
+func (cfg *apiConfig) watchSubscriptions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.sweepSubscriptions(ctx); err != nil {
+				slog.ErrorContext(ctx, "Error expiring lapsed subscriptions", "error", err)
+			}
+		}
+	}
+}